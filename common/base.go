@@ -1,6 +1,11 @@
 package common
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
 
 // Page 分页参数结构体
 type Page struct {
@@ -18,6 +23,76 @@ type GeoPoint struct {
 	Coordinates []float64 `bson:"coordinates"` // 坐标，包含经度和纬度
 }
 
+// LngLat 返回 GeoJSON 点的经纬度
+func (p GeoPoint) LngLat() LngLat {
+	if len(p.Coordinates) < 2 {
+		return LngLat{}
+	}
+	return LngLat{Lng: p.Coordinates[0], Lat: p.Coordinates[1]}
+}
+
+// LngLat 经纬度坐标点，零值表示未设置位置
+type LngLat struct {
+	Lng float64 `bson:"lng" json:"lng"` // 经度，范围 [-180, 180]
+	Lat float64 `bson:"lat" json:"lat"` // 纬度，范围 [-90, 90]
+}
+
+// NewLngLat 校验经纬度范围后构造坐标点
+func NewLngLat(lng float64, lat float64) (LngLat, error) {
+	point := LngLat{Lng: lng, Lat: lat}
+	if err := point.Validate(); err != nil {
+		return LngLat{}, err
+	}
+	return point, nil
+}
+
+// ParseLngLat 解析字符串形式的经纬度并校验范围
+func ParseLngLat(lng string, lat string) (LngLat, error) {
+	lngFloat, err := strconv.ParseFloat(strings.TrimSpace(lng), 64)
+	if err != nil {
+		return LngLat{}, fmt.Errorf("经度格式错误: %s", lng)
+	}
+	latFloat, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil {
+		return LngLat{}, fmt.Errorf("纬度格式错误: %s", lat)
+	}
+	return NewLngLat(lngFloat, latFloat)
+}
+
+// Validate 校验经纬度是否为有限值且在合法范围内
+func (p LngLat) Validate() error {
+	if math.IsNaN(p.Lng) || math.IsNaN(p.Lat) || math.IsInf(p.Lng, 0) || math.IsInf(p.Lat, 0) {
+		return fmt.Errorf("经纬度不是有效数字")
+	}
+	if p.Lng < -180 || p.Lng > 180 {
+		return fmt.Errorf("经度超出范围: %v", p.Lng)
+	}
+	if p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("纬度超出范围: %v", p.Lat)
+	}
+	return nil
+}
+
+// IsZero 是否未设置位置
+func (p LngLat) IsZero() bool {
+	return p.Lng == 0 && p.Lat == 0
+}
+
+// GeoPoint 转换为 GeoJSON 点
+func (p LngLat) GeoPoint() GeoPoint {
+	return GeoPoint{Type: "Point", Coordinates: []float64{p.Lng, p.Lat}}
+}
+
+func (p LngLat) String() string {
+	return strconv.FormatFloat(p.Lng, 'f', -1, 64) + "," + strconv.FormatFloat(p.Lat, 'f', -1, 64)
+}
+
+// GeoPolygon 表示一个使用 GeoJSON 格式的多边形，第一个环为外环，首尾点相同。
+type GeoPolygon struct {
+	Type        string        `bson:"type"`        // 类型，固定为 "Polygon"
+	Coordinates [][][]float64 `bson:"coordinates"` // 环的坐标，每个点为 [经度, 纬度]
+}
+
 type BaiduDistanceResponse struct {
 	Status int `json:"status"`
 	Result struct {
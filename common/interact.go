@@ -78,6 +78,18 @@ var (
 			Message: fmt.Sprintf("服务器错误：%s", msg),
 		}
 	}
+	ValidateError = func(msg string) *ErrorMsg {
+		return &ErrorMsg{
+			Code:    40002,
+			Message: fmt.Sprintf("参数校验失败：%s", msg),
+		}
+	}
+	LoginThrottled = func(seconds int) *ErrorMsg {
+		return &ErrorMsg{
+			Code:    70006,
+			Message: fmt.Sprintf("登录失败次数过多，请 %d 秒后再试", seconds),
+		}
+	}
 	ParamError              = &ErrorMsg{Code: 40001, Message: "参数错误"}
 	RecordNotFound          = &ErrorMsg{Code: 60001, Message: "记录不存在"}
 	RecordExist             = &ErrorMsg{Code: 60002, Message: "记录已存在"}
@@ -85,4 +97,5 @@ var (
 	UserBanned              = &ErrorMsg{Code: 70002, Message: "用户被封禁"}
 	UserDeleted             = &ErrorMsg{Code: 70003, Message: "用户已删除"}
 	UserNameOrPasswordError = &ErrorMsg{Code: 70004, Message: "用户名或密码错误"}
+	PermissionDenied        = &ErrorMsg{Code: 70005, Message: "无权限访问"}
 )
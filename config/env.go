@@ -16,6 +16,15 @@ var (
 	DeepseekApiKey    string
 	AliBaiLianApiKey  string
 	PineconeApiKey    string
+	BaiduMapAk        string
+	GeocoderProvider  string
+	DistanceProvider  string
+	RoadGraphFile     string
+	// 密码策略：最小长度与至少包含的字符种类数，为空时使用默认值
+	PasswordMinLength  string
+	PasswordMinClasses string
+	// 受信任的反向代理地址（IP 或 CIDR），多个用逗号分隔；为空时不信任任何代理，客户端 IP 取连接的对端地址
+	TrustedProxies string
 )
 
 func initEnvConfig() {
@@ -31,5 +40,12 @@ func initEnvConfig() {
 	DeepseekApiKey = os.Getenv("DEEPSEEK_API_KEY")
 	AliBaiLianApiKey = os.Getenv("ALI_BAILIAN_API_KEY")
 	PineconeApiKey = os.Getenv("PINECONE_API_KEY")
+	BaiduMapAk = os.Getenv("BAIDU_MAP_AK")
+	GeocoderProvider = os.Getenv("GEOCODER_PROVIDER")
+	DistanceProvider = os.Getenv("DISTANCE_PROVIDER")
+	RoadGraphFile = os.Getenv("ROAD_GRAPH_FILE")
+	PasswordMinLength = os.Getenv("PASSWORD_MIN_LENGTH")
+	PasswordMinClasses = os.Getenv("PASSWORD_MIN_CLASSES")
+	TrustedProxies = os.Getenv("TRUSTED_PROXIES")
 	handleSuccess("初始化环境变量成功！")
 }
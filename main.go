@@ -1,8 +1,49 @@
 package main
 
-import "go_logistics/router"
+import (
+	"go.uber.org/zap"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/router"
+	"go_logistics/service"
+	"go_logistics/util"
+)
 
 func main() {
+	util.SetTokenSecret(config.SecretKey)
+	util.SetPasswordPolicy(config.PasswordMinLength, config.PasswordMinClasses)
+	// 执行尚未执行的数据迁移（坐标类型与坐标系转换、补全网点地理字段、补充用户角色），
+	// 失败时记录日志后继续启动，下次启动时重试
+	if err := entity.RunMigrations(); err != nil {
+		config.Log.Error("数据迁移失败！", zap.Error(err))
+	}
+	if err := entity.EnsureOutletIndexes(); err != nil {
+		panic(err)
+	}
+	if err := entity.EnsureInventoryIndexes(); err != nil {
+		panic(err)
+	}
+	if err := entity.EnsureOutletIntakeIndexes(); err != nil {
+		panic(err)
+	}
+	if err := entity.EnsureGeoPOIIndexes(); err != nil {
+		panic(err)
+	}
+	if err := entity.EnsureDistanceCacheIndexes(); err != nil {
+		panic(err)
+	}
+	if err := entity.EnsureSessionIndexes(); err != nil {
+		panic(err)
+	}
+	if err := entity.EnsureLoginAttemptIndexes(); err != nil {
+		panic(err)
+	}
+	// 同步已吊销的会话，Token 校验只查内存中的吊销列表
+	service.StartSessionRevocationSync()
+	// 定时检查网点滞留包裹
+	service.StartInventoryMonitor()
+	// 重新调度收件已满而顺延的订单
+	service.StartOrderRetrySweeper()
 	server := router.Router()
 	if err := server.Run(":8080"); err != nil {
 		panic(err)
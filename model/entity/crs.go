@@ -0,0 +1,182 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"go_logistics/config"
+	"go_logistics/util"
+)
+
+// 各实体的 ConvertCRS 将坐标从当前标注的坐标系转换到目标坐标系并更新标注，
+// 未标注坐标系的历史数据按 util.LegacyCRS 处理。
+// 接口入参先标注为请求坐标系再转换到 util.StorageCRS，出参从存储坐标系转换到请求坐标系。
+
+// ConvertCRS 转换网点坐标与营业范围，Location 与 Area 需在入库前重新生成
+func (o *Outlet) ConvertCRS(to util.CRS) {
+	from := o.CRS.OrLegacy()
+	o.LngLat = util.ConvertLngLat(o.LngLat, from, to)
+	o.Scope = util.ConvertGeoPoints(o.Scope, from, to)
+	o.CRS = to
+}
+
+// ConvertCRS 转换线路点位
+func (r *Route) ConvertCRS(to util.CRS) {
+	r.Points = util.ConvertGeoPoints(r.Points, r.CRS.OrLegacy(), to)
+	r.CRS = to
+}
+
+// ConvertCRS 转换订单起终点坐标
+func (o *Order) ConvertCRS(to util.CRS) {
+	from := o.CRS.OrLegacy()
+	o.Start = util.ConvertLngLat(o.Start, from, to)
+	o.End = util.ConvertLngLat(o.End, from, to)
+	o.CRS = to
+}
+
+// ConvertCRS 转换车辆当前位置
+func (v *Vehicle) ConvertCRS(to util.CRS) {
+	v.LngLat = util.ConvertLngLat(v.LngLat, v.CRS.OrLegacy(), to)
+	v.CRS = to
+}
+
+// ConvertCRS 转换趟次起终点坐标
+func (t *Trip) ConvertCRS(to util.CRS) {
+	from := t.CRS.OrLegacy()
+	t.Start = util.ConvertLngLat(t.Start, from, to)
+	t.End = util.ConvertLngLat(t.End, from, to)
+	t.CRS = to
+}
+
+// ConvertCRS 转换司机上报事件的位置
+func (e *DriverEvent) ConvertCRS(to util.CRS) {
+	e.LngLat = util.ConvertLngLat(e.LngLat, e.CRS.OrLegacy(), to)
+	e.CRS = to
+}
+
+// ConvertCRS 转换派送计划的网点与停靠点坐标
+func (p *DeliveryPlan) ConvertCRS(to util.CRS) {
+	from := p.CRS.OrLegacy()
+	p.Depot = util.ConvertLngLat(p.Depot, from, to)
+	for _, route := range p.Routes {
+		for i := range route.Stops {
+			route.Stops[i].Position = util.ConvertLngLat(route.Stops[i].Position, from, to)
+		}
+	}
+	p.CRS = to
+}
+
+// legacyCRSFilter 未标注坐标系的历史数据
+var legacyCRSFilter = bson.M{"crs": bson.M{"$exists": false}}
+
+// MigrateLegacyCRS 将未标注坐标系的历史数据从 BD-09 转换为存储坐标系并补充标注，
+// 网点同时重新生成 location 与 area，需在创建地理索引前执行
+func MigrateLegacyCRS() error {
+	migrations := []struct {
+		name       string
+		collection *mongo.Collection
+		convert    func(cursor *mongo.Cursor) (bson.M, error)
+	}{
+		{"网点", OutletCollection, convertOutletCRS},
+		{"线路", RouteCollection, func(cursor *mongo.Cursor) (bson.M, error) {
+			var route Route
+			if err := cursor.Decode(&route); err != nil {
+				return nil, err
+			}
+			route.ConvertCRS(util.StorageCRS)
+			return bson.M{"points": route.Points}, nil
+		}},
+		{"订单", OrderCollection, func(cursor *mongo.Cursor) (bson.M, error) {
+			var order Order
+			if err := cursor.Decode(&order); err != nil {
+				return nil, err
+			}
+			order.ConvertCRS(util.StorageCRS)
+			return bson.M{"start": order.Start, "end": order.End}, nil
+		}},
+		{"车辆", VehicleCollection, func(cursor *mongo.Cursor) (bson.M, error) {
+			var vehicle Vehicle
+			if err := cursor.Decode(&vehicle); err != nil {
+				return nil, err
+			}
+			vehicle.ConvertCRS(util.StorageCRS)
+			return bson.M{"lng": vehicle.Lng, "lat": vehicle.Lat}, nil
+		}},
+		{"行程", TripCollection, func(cursor *mongo.Cursor) (bson.M, error) {
+			var trip Trip
+			if err := cursor.Decode(&trip); err != nil {
+				return nil, err
+			}
+			trip.ConvertCRS(util.StorageCRS)
+			return bson.M{"start": trip.Start, "end": trip.End}, nil
+		}},
+		{"司机事件", DriverEventCollection, func(cursor *mongo.Cursor) (bson.M, error) {
+			var event DriverEvent
+			if err := cursor.Decode(&event); err != nil {
+				return nil, err
+			}
+			event.ConvertCRS(util.StorageCRS)
+			return bson.M{"lng": event.Lng, "lat": event.Lat}, nil
+		}},
+	}
+	for _, migration := range migrations {
+		if err := migrateCollectionCRS(migration.collection, migration.convert); err != nil {
+			return fmt.Errorf("迁移%s坐标失败: %w", migration.name, err)
+		}
+	}
+	return nil
+}
+
+// convertOutletCRS 转换网点坐标并重新生成地理字段
+func convertOutletCRS(cursor *mongo.Cursor) (bson.M, error) {
+	var outlet Outlet
+	if err := cursor.Decode(&outlet); err != nil {
+		return nil, err
+	}
+	outlet.ConvertCRS(util.StorageCRS)
+	set := bson.M{"lng": outlet.Lng, "lat": outlet.Lat, "scope": outlet.Scope}
+	if location, err := util.NewGeoPoint(outlet.LngLat); err == nil {
+		set["location"] = location
+	}
+	if area, err := util.ScopeToPolygon(outlet.Scope); err == nil {
+		set["area"] = area
+	} else {
+		config.Log.Warn("网点营业范围不合法，跳过地理索引", zap.String("outletId", outlet.ID.Hex()), zap.Error(err))
+	}
+	return set, nil
+}
+
+// migrateCollectionCRS 逐条转换集合中未标注坐标系的文档，坐标不合法的文档记录日志后保留原坐标并标注为历史坐标系
+func migrateCollectionCRS(collection *mongo.Collection, convert func(cursor *mongo.Cursor) (bson.M, error)) error {
+	ctx := context.Background()
+	cursor, err := collection.Find(ctx, legacyCRSFilter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		id := cursor.Current.Lookup("_id")
+		set, err := convert(cursor)
+		if err != nil {
+			config.Log.Warn("坐标不合法，保留原坐标", zap.String("collection", collection.Name()),
+				zap.String("id", id.String()), zap.Error(err))
+			set = bson.M{"crs": util.LegacyCRS}
+		} else {
+			set["crs"] = util.StorageCRS
+		}
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+		if err != nil && set["area"] != nil {
+			// 自相交等 2dsphere 不接受的多边形只记录日志，仍然写入转换后的坐标
+			config.Log.Warn("网点营业范围无法建立地理索引", zap.String("id", id.String()), zap.Error(err))
+			delete(set, "area")
+			_, err = collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+		}
+		if err != nil {
+			config.Log.Warn("迁移坐标失败", zap.String("collection", collection.Name()),
+				zap.String("id", id.String()), zap.Error(err))
+		}
+	}
+	return cursor.Err()
+}
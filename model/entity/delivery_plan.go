@@ -0,0 +1,263 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+	"time"
+)
+
+var DeliveryPlanCollection = config.MongoClient.Database("logistics").Collection("delivery_plan")
+
+// DeliveryPlanStatus 派送计划状态
+type DeliveryPlanStatus int
+
+const (
+	DeliveryPlanDraft      DeliveryPlanStatus = 1 // 待确认
+	DeliveryPlanCommitted  DeliveryPlanStatus = 2 // 已下发
+	DeliveryPlanCommitting DeliveryPlanStatus = 3 // 下发中，下发前先原子地认领计划，避免重复下发
+	DeliveryPlanPartial    DeliveryPlanStatus = 4 // 部分下发，失败的路线记录在 DeliveryRoute.CommitError
+)
+
+func (s DeliveryPlanStatus) String() string {
+	textMap := map[DeliveryPlanStatus]string{
+		DeliveryPlanDraft:      "待确认",
+		DeliveryPlanCommitted:  "已下发",
+		DeliveryPlanCommitting: "下发中",
+		DeliveryPlanPartial:    "部分下发",
+	}
+	return textMap[s]
+}
+
+// DeliveryTimeWindow 订单的期望送达时间段，时间为 HH:mm（北京时间）
+type DeliveryTimeWindow struct {
+	OrderID string `json:"orderId"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// DeliveryStop 派送路线上的一个停靠点
+type DeliveryStop struct {
+	Sequence    int                `bson:"sequence" json:"sequence"` // 派送顺序，从 1 开始
+	OrderID     string             `bson:"orderId" json:"orderId"`
+	Address     string             `bson:"address" json:"address"`
+	Position    common.LngLat      `bson:"position" json:"position"`
+	Weight      float64            `bson:"weight" json:"weight"`
+	WindowStart primitive.DateTime `bson:"windowStart,omitempty" json:"windowStart"`
+	WindowEnd   primitive.DateTime `bson:"windowEnd,omitempty" json:"windowEnd"`
+	Arrival     primitive.DateTime `bson:"arrival" json:"arrival"` // 预计到达时间
+}
+
+// DeliveryRoute 一辆车的派送路线，从网点出发并返回网点
+type DeliveryRoute struct {
+	PlateNumber  string             `bson:"plateNumber" json:"plateNumber"`
+	VehicleType  VehicleType        `bson:"vehicleType" json:"vehicleType"`
+	LoadCapacity float64            `bson:"loadCapacity" json:"loadCapacity"`
+	Load         float64            `bson:"load" json:"load"`
+	Distance     float64            `bson:"distance" json:"distance"` // 含返回网点的总里程，单位公里
+	Duration     float64            `bson:"duration" json:"duration"` // 含返回网点的总时长，单位小时
+	ReturnTime   primitive.DateTime `bson:"returnTime" json:"returnTime"`
+	Stops        []DeliveryStop     `bson:"stops" json:"stops"`
+	TripID       string             `bson:"tripId,omitempty" json:"tripId"`           // 下发后生成的趟次
+	CommitError  string             `bson:"commitError,omitempty" json:"commitError"` // 下发失败的原因
+}
+
+// DeliveryPlan 网点某天的末端派送计划
+type DeliveryPlan struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OutletID      string             `bson:"outletId" json:"outletId"`
+	OutletName    string             `bson:"outletName" json:"outletName"`
+	Date          string             `bson:"date" json:"date"` // 派送日期 yyyy-MM-dd
+	Depot         common.LngLat      `bson:"depot" json:"depot"`
+	DepartureTime primitive.DateTime `bson:"departureTime" json:"departureTime"`
+	Provider      string             `bson:"provider" json:"provider"` // 计算行驶距离的服务
+	Status        DeliveryPlanStatus `bson:"status" json:"status"`
+	Routes        []*DeliveryRoute   `bson:"routes" json:"routes"`
+	Unassigned    []string           `bson:"unassigned" json:"unassigned"` // 载重或时间窗无法满足的订单
+	TotalDistance float64            `bson:"totalDistance" json:"totalDistance"`
+	CRS           util.CRS           `bson:"crs" json:"crs"` // 坐标系，入库时统一为 WGS-84
+	Operator      string             `bson:"operator" json:"operator"`
+	CommitTime    primitive.DateTime `bson:"commitTime,omitempty" json:"commitTime"`
+	CreateTime    primitive.DateTime `bson:"createTime" json:"createTime"`
+}
+
+// Parse 将 HH:mm 时间段解析为派送当天的时间，结束时间为空表示不限
+func (w DeliveryTimeWindow) Parse(day time.Time) (time.Time, time.Time, error) {
+	var start, end time.Time
+	if w.Start != "" {
+		minutes, err := parseShiftMinutes(w.Start)
+		if err != nil {
+			return start, end, err
+		}
+		start = day.Add(time.Duration(minutes) * time.Minute)
+	}
+	if w.End != "" {
+		minutes, err := parseShiftMinutes(w.End)
+		if err != nil {
+			return start, end, err
+		}
+		end = day.Add(time.Duration(minutes) * time.Minute)
+		if !start.IsZero() && !end.After(start) {
+			return start, end, fmt.Errorf("订单 %s 的送达时间段结束时间必须晚于开始时间", w.OrderID)
+		}
+	}
+	return start, end, nil
+}
+
+// InsertDeliveryPlan 新建派送计划
+func InsertDeliveryPlan(plan *DeliveryPlan) error {
+	plan.Status = DeliveryPlanDraft
+	plan.CRS = util.StorageCRS
+	plan.CreateTime = util.GetMongoTimeNow()
+	result, err := DeliveryPlanCollection.InsertOne(context.Background(), plan)
+	if err != nil {
+		return err
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		plan.ID = id
+	}
+	return nil
+}
+
+// GetDeliveryPlanById 根据 ID 获取派送计划
+func GetDeliveryPlanById(planId string) (*DeliveryPlan, error) {
+	objectId, err := primitive.ObjectIDFromHex(planId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid planId: %w", err)
+	}
+	var plan DeliveryPlan
+	if err = DeliveryPlanCollection.FindOne(context.Background(), bson.M{"_id": objectId}).Decode(&plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// GetDeliveryPlanList 获取网点的派送计划，日期为空时不限，按创建时间倒序
+func GetDeliveryPlanList(outletId string, date string) ([]*DeliveryPlan, error) {
+	filter := bson.M{"outletId": outletId}
+	if date != "" {
+		filter["date"] = date
+	}
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"createTime": -1})
+	cursor, err := DeliveryPlanCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var plans []*DeliveryPlan
+	if err = cursor.All(context.Background(), &plans); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// ClaimDeliveryPlan 原子地将待确认的计划标记为下发中，计划已被认领或已下发时返回错误
+func ClaimDeliveryPlan(plan *DeliveryPlan, operator string) error {
+	filter := bson.M{"_id": plan.ID, "status": DeliveryPlanDraft}
+	update := bson.M{"$set": bson.M{"status": DeliveryPlanCommitting, "operator": operator}}
+	result, err := DeliveryPlanCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("派送计划已下发或正在下发")
+	}
+	plan.Status = DeliveryPlanCommitting
+	plan.Operator = operator
+	return nil
+}
+
+// ReleaseDeliveryPlan 尚未下发任何路线时放弃认领，计划恢复为待确认
+func ReleaseDeliveryPlan(plan *DeliveryPlan) error {
+	filter := bson.M{"_id": plan.ID, "status": DeliveryPlanCommitting}
+	update := bson.M{"$set": bson.M{"status": DeliveryPlanDraft, "operator": ""}}
+	_, err := DeliveryPlanCollection.UpdateOne(context.Background(), filter, update)
+	if err == nil {
+		plan.Status = DeliveryPlanDraft
+	}
+	return err
+}
+
+// FinishDeliveryPlanCommit 下发结束后记录各路线的趟次与失败原因，全部成功时为已下发，否则为部分下发
+func FinishDeliveryPlanCommit(plan *DeliveryPlan) error {
+	plan.Status = DeliveryPlanCommitted
+	for _, route := range plan.Routes {
+		if route.CommitError != "" {
+			plan.Status = DeliveryPlanPartial
+			break
+		}
+	}
+	filter := bson.M{"_id": plan.ID, "status": DeliveryPlanCommitting}
+	update := bson.M{
+		"$set": bson.M{
+			"status":     plan.Status,
+			"routes":     plan.Routes,
+			"commitTime": util.GetMongoTimeNow(),
+		},
+	}
+	result, err := DeliveryPlanCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("派送计划不在下发中")
+	}
+	return nil
+}
+
+// AssignOrderDelivery 订单装车派送：记录派送车辆与趟次，装车即视为取件，司机可逐单确认送达
+func AssignOrderDelivery(orderId string, plateNumber string, tripId string, driverId string) error {
+	filter := bson.M{
+		"orderId": orderId,
+		"status":  bson.M{"$in": bson.A{Pending, Processing}},
+	}
+	now := util.GetMongoTimeNow()
+	update := bson.M{
+		"$set": bson.M{
+			"status":           Processing,
+			"transPortVehicle": plateNumber,
+			"tripId":           tripId,
+			"driverId":         driverId,
+			"routeId":          "",
+			"remark":           "派送中",
+			"pickupTime":       now,
+			"updateTime":       now,
+		},
+	}
+	result, err := OrderCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("订单 %s 已结束，无法派送", orderId)
+	}
+	return nil
+}
+
+// ReturnUndeliveredOrder 派送结束时未送达的订单退回网点，恢复为待处理等待下次派送
+func ReturnUndeliveredOrder(orderId string, plateNumber string) error {
+	filter := bson.M{
+		"orderId":          orderId,
+		"transPortVehicle": plateNumber,
+		"status":           Processing,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":           Pending,
+			"transPortVehicle": "",
+			"tripId":           "",
+			"driverId":         "",
+			"remark":           "派送未完成，已退回网点",
+			"updateTime":       util.GetMongoTimeNow(),
+		},
+		"$unset": bson.M{"pickupTime": ""},
+	}
+	_, err := OrderCollection.UpdateOne(context.Background(), filter, update)
+	return err
+}
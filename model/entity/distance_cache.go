@@ -0,0 +1,165 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+	"time"
+)
+
+var DistanceCacheCollection = config.MongoClient.Database("logistics").Collection("distance_cache")
+
+// DistanceCacheTTL 距离缓存的有效期，过期后由 Mongo TTL 索引删除，下次查询时重新算路
+const DistanceCacheTTL = 30 * 24 * time.Hour
+
+// DistanceCache 两点之间的行驶距离缓存，坐标为存储坐标系
+type DistanceCache struct {
+	Key            string        `bson:"key" json:"key"` // 服务名称与起终点坐标（保留 5 位小数，约 1 米）拼接
+	Provider       string        `bson:"provider" json:"provider"`
+	Origin         common.LngLat `bson:"origin" json:"origin"`
+	Destination    common.LngLat `bson:"destination" json:"destination"`
+	util.RouteCost `bson:",inline"`
+	UpdateTime     primitive.DateTime `bson:"updateTime" json:"updateTime"`
+}
+
+// distanceCacheKey 生成缓存键，坐标保留 5 位小数，相距 1 米以内的点共用缓存
+func distanceCacheKey(provider string, origin common.LngLat, destination common.LngLat) string {
+	return fmt.Sprintf("%s|%.5f,%.5f|%.5f,%.5f", provider, origin.Lng, origin.Lat, destination.Lng, destination.Lat)
+}
+
+// EnsureDistanceCacheIndexes 创建距离缓存的唯一索引与过期索引
+func EnsureDistanceCacheIndexes() error {
+	_, err := DistanceCacheCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "updateTime", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(DistanceCacheTTL.Seconds()))},
+	})
+	if err != nil {
+		return fmt.Errorf("创建距离缓存索引失败: %w", err)
+	}
+	return nil
+}
+
+// FindDistanceCaches 按缓存键批量查询距离缓存
+func FindDistanceCaches(ctx context.Context, keys []string) (map[string]util.RouteCost, error) {
+	cursor, err := DistanceCacheCollection.Find(ctx, bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var caches []*DistanceCache
+	if err = cursor.All(ctx, &caches); err != nil {
+		return nil, err
+	}
+	result := make(map[string]util.RouteCost, len(caches))
+	for _, cache := range caches {
+		result[cache.Key] = cache.RouteCost
+	}
+	return result, nil
+}
+
+// SaveDistanceCaches 批量写入距离缓存，已存在的缓存会被覆盖并刷新有效期
+func SaveDistanceCaches(ctx context.Context, caches []*DistanceCache) error {
+	if len(caches) == 0 {
+		return nil
+	}
+	now := util.GetMongoTimeNow()
+	models := make([]mongo.WriteModel, 0, len(caches))
+	for _, cache := range caches {
+		cache.UpdateTime = now
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"key": cache.Key}).
+			SetReplacement(cache).
+			SetUpsert(true))
+	}
+	_, err := DistanceCacheCollection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// CachedDistanceProvider 为行驶距离服务增加 Mongo 距离矩阵缓存，只对缓存未命中的起终点调用下层服务
+type CachedDistanceProvider struct {
+	Provider util.DistanceProvider
+}
+
+// Name 服务名称
+func (p *CachedDistanceProvider) Name() string {
+	return p.Provider.Name()
+}
+
+// Matrix 先查缓存，再对包含未命中项的起点与终点组成的子矩阵算路并写回缓存
+func (p *CachedDistanceProvider) Matrix(ctx context.Context, origins []common.LngLat, destinations []common.LngLat) ([][]util.RouteCost, error) {
+	name := p.Provider.Name()
+	keys := make([]string, 0, len(origins)*len(destinations))
+	for _, origin := range origins {
+		for _, destination := range destinations {
+			keys = append(keys, distanceCacheKey(name, origin, destination))
+		}
+	}
+	cached, err := FindDistanceCaches(ctx, keys)
+	if err != nil {
+		// 缓存不可用时直接算路
+		config.Log.Warn("查询距离缓存失败", zap.Error(err))
+		return p.Provider.Matrix(ctx, origins, destinations)
+	}
+
+	matrix := util.NewRouteMatrix(len(origins), len(destinations))
+	var missingOrigins, missingDestinations []int
+	missingDestinationSet := make(map[int]bool)
+	for i, origin := range origins {
+		missing := false
+		for j, destination := range destinations {
+			cost, ok := cached[distanceCacheKey(name, origin, destination)]
+			if ok {
+				matrix[i][j] = cost
+				continue
+			}
+			missing = true
+			if !missingDestinationSet[j] {
+				missingDestinationSet[j] = true
+				missingDestinations = append(missingDestinations, j)
+			}
+		}
+		if missing {
+			missingOrigins = append(missingOrigins, i)
+		}
+	}
+	if len(missingOrigins) == 0 {
+		return matrix, nil
+	}
+
+	subOrigins := make([]common.LngLat, len(missingOrigins))
+	for k, i := range missingOrigins {
+		subOrigins[k] = origins[i]
+	}
+	subDestinations := make([]common.LngLat, len(missingDestinations))
+	for k, j := range missingDestinations {
+		subDestinations[k] = destinations[j]
+	}
+	computed, err := p.Provider.Matrix(ctx, subOrigins, subDestinations)
+	if err != nil {
+		return nil, err
+	}
+	caches := make([]*DistanceCache, 0, len(subOrigins)*len(subDestinations))
+	for a, i := range missingOrigins {
+		for b, j := range missingDestinations {
+			matrix[i][j] = computed[a][b]
+			caches = append(caches, &DistanceCache{
+				Key:         distanceCacheKey(name, origins[i], destinations[j]),
+				Provider:    name,
+				Origin:      origins[i],
+				Destination: destinations[j],
+				RouteCost:   computed[a][b],
+			})
+		}
+	}
+	if err = SaveDistanceCaches(ctx, caches); err != nil {
+		config.Log.Warn("写入距离缓存失败", zap.Error(err))
+	}
+	return matrix, nil
+}
@@ -0,0 +1,480 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+	"time"
+)
+
+var DriverCollection = config.MongoClient.Database("logistics").Collection("driver")
+var DriverAssignmentCollection = config.MongoClient.Database("logistics").Collection("driver_assignment")
+
+// DriverStatus 司机状态
+type DriverStatus int
+
+const (
+	DriverStatusOnDuty   DriverStatus = 1 // 在职
+	DriverStatusLeave    DriverStatus = 2 // 休假
+	DriverStatusResigned DriverStatus = 3 // 离职
+)
+
+func (s DriverStatus) String() string {
+	textMap := map[DriverStatus]string{
+		DriverStatusOnDuty:   "在职",
+		DriverStatusLeave:    "休假",
+		DriverStatusResigned: "离职",
+	}
+	return textMap[s]
+}
+
+// IsValid 判断司机状态是否合法
+func (s DriverStatus) IsValid() bool {
+	return s >= DriverStatusOnDuty && s <= DriverStatusResigned
+}
+
+// LicenseClass 驾照准驾车型
+type LicenseClass int
+
+const (
+	LicenseA2 LicenseClass = 1 // 牵引车
+	LicenseB2 LicenseClass = 2 // 大型货车
+	LicenseC1 LicenseClass = 3 // 小型汽车
+)
+
+func (l LicenseClass) String() string {
+	textMap := map[LicenseClass]string{
+		LicenseA2: "A2",
+		LicenseB2: "B2",
+		LicenseC1: "C1",
+	}
+	return textMap[l]
+}
+
+// IsValid 判断驾照等级是否合法
+func (l LicenseClass) IsValid() bool {
+	return l >= LicenseA2 && l <= LicenseC1
+}
+
+// licenseVehicleTypes 各驾照等级允许驾驶的车辆类型（高等级驾照兼容低等级车型）
+var licenseVehicleTypes = map[LicenseClass][]VehicleType{
+	LicenseA2: {Truck, Minibus, Pickup},
+	LicenseB2: {Truck, Minibus, Pickup},
+	LicenseC1: {Minibus, Pickup},
+}
+
+// CanDrive 判断驾照等级是否允许驾驶指定类型的车辆
+func (l LicenseClass) CanDrive(vehicleType VehicleType) bool {
+	for _, t := range licenseVehicleTypes[l] {
+		if t == vehicleType {
+			return true
+		}
+	}
+	return false
+}
+
+// DriverShift 司机排班，Weekday 与 time.Weekday 一致（0 表示周日），时间格式为 HH:mm
+type DriverShift struct {
+	Weekday   time.Weekday `bson:"weekday" json:"weekday"`
+	StartTime string       `bson:"startTime" json:"startTime"`
+	EndTime   string       `bson:"endTime" json:"endTime"`
+}
+
+// Driver 司机结构
+type Driver struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Phone        string             `bson:"phone" json:"phone"`
+	LicenseNo    string             `bson:"licenseNo" json:"licenseNo"`
+	LicenseClass LicenseClass       `bson:"licenseClass" json:"licenseClass"`
+	Status       DriverStatus       `bson:"status" json:"status"`
+	PlateNumber  string             `bson:"plateNumber" json:"plateNumber"` // 当前搭档车辆
+	Shifts       []DriverShift      `bson:"shifts" json:"shifts"`
+	Password     string             `bson:"password" json:"-"`
+	Salt         string             `bson:"salt" json:"-"`
+	Remark       string             `bson:"remark" json:"remark"`
+	CreateTime   primitive.DateTime `bson:"createTime" json:"-"`
+	UpdateTime   primitive.DateTime `bson:"updateTime" json:"-"`
+}
+
+// DriverAssignment 司机与车辆的搭档记录，EndTime 为空表示当前仍在搭档
+type DriverAssignment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DriverID    string             `bson:"driverId" json:"driverId"`
+	DriverName  string             `bson:"driverName" json:"driverName"`
+	PlateNumber string             `bson:"plateNumber" json:"plateNumber"`
+	StartTime   primitive.DateTime `bson:"startTime" json:"startTime"`
+	EndTime     primitive.DateTime `bson:"endTime,omitempty" json:"endTime"`
+}
+
+// FindDriverListDTO 查询司机列表的参数
+type FindDriverListDTO struct {
+	Name         string       `json:"name"`
+	Phone        string       `json:"phone"`
+	LicenseClass LicenseClass `json:"licenseClass"`
+	Status       DriverStatus `json:"status"`
+	PlateNumber  string       `json:"plateNumber"`
+	Page         common.Page  `json:"page"`
+}
+
+func (dto *FindDriverListDTO) String() string {
+	return fmt.Sprintf("name: %s, phone: %s, licenseClass: %s, status: %d, plateNumber: %s, page: %s",
+		dto.Name, dto.Phone, dto.LicenseClass, dto.Status, dto.PlateNumber, dto.Page.String())
+}
+
+// FindDriverAssignmentListDTO 查询搭档记录的参数
+type FindDriverAssignmentListDTO struct {
+	DriverID    string       `json:"driverId"`
+	PlateNumber string       `json:"plateNumber"`
+	Page        common.Page  `json:"page"`
+	Scope       *OutletScope `json:"-"` // 当前用户的网点范围，由接口层设置
+}
+
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FindDriverAssignmentListDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
+}
+
+// IsOnShift 判断司机在指定时间是否处于排班中（支持跨零点的夜班）
+func (d *Driver) IsOnShift(t time.Time) bool {
+	if d.Status != DriverStatusOnDuty {
+		return false
+	}
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	if loc != nil {
+		t = t.In(loc)
+	}
+	minutes := t.Hour()*60 + t.Minute()
+	yesterday := (t.Weekday() + 6) % 7
+	for _, shift := range d.Shifts {
+		start, err := parseShiftMinutes(shift.StartTime)
+		if err != nil {
+			continue
+		}
+		end, err := parseShiftMinutes(shift.EndTime)
+		if err != nil {
+			continue
+		}
+		if start <= end {
+			if shift.Weekday == t.Weekday() && minutes >= start && minutes < end {
+				return true
+			}
+			continue
+		}
+		// 跨零点的班次：当天开始时间之后，或前一天班次延续到今天结束时间之前
+		if shift.Weekday == t.Weekday() && minutes >= start {
+			return true
+		}
+		if shift.Weekday == yesterday && minutes < end {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateShifts 校验排班数据是否合法
+func ValidateShifts(shifts []DriverShift) error {
+	for _, shift := range shifts {
+		if shift.Weekday < time.Sunday || shift.Weekday > time.Saturday {
+			return fmt.Errorf("排班星期不合法: %d", shift.Weekday)
+		}
+		if _, err := parseShiftMinutes(shift.StartTime); err != nil {
+			return err
+		}
+		if _, err := parseShiftMinutes(shift.EndTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseShiftMinutes 将 HH:mm 解析为当天的分钟数
+func parseShiftMinutes(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("排班时间格式错误: %s", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// InsertDriver 新建司机
+func InsertDriver(driver *Driver) error {
+	count, err := DriverCollection.CountDocuments(context.Background(), bson.M{"licenseNo": driver.LicenseNo})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("驾驶证号 %s 已存在", driver.LicenseNo)
+	}
+	// 手机号作为司机端登录账号，必须唯一
+	count, err = DriverCollection.CountDocuments(context.Background(), bson.M{"phone": driver.Phone})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("手机号 %s 已存在", driver.Phone)
+	}
+	// 填充时间
+	driver.CreateTime = util.GetMongoTimeNow()
+	driver.UpdateTime = util.GetMongoTimeNow()
+	_, err = DriverCollection.InsertOne(context.Background(), driver)
+	return err
+}
+
+// UpdateDriver 修改司机信息（不修改搭档车辆，搭档关系通过 AssignDriver 维护）
+func UpdateDriver(driverId string, driver *Driver) error {
+	objectId, err := primitive.ObjectIDFromHex(driverId)
+	if err != nil {
+		return fmt.Errorf("invalid driverId: %w", err)
+	}
+	// 驾驶证号与手机号不能与其他司机重复
+	count, err := DriverCollection.CountDocuments(context.Background(),
+		bson.M{"licenseNo": driver.LicenseNo, "_id": bson.M{"$ne": objectId}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("驾驶证号 %s 已存在", driver.LicenseNo)
+	}
+	count, err = DriverCollection.CountDocuments(context.Background(),
+		bson.M{"phone": driver.Phone, "_id": bson.M{"$ne": objectId}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("手机号 %s 已存在", driver.Phone)
+	}
+	filter := bson.M{"_id": objectId}
+	update := bson.M{
+		"$set": bson.M{
+			"name":         driver.Name,
+			"phone":        driver.Phone,
+			"licenseNo":    driver.LicenseNo,
+			"licenseClass": driver.LicenseClass,
+			"status":       driver.Status,
+			"shifts":       driver.Shifts,
+			"remark":       driver.Remark,
+			"updateTime":   util.GetMongoTimeNow(),
+		},
+	}
+	result, err := DriverCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("未找到匹配的司机进行更新")
+	}
+	return nil
+}
+
+// DeleteDriver 删除司机，同时结束其搭档记录
+func DeleteDriver(driverId string) error {
+	objectId, err := primitive.ObjectIDFromHex(driverId)
+	if err != nil {
+		return fmt.Errorf("invalid driverId: %w", err)
+	}
+	if err = closeDriverAssignments(bson.M{"driverId": driverId}); err != nil {
+		return err
+	}
+	_, err = DriverCollection.DeleteOne(context.Background(), bson.M{"_id": objectId})
+	return err
+}
+
+// GetDriverById 根据ID获取司机信息
+func GetDriverById(driverId string) (driver *Driver, err error) {
+	objectId, err := primitive.ObjectIDFromHex(driverId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid driverId: %w", err)
+	}
+	err = DriverCollection.FindOne(context.Background(), bson.M{"_id": objectId}).Decode(&driver)
+	return
+}
+
+// GetDriverByPhone 根据手机号获取司机信息（司机端登录使用）
+func GetDriverByPhone(phone string) (driver *Driver, err error) {
+	err = DriverCollection.FindOne(context.Background(), bson.M{"phone": phone}).Decode(&driver)
+	return
+}
+
+// UpdateDriverPassword 修改司机端登录密码
+func UpdateDriverPassword(driverId string, password string, salt string) error {
+	objectId, err := primitive.ObjectIDFromHex(driverId)
+	if err != nil {
+		return fmt.Errorf("invalid driverId: %w", err)
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"password":   password,
+			"salt":       salt,
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	_, err = DriverCollection.UpdateOne(context.Background(), bson.M{"_id": objectId}, update)
+	return err
+}
+
+// RehashDriverPassword 登录成功后将旧哈希替换为新哈希，仅当库中仍是校验通过的旧哈希时才更新
+func RehashDriverPassword(driverId primitive.ObjectID, oldPassword string, password string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"password":   password,
+			"salt":       "",
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	_, err := DriverCollection.UpdateOne(context.Background(), bson.M{"_id": driverId, "password": oldPassword}, update)
+	return err
+}
+
+// GetDriverByVehicle 获取车辆当前搭档的司机，没有搭档时返回 nil, nil
+func GetDriverByVehicle(plateNumber string) (*Driver, error) {
+	var driver Driver
+	err := DriverCollection.FindOne(context.Background(), bson.M{"plateNumber": plateNumber}).Decode(&driver)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &driver, nil
+}
+
+// buildDriverFilter 构建司机查询条件
+func buildDriverFilter(dto FindDriverListDTO) bson.M {
+	filter := bson.M{}
+	if dto.Name != "" {
+		filter["name"] = bson.M{"$regex": dto.Name, "$options": "i"}
+	}
+	if dto.Phone != "" {
+		filter["phone"] = bson.M{"$regex": dto.Phone, "$options": "i"}
+	}
+	if dto.LicenseClass != 0 {
+		filter["licenseClass"] = dto.LicenseClass
+	}
+	if dto.Status != 0 {
+		filter["status"] = dto.Status
+	}
+	if dto.PlateNumber != "" {
+		filter["plateNumber"] = bson.M{"$regex": dto.PlateNumber, "$options": "i"}
+	}
+	return filter
+}
+
+// GetDriverList 根据条件查询司机列表
+func GetDriverList(dto FindDriverListDTO) (drivers []*Driver, err error) {
+	filter := buildDriverFilter(dto)
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
+	findOptions.SetLimit(int64(dto.Page.Limit))
+	findOptions.SetSort(bson.M{"updateTime": -1})
+
+	cursor, err := DriverCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var driver Driver
+		if err := cursor.Decode(&driver); err != nil {
+			return nil, err
+		}
+		drivers = append(drivers, &driver)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return drivers, nil
+}
+
+// GetDriverTotalCount 获取司机总数
+func GetDriverTotalCount(dto FindDriverListDTO) (count int64, err error) {
+	return DriverCollection.CountDocuments(context.Background(), buildDriverFilter(dto))
+}
+
+// AssignDriver 为司机分配车辆，结束司机和车辆之前的搭档记录后写入新记录。
+// plateNumber 为空时表示解除司机当前的搭档关系。
+func AssignDriver(driver *Driver, plateNumber string) error {
+	driverId := driver.ID.Hex()
+	if err := closeDriverAssignments(bson.M{"driverId": driverId}); err != nil {
+		return err
+	}
+	if plateNumber != "" {
+		if err := closeDriverAssignments(bson.M{"plateNumber": plateNumber}); err != nil {
+			return err
+		}
+		// 车辆原搭档司机解除关系
+		_, err := DriverCollection.UpdateMany(context.Background(),
+			bson.M{"plateNumber": plateNumber, "_id": bson.M{"$ne": driver.ID}},
+			bson.M{"$set": bson.M{"plateNumber": "", "updateTime": util.GetMongoTimeNow()}})
+		if err != nil {
+			return err
+		}
+		_, err = DriverAssignmentCollection.InsertOne(context.Background(), &DriverAssignment{
+			DriverID:    driverId,
+			DriverName:  driver.Name,
+			PlateNumber: plateNumber,
+			StartTime:   util.GetMongoTimeNow(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := DriverCollection.UpdateOne(context.Background(),
+		bson.M{"_id": driver.ID},
+		bson.M{"$set": bson.M{"plateNumber": plateNumber, "updateTime": util.GetMongoTimeNow()}})
+	return err
+}
+
+// closeDriverAssignments 结束满足条件且仍在进行中的搭档记录
+func closeDriverAssignments(filter bson.M) error {
+	filter["endTime"] = bson.M{"$exists": false}
+	update := bson.M{
+		"$set": bson.M{
+			"endTime": util.GetMongoTimeNow(),
+		},
+	}
+	_, err := DriverAssignmentCollection.UpdateMany(context.Background(), filter, update)
+	return err
+}
+
+// GetDriverAssignmentList 查询司机与车辆的搭档历史
+func GetDriverAssignmentList(dto FindDriverAssignmentListDTO) (assignments []*DriverAssignment, err error) {
+	filter := bson.M{}
+	if dto.DriverID != "" {
+		filter["driverId"] = dto.DriverID
+	}
+	if dto.PlateNumber != "" {
+		filter["plateNumber"] = dto.PlateNumber
+	}
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
+	findOptions.SetLimit(int64(dto.Page.Limit))
+	findOptions.SetSort(bson.M{"startTime": -1})
+
+	if filter, err = dto.Scope.ScopeDriverAssignments(filter); err != nil {
+		return nil, err
+	}
+	cursor, err := DriverAssignmentCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var assignment DriverAssignment
+		if err := cursor.Decode(&assignment); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, &assignment)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
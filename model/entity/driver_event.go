@@ -0,0 +1,114 @@
+package entity
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+)
+
+var DriverEventCollection = config.MongoClient.Database("logistics").Collection("driver_event")
+
+// DriverEventType 司机上报事件类型
+type DriverEventType int
+
+const (
+	DriverEventPickup   DriverEventType = 1 // 确认取件
+	DriverEventArrival  DriverEventType = 2 // 到达网点
+	DriverEventDelivery DriverEventType = 3 // 确认送达
+	DriverEventIncident DriverEventType = 4 // 异常上报
+	DriverEventPhoto    DriverEventType = 5 // 上传照片
+)
+
+func (t DriverEventType) String() string {
+	textMap := map[DriverEventType]string{
+		DriverEventPickup:   "确认取件",
+		DriverEventArrival:  "到达网点",
+		DriverEventDelivery: "确认送达",
+		DriverEventIncident: "异常上报",
+		DriverEventPhoto:    "上传照片",
+	}
+	return textMap[t]
+}
+
+// DriverEvent 司机通过移动端上报的事件
+type DriverEvent struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type          DriverEventType    `bson:"type" json:"type"`
+	DriverID      string             `bson:"driverId" json:"driverId"`
+	PlateNumber   string             `bson:"plateNumber" json:"plateNumber"`
+	OrderID       string             `bson:"orderId,omitempty" json:"orderId"`
+	OutletID      string             `bson:"outletId,omitempty" json:"outletId"`
+	FileID        string             `bson:"fileId,omitempty" json:"fileId"`
+	common.LngLat `bson:",inline"`   // 上报位置，零值表示未上报
+	CRS           util.CRS           `bson:"crs" json:"crs"` // 坐标系，入库时统一为 WGS-84
+	Description   string             `bson:"description" json:"description"`
+	CreateTime    primitive.DateTime `bson:"createTime" json:"createTime"`
+}
+
+// FindDriverEventListDTO 查询司机事件的参数
+type FindDriverEventListDTO struct {
+	DriverID    string          `json:"driverId"`
+	PlateNumber string          `json:"plateNumber"`
+	OrderID     string          `json:"orderId"`
+	Type        DriverEventType `json:"type"`
+	Page        common.Page     `json:"page"`
+	Scope       *OutletScope    `json:"-"` // 当前用户的网点范围，由接口层设置
+}
+
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FindDriverEventListDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
+}
+
+// InsertDriverEvent 新建司机事件
+func InsertDriverEvent(event *DriverEvent) error {
+	event.CRS = util.StorageCRS
+	event.CreateTime = util.GetMongoTimeNow()
+	_, err := DriverEventCollection.InsertOne(context.Background(), event)
+	return err
+}
+
+// GetDriverEventList 根据条件查询司机事件列表
+func GetDriverEventList(dto FindDriverEventListDTO) (events []*DriverEvent, err error) {
+	filter := bson.M{}
+	if dto.DriverID != "" {
+		filter["driverId"] = dto.DriverID
+	}
+	if dto.PlateNumber != "" {
+		filter["plateNumber"] = dto.PlateNumber
+	}
+	if dto.OrderID != "" {
+		filter["orderId"] = dto.OrderID
+	}
+	if dto.Type != 0 {
+		filter["type"] = dto.Type
+	}
+	if filter, err = dto.Scope.ScopeDriverEvents(filter); err != nil {
+		return nil, err
+	}
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
+	findOptions.SetLimit(int64(dto.Page.Limit))
+	findOptions.SetSort(bson.M{"createTime": -1})
+
+	cursor, err := DriverEventCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var event DriverEvent
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -17,11 +17,13 @@ type BusinessType int
 
 const (
 	AIRepository BusinessType = 1
+	DriverPhoto  BusinessType = 2
 )
 
 func (bt BusinessType) String() string {
 	textMap := map[BusinessType]string{
 		AIRepository: "AI知识库",
+		DriverPhoto:  "司机照片",
 	}
 	return textMap[bt]
 }
@@ -41,12 +43,24 @@ type FindFileListDTO struct {
 	FileType BusinessType `json:"fileType"`
 	FileName string       `json:"fileName"`
 	Page     common.Page  `json:"page"`
+	Scope    *OutletScope `json:"-"` // 当前用户的网点范围，由接口层设置
+}
+
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FindFileListDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
 }
 
 func InsertFile(ctx context.Context, file *File) (err error) {
 	file.UploadTime = util.GetMongoTimeNow()
-	_, err = FileCollection.InsertOne(ctx, file)
-	return err
+	result, err := FileCollection.InsertOne(ctx, file)
+	if err != nil {
+		return err
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		file.ID = id
+	}
+	return nil
 }
 
 func DeleteFile(ctx context.Context, fileId string) (err error) {
@@ -66,6 +80,9 @@ func GetFileList(ctx context.Context, dto FindFileListDTO) (files []*File, err e
 	if dto.FileName != "" {
 		filter["fileName"] = bson.M{"$regex": dto.FileName, "$options": "i"}
 	}
+	if filter, err = dto.Scope.ScopeFiles(filter); err != nil {
+		return
+	}
 
 	findOptions := options.Find()
 	findOptions.SetSort(bson.M{"uploadTime": -1})
@@ -0,0 +1,186 @@
+package entity
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"strconv"
+	"time"
+)
+
+// FleetStatGroupBy 车队统计的分组维度
+type FleetStatGroupBy string
+
+const (
+	FleetStatByVehicleType FleetStatGroupBy = "vehicleType"
+	FleetStatByRoute       FleetStatGroupBy = "route"
+)
+
+// FleetStatDTO 车队统计的查询参数，时间范围按趟次分配时间过滤，默认最近 30 天
+type FleetStatDTO struct {
+	GroupBy   FleetStatGroupBy `json:"groupBy"`
+	StartTime time.Time        `json:"startTime"`
+	EndTime   time.Time        `json:"endTime"`
+	Scope     *OutletScope     `json:"-"` // 当前用户的网点范围，由接口层设置
+}
+
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FleetStatDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
+}
+
+// FleetStat 车队利用率与成本指标
+type FleetStat struct {
+	Key                string  `bson:"key" json:"key"`   // 分组键：车型或线路ID，汇总行为空
+	Name               string  `bson:"name" json:"name"` // 分组名称：车型名称或线路名称
+	TripCount          int64   `bson:"tripCount" json:"tripCount"`
+	VehicleCount       int64   `bson:"vehicleCount" json:"vehicleCount"`
+	AvgLoadFactor      float64 `bson:"avgLoadFactor" json:"avgLoadFactor"`           // 平均满载率
+	Distance           float64 `bson:"distance" json:"distance"`                     // 重载里程
+	EmptyDistance      float64 `bson:"emptyDistance" json:"emptyDistance"`           // 空驶里程
+	EmptyRatio         float64 `bson:"emptyRatio" json:"emptyRatio"`                 // 空驶率 = 空驶里程 / 总里程
+	TripsPerVehicleDay float64 `bson:"tripsPerVehicleDay" json:"tripsPerVehicleDay"` // 单车日均趟次
+	AvgIdleHours       float64 `bson:"avgIdleHours" json:"avgIdleHours"`             // 平均空闲时长
+	TotalIdleHours     float64 `bson:"totalIdleHours" json:"totalIdleHours"`
+	Cost               float64 `bson:"cost" json:"cost"`
+	CostPerKm          float64 `bson:"costPerKm" json:"costPerKm"`
+	CostPerKgKm        float64 `bson:"costPerKgKm" json:"costPerKgKm"`
+}
+
+// FleetStatResult 车队统计结果
+type FleetStatResult struct {
+	StartTime time.Time    `json:"startTime"`
+	EndTime   time.Time    `json:"endTime"`
+	Summary   *FleetStat   `json:"summary"`
+	Groups    []*FleetStat `json:"groups"`
+}
+
+// Normalize 补全默认时间范围与分组维度
+func (dto *FleetStatDTO) Normalize() {
+	if dto.EndTime.IsZero() {
+		dto.EndTime = time.Now()
+	}
+	if dto.StartTime.IsZero() {
+		dto.StartTime = dto.EndTime.AddDate(0, 0, -30)
+	}
+	if dto.GroupBy != FleetStatByRoute {
+		dto.GroupBy = FleetStatByVehicleType
+	}
+}
+
+// GetFleetStats 使用聚合管道统计已完成趟次的车队利用率与成本
+func GetFleetStats(dto FleetStatDTO) (*FleetStatResult, error) {
+	dto.Normalize()
+	days := math.Max(dto.EndTime.Sub(dto.StartTime).Hours()/24, 1)
+
+	filter, err := dto.Scope.ScopeTrips(bson.M{
+		"status": TripCompleted,
+		"assignTime": bson.M{
+			"$gte": primitive.NewDateTimeFromTime(dto.StartTime.UTC()),
+			"$lte": primitive.NewDateTimeFromTime(dto.EndTime.UTC()),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	match := bson.M{"$match": filter}
+
+	groupKey := "$vehicleType"
+	if dto.GroupBy == FleetStatByRoute {
+		groupKey = "$routeId"
+	}
+
+	groups, err := aggregateFleetStats(match, groupKey, days)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := aggregateFleetStats(match, nil, days)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按车型分组时使用车型名称
+	if dto.GroupBy == FleetStatByVehicleType {
+		for _, group := range groups {
+			if vehicleType, err := strconv.Atoi(group.Key); err == nil {
+				group.Name = VehicleType(vehicleType).String()
+			}
+		}
+	}
+	result := &FleetStatResult{
+		StartTime: dto.StartTime,
+		EndTime:   dto.EndTime,
+		Summary:   &FleetStat{},
+		Groups:    groups,
+	}
+	if len(summaries) > 0 {
+		result.Summary = summaries[0]
+		result.Summary.Name = ""
+	}
+	if result.Groups == nil {
+		result.Groups = make([]*FleetStat, 0)
+	}
+	return result, nil
+}
+
+// aggregateFleetStats 按分组键聚合趟次指标，groupKey 为 nil 时汇总全部趟次
+func aggregateFleetStats(match bson.M, groupKey interface{}, days float64) ([]*FleetStat, error) {
+	safeDivide := func(numerator interface{}, denominator interface{}) bson.M {
+		return bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{denominator, 0}},
+			bson.M{"$divide": bson.A{numerator, denominator}},
+			0,
+		}}
+	}
+	pipeline := []bson.M{
+		match,
+		{"$group": bson.M{
+			"_id":            groupKey,
+			"name":           bson.M{"$first": "$routeName"},
+			"tripCount":      bson.M{"$sum": 1},
+			"vehicles":       bson.M{"$addToSet": "$plateNumber"},
+			"avgLoadFactor":  bson.M{"$avg": "$loadFactor"},
+			"distance":       bson.M{"$sum": "$distance"},
+			"emptyDistance":  bson.M{"$sum": "$emptyDistance"},
+			"avgIdleHours":   bson.M{"$avg": "$idleHours"},
+			"totalIdleHours": bson.M{"$sum": "$idleHours"},
+			"cost":           bson.M{"$sum": "$cost"},
+			// 重量单位为吨，换算为公斤·公里
+			"kgKm": bson.M{"$sum": bson.M{"$multiply": bson.A{"$totalWeight", 1000, "$distance"}}},
+		}},
+		{"$addFields": bson.M{
+			"vehicleCount":  bson.M{"$size": "$vehicles"},
+			"totalDistance": bson.M{"$add": bson.A{"$distance", "$emptyDistance"}},
+		}},
+		{"$project": bson.M{
+			"_id":                0,
+			"key":                bson.M{"$toString": "$_id"},
+			"name":               1,
+			"tripCount":          1,
+			"vehicleCount":       1,
+			"avgLoadFactor":      1,
+			"distance":           1,
+			"emptyDistance":      1,
+			"avgIdleHours":       1,
+			"totalIdleHours":     1,
+			"cost":               1,
+			"emptyRatio":         safeDivide("$emptyDistance", "$totalDistance"),
+			"tripsPerVehicleDay": safeDivide("$tripCount", bson.M{"$multiply": bson.A{"$vehicleCount", days}}),
+			"costPerKm":          safeDivide("$cost", "$totalDistance"),
+			"costPerKgKm":        safeDivide("$cost", "$kgKm"),
+		}},
+		{"$sort": bson.M{"key": 1}},
+	}
+
+	cursor, err := TripCollection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var stats []*FleetStat
+	if err = cursor.All(context.Background(), &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
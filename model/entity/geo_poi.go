@@ -0,0 +1,203 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+	"strings"
+	"unicode/utf8"
+)
+
+var GeoPOICollection = config.MongoClient.Database("logistics").Collection("geo_poi")
+
+const (
+	// offlineMinConfidence 离线地址解析的最低可信度，低于该值视为无法解析
+	offlineMinConfidence = 60
+	// offlineReverseDistance 离线逆地址解析的最大距离，单位米
+	offlineReverseDistance = 1000
+)
+
+// GeoPOI 离线地址库中的地址或兴趣点
+type GeoPOI struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name          string             `bson:"name" json:"name"`
+	Address       string             `bson:"address" json:"address"`
+	Province      string             `bson:"province" json:"province"`
+	City          string             `bson:"city" json:"city"`
+	District      string             `bson:"district" json:"district"`
+	FullAddress   string             `bson:"fullAddress" json:"fullAddress"` // 省市区与详细地址拼接后规范化的结果
+	common.LngLat `bson:",inline"`   // 离线地址统一存储为 WGS-84
+	Location      common.GeoPoint    `bson:"location" json:"-"`
+	CreateTime    primitive.DateTime `bson:"createTime" json:"-"`
+}
+
+// UpsertGeoPOIs 导入离线地址，名称与完整地址相同的记录会被覆盖，返回新增与更新的数量
+func UpsertGeoPOIs(pois []*GeoPOI) (int64, int64, error) {
+	if len(pois) == 0 {
+		return 0, 0, nil
+	}
+	models := make([]mongo.WriteModel, 0, len(pois))
+	for _, poi := range pois {
+		location, err := util.NewGeoPoint(poi.LngLat)
+		if err != nil {
+			return 0, 0, fmt.Errorf("地址 %s 坐标不合法: %w", poi.Name, err)
+		}
+		poi.Location = location
+		poi.FullAddress = util.NormalizeAddress(poi.Province + poi.City + poi.District + poi.Address)
+		poi.CreateTime = util.GetMongoTimeNow()
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"name": poi.Name, "fullAddress": poi.FullAddress}).
+			SetReplacement(poi).
+			SetUpsert(true))
+	}
+	result, err := GeoPOICollection.BulkWrite(context.Background(), models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, err
+	}
+	return result.UpsertedCount, result.ModifiedCount, nil
+}
+
+// EnsureGeoPOIIndexes 创建离线地址库的索引
+func EnsureGeoPOIIndexes() error {
+	_, err := GeoPOICollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+		{Keys: bson.D{{Key: "fullAddress", Value: 1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}}},
+		{Keys: bson.D{{Key: "city", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("创建离线地址库索引失败: %w", err)
+	}
+	return nil
+}
+
+// OfflineGeocoder 基于导入的离线地址库的地址解析，用于测试与无法访问外网的部署
+type OfflineGeocoder struct{}
+
+// Geocode 先按完整地址或名称精确匹配，再在同城地址中按最长公共子串打分选取最相近的地址
+func (g *OfflineGeocoder) Geocode(ctx context.Context, address string, city string) (*util.GeocodeResult, error) {
+	query := util.NormalizeAddress(address)
+	if query == "" {
+		return nil, util.ErrAddressNotFound
+	}
+	var exact GeoPOI
+	err := GeoPOICollection.FindOne(ctx, bson.M{"$or": []bson.M{{"fullAddress": query}, {"name": query}}}).Decode(&exact)
+	if err == nil {
+		return exact.toGeocodeResult(100), nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	cities, err := g.matchCities(ctx, query, city)
+	if err != nil {
+		return nil, err
+	}
+	if len(cities) == 0 {
+		return nil, util.ErrAddressNotFound
+	}
+	// 逐条为同城的全部地址打分，不截断候选，避免漏掉最相近的地址
+	cursor, err := GeoPOICollection.Find(ctx, bson.M{"city": bson.M{"$in": cities}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	queryLength := utf8.RuneCountInString(query)
+	var best *GeoPOI
+	bestScore := 0
+	for cursor.Next(ctx) {
+		var candidate GeoPOI
+		if err = cursor.Decode(&candidate); err != nil {
+			return nil, err
+		}
+		score := util.LongestCommonSubstring(query, candidate.FullAddress)
+		if nameScore := util.LongestCommonSubstring(query, candidate.Name); nameScore > score {
+			score = nameScore
+		}
+		if score > bestScore {
+			best, bestScore = &candidate, score
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+	confidence := bestScore * 100 / queryLength
+	if best == nil || confidence < offlineMinConfidence {
+		return nil, util.ErrAddressNotFound
+	}
+	return best.toGeocodeResult(confidence), nil
+}
+
+// ReverseGeocode 返回一定距离内最近的地址
+func (g *OfflineGeocoder) ReverseGeocode(ctx context.Context, position common.LngLat) (*util.GeocodeResult, error) {
+	filter := bson.M{
+		"location": bson.M{"$near": bson.M{
+			"$geometry":    geoPointFilter(position),
+			"$maxDistance": offlineReverseDistance,
+		}},
+	}
+	var poi GeoPOI
+	err := GeoPOICollection.FindOne(ctx, filter).Decode(&poi)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, util.ErrAddressNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := poi.toGeocodeResult(100)
+	result.LngLat = position
+	return result, nil
+}
+
+// cityVariants 城市名的两种写法，“北京”与“北京市”视为同一城市
+func cityVariants(city string) []string {
+	short := strings.TrimSuffix(strings.TrimSpace(city), "市")
+	if short == "" {
+		return nil
+	}
+	return []string{short, short + "市"}
+}
+
+// matchCities 确定解析范围：指定了城市时使用该城市（不区分是否带“市”），否则取地址中出现的城市
+func (g *OfflineGeocoder) matchCities(ctx context.Context, query string, city string) ([]string, error) {
+	if city != "" {
+		return cityVariants(city), nil
+	}
+	values, err := GeoPOICollection.Distinct(ctx, "city", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var cities []string
+	for _, value := range values {
+		name, ok := value.(string)
+		if !ok || name == "" {
+			continue
+		}
+		// 兼容地址中省略“市”的写法
+		short := strings.TrimSuffix(name, "市")
+		if strings.Contains(query, name) || (utf8.RuneCountInString(short) >= 2 && strings.Contains(query, short)) {
+			cities = append(cities, name)
+		}
+	}
+	return cities, nil
+}
+
+func (p *GeoPOI) toGeocodeResult(confidence int) *util.GeocodeResult {
+	return &util.GeocodeResult{
+		LngLat:           p.LngLat,
+		FormattedAddress: p.Province + p.City + p.District + p.Address,
+		Province:         p.Province,
+		City:             p.City,
+		District:         p.District,
+		Confidence:       confidence,
+		Provider:         "offline",
+		CRS:              util.StorageCRS,
+	}
+}
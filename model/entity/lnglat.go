@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"go_logistics/config"
+)
+
+// toDoubleExpr 将字符串字段转换为数字，空字符串或无法解析的值转换为 0（未设置位置）
+func toDoubleExpr(field string) bson.M {
+	return bson.M{"$convert": bson.M{"input": "$" + field, "to": "double", "onError": 0, "onNull": 0}}
+}
+
+// MigrateCoordinateTypes 将历史数据中字符串形式的经纬度转换为数字：
+// 网点、车辆、司机事件的 lng/lat 原地转换，订单与趟次的 startLng/startLat/endLng/endLat 合并为 start/end。
+// 需在坐标系迁移与创建地理索引前执行
+func MigrateCoordinateTypes() error {
+	ctx := context.Background()
+	stringFilter := bson.M{"$or": []bson.M{{"lng": bson.M{"$type": "string"}}, {"lat": bson.M{"$type": "string"}}}}
+	flatPipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"lng": toDoubleExpr("lng"), "lat": toDoubleExpr("lat")}}},
+	}
+	for _, collection := range []*mongo.Collection{OutletCollection, VehicleCollection, DriverEventCollection} {
+		result, err := collection.UpdateMany(ctx, stringFilter, flatPipeline)
+		if err != nil {
+			return fmt.Errorf("转换 %s 坐标类型失败: %w", collection.Name(), err)
+		}
+		if result.ModifiedCount > 0 {
+			config.Log.Info("已转换坐标类型", zap.String("collection", collection.Name()), zap.Int64("count", result.ModifiedCount))
+		}
+	}
+
+	splitFilter := bson.M{"$or": []bson.M{{"startLng": bson.M{"$exists": true}}, {"endLng": bson.M{"$exists": true}}}}
+	splitPipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"start": bson.M{"lng": toDoubleExpr("startLng"), "lat": toDoubleExpr("startLat")},
+			"end":   bson.M{"lng": toDoubleExpr("endLng"), "lat": toDoubleExpr("endLat")},
+		}}},
+		{{Key: "$unset", Value: bson.A{"startLng", "startLat", "endLng", "endLat"}}},
+	}
+	for _, collection := range []*mongo.Collection{OrderCollection, TripCollection} {
+		result, err := collection.UpdateMany(ctx, splitFilter, splitPipeline)
+		if err != nil {
+			return fmt.Errorf("转换 %s 坐标类型失败: %w", collection.Name(), err)
+		}
+		if result.ModifiedCount > 0 {
+			config.Log.Info("已转换坐标类型", zap.String("collection", collection.Name()), zap.Int64("count", result.ModifiedCount))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+	"time"
+)
+
+var LoginThrottleCollection = config.MongoClient.Database("logistics").Collection("login_throttle")
+var LoginAuditCollection = config.MongoClient.Database("logistics").Collection("login_audit")
+
+const (
+	// loginThrottleTTL 最后一次失败后保留失败计数的时长
+	loginThrottleTTL = 24 * time.Hour
+	// LoginAuditTTL 登录审计记录的保留时长
+	LoginAuditTTL = 180 * 24 * time.Hour
+)
+
+// LoginThrottle 按账号或 IP 统计的连续登录失败，Key 为 "<client>:<name>" 或 "ip:<ip>"
+type LoginThrottle struct {
+	Key         string             `bson:"key" json:"key"`
+	Failures    int                `bson:"failures" json:"failures"` // 统计窗口内的连续失败次数
+	LastFailure primitive.DateTime `bson:"lastFailure" json:"lastFailure"`
+	LockedUntil primitive.DateTime `bson:"lockedUntil,omitempty" json:"lockedUntil"` // 锁定截止时间，为空表示未锁定
+	UpdateTime  primitive.DateTime `bson:"updateTime" json:"updateTime"`
+}
+
+// LoginAudit 登录审计记录，每次登录尝试都会记录
+type LoginAudit struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Client     string             `bson:"client" json:"client"` // 登录入口：后台或司机端
+	Name       string             `bson:"name" json:"name"`     // 后台为用户名，司机端为手机号
+	IP         string             `bson:"ip" json:"ip"`
+	UserAgent  string             `bson:"userAgent" json:"userAgent"`
+	Success    bool               `bson:"success" json:"success"`
+	Reason     string             `bson:"reason" json:"reason"` // 失败原因
+	CreateTime primitive.DateTime `bson:"createTime" json:"createTime"`
+}
+
+// FindLoginAuditListDTO 查询登录审计的参数
+type FindLoginAuditListDTO struct {
+	Client    string      `json:"client"`
+	Name      string      `json:"name"`
+	IP        string      `json:"ip"`
+	Success   *bool       `json:"success"`
+	StartTime time.Time   `json:"startTime"`
+	EndTime   time.Time   `json:"endTime"`
+	Page      common.Page `json:"page"`
+}
+
+func (dto *FindLoginAuditListDTO) String() string {
+	return fmt.Sprintf("client: %s, name: %s, ip: %s, success: %v, startTime: %s, endTime: %s, page: %s",
+		dto.Client, dto.Name, dto.IP, dto.Success, dto.StartTime, dto.EndTime, dto.Page.String())
+}
+
+// IsLocked 是否处于锁定中
+func (t *LoginThrottle) IsLocked(now time.Time) bool {
+	return t.LockedUntil != 0 && t.LockedUntil.Time().After(now)
+}
+
+// EnsureLoginAttemptIndexes 创建登录限制与审计的索引
+func EnsureLoginAttemptIndexes() error {
+	_, err := LoginThrottleCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "updateTime", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(loginThrottleTTL.Seconds()))},
+	})
+	if err != nil {
+		return fmt.Errorf("创建登录限制索引失败: %w", err)
+	}
+	_, err = LoginAuditCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "createTime", Value: -1}}},
+		{Keys: bson.D{{Key: "ip", Value: 1}, {Key: "createTime", Value: -1}}},
+		{Keys: bson.D{{Key: "createTime", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(LoginAuditTTL.Seconds()))},
+	})
+	if err != nil {
+		return fmt.Errorf("创建登录审计索引失败: %w", err)
+	}
+	return nil
+}
+
+// GetLoginThrottles 批量查询登录失败统计，不存在的键不在结果中
+func GetLoginThrottles(keys []string) (map[string]*LoginThrottle, error) {
+	cursor, err := LoginThrottleCollection.Find(context.Background(), bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var throttles []*LoginThrottle
+	if err = cursor.All(context.Background(), &throttles); err != nil {
+		return nil, err
+	}
+	result := make(map[string]*LoginThrottle, len(throttles))
+	for _, throttle := range throttles {
+		result[throttle.Key] = throttle
+	}
+	return result, nil
+}
+
+// ReserveLoginAttempt 在校验密码前原子地预占一次登录尝试，预占即按一次失败计数：距上次失败超过 window 时重新计数，
+// 达到 maxFailures 次时锁定 lockDuration。previous 为预占前读取到的统计（不存在时为 nil），
+// 统计已被并发的登录请求修改时不做更新并返回 false，调用方需重新读取统计后再判断是否允许登录
+func ReserveLoginAttempt(key string, previous *LoginThrottle, now time.Time, window time.Duration, maxFailures int, lockDuration time.Duration) (*LoginThrottle, bool, error) {
+	mongoNow := primitive.NewDateTimeFromTime(now)
+	if previous == nil {
+		throttle := &LoginThrottle{Key: key, Failures: 1, LastFailure: mongoNow, UpdateTime: mongoNow}
+		if maxFailures <= 1 {
+			throttle.LockedUntil = primitive.NewDateTimeFromTime(now.Add(lockDuration))
+		}
+		_, err := LoginThrottleCollection.InsertOne(context.Background(), throttle)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return throttle, true, nil
+	}
+	throttle := *previous
+	set := bson.M{"lastFailure": mongoNow, "updateTime": mongoNow}
+	update := bson.M{"$set": set}
+	if now.Sub(previous.LastFailure.Time()) > window {
+		throttle.Failures = 1
+		set["failures"] = 1
+	} else {
+		throttle.Failures++
+		update["$inc"] = bson.M{"failures": 1}
+	}
+	if throttle.Failures >= maxFailures {
+		throttle.LockedUntil = primitive.NewDateTimeFromTime(now.Add(lockDuration))
+		set["lockedUntil"] = throttle.LockedUntil
+	}
+	throttle.LastFailure, throttle.UpdateTime = mongoNow, mongoNow
+	// 以读取到的失败次数与时间作为条件，并发请求中只有一个能基于同一份统计预占成功
+	filter := bson.M{"key": key, "failures": previous.Failures, "lastFailure": previous.LastFailure}
+	result, err := LoginThrottleCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return nil, false, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, false, nil
+	}
+	return &throttle, true, nil
+}
+
+// ReleaseLoginAttempt 撤销一次预占的登录尝试：失败次数减一，低于 maxFailures 时解除本次预占造成的锁定
+func ReleaseLoginAttempt(key string, maxFailures int) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures":   bson.M{"$max": bson.A{bson.M{"$subtract": bson.A{"$failures", 1}}, 0}},
+			"updateTime": util.GetMongoTimeNow(),
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"lockedUntil": bson.M{"$cond": bson.A{
+				bson.M{"$lt": bson.A{"$failures", maxFailures}},
+				"$$REMOVE",
+				"$lockedUntil",
+			}},
+		}}},
+	}
+	_, err := LoginThrottleCollection.UpdateOne(context.Background(), bson.M{"key": key, "failures": bson.M{"$gt": 0}}, pipeline)
+	return err
+}
+
+// ClearLoginThrottle 清除登录失败统计，登录成功或管理员解锁时调用，返回是否存在统计
+func ClearLoginThrottle(key string) (bool, error) {
+	result, err := LoginThrottleCollection.DeleteOne(context.Background(), bson.M{"key": key})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// InsertLoginAudit 新建登录审计记录
+func InsertLoginAudit(audit *LoginAudit) error {
+	audit.CreateTime = util.GetMongoTimeNow()
+	_, err := LoginAuditCollection.InsertOne(context.Background(), audit)
+	return err
+}
+
+// loginAuditFilter 登录审计的查询条件
+func loginAuditFilter(dto FindLoginAuditListDTO) bson.M {
+	filter := bson.M{}
+	if dto.Client != "" {
+		filter["client"] = dto.Client
+	}
+	if dto.Name != "" {
+		filter["name"] = bson.M{"$regex": dto.Name, "$options": "i"}
+	}
+	if dto.IP != "" {
+		filter["ip"] = dto.IP
+	}
+	if dto.Success != nil {
+		filter["success"] = *dto.Success
+	}
+	if !dto.StartTime.IsZero() || !dto.EndTime.IsZero() {
+		timeFilter := bson.M{}
+		if !dto.StartTime.IsZero() {
+			timeFilter["$gte"] = primitive.NewDateTimeFromTime(dto.StartTime)
+		}
+		if !dto.EndTime.IsZero() {
+			timeFilter["$lte"] = primitive.NewDateTimeFromTime(dto.EndTime)
+		}
+		filter["createTime"] = timeFilter
+	}
+	return filter
+}
+
+// GetLoginAuditList 根据条件查询登录审计，按时间倒序
+func GetLoginAuditList(dto FindLoginAuditListDTO) ([]*LoginAudit, error) {
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
+	findOptions.SetLimit(int64(dto.Page.Limit))
+	findOptions.SetSort(bson.M{"createTime": -1})
+	cursor, err := LoginAuditCollection.Find(context.Background(), loginAuditFilter(dto), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var audits []*LoginAudit
+	if err = cursor.All(context.Background(), &audits); err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+// GetLoginAuditTotalCount 获取登录审计总数
+func GetLoginAuditTotalCount(dto FindLoginAuditListDTO) (int64, error) {
+	return LoginAuditCollection.CountDocuments(context.Background(), loginAuditFilter(dto))
+}
@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"go_logistics/config"
+	"go_logistics/util"
+)
+
+var MigrationCollection = config.MongoClient.Database("logistics").Collection("migration")
+
+// migrationVersionId 记录已执行迁移版本的文档
+const migrationVersionId = "schema"
+
+// MigrationVersion 已执行的数据迁移版本
+type MigrationVersion struct {
+	ID         string             `bson:"_id" json:"id"`
+	Version    int                `bson:"version" json:"version"`
+	UpdateTime primitive.DateTime `bson:"updateTime" json:"updateTime"`
+}
+
+// migrations 按版本排列的数据迁移，只能在末尾追加，已发布的版本号不可修改
+var migrations = []struct {
+	version int
+	name    string
+	run     func() error
+}{
+	{1, "坐标类型转换", MigrateCoordinateTypes},
+	{2, "历史坐标系转换", MigrateLegacyCRS},
+	{3, "补全网点地理字段", BackfillOutletGeo},
+	{4, "补充用户角色", MigrateUserRoles},
+}
+
+// getMigrationVersion 查询已执行的迁移版本，从未执行过时返回 0
+func getMigrationVersion() (int, error) {
+	var version MigrationVersion
+	err := MigrationCollection.FindOne(context.Background(), bson.M{"_id": migrationVersionId}).Decode(&version)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return version.Version, nil
+}
+
+// setMigrationVersion 记录已执行的迁移版本
+func setMigrationVersion(version int) error {
+	update := bson.M{"$set": bson.M{"version": version, "updateTime": util.GetMongoTimeNow()}}
+	_, err := MigrationCollection.UpdateOne(context.Background(), bson.M{"_id": migrationVersionId}, update,
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// RunMigrations 依次执行版本高于已记录版本的数据迁移，每个迁移成功后立即记录版本，已执行的迁移不会重复执行。
+// 某个迁移失败时停止执行后续迁移并返回错误，下次启动时从该迁移继续
+func RunMigrations() error {
+	current, err := getMigrationVersion()
+	if err != nil {
+		return fmt.Errorf("查询数据迁移版本失败: %w", err)
+	}
+	for _, migration := range migrations {
+		if migration.version <= current {
+			continue
+		}
+		if err = migration.run(); err != nil {
+			return fmt.Errorf("数据迁移 %d（%s）失败: %w", migration.version, migration.name, err)
+		}
+		if err = setMigrationVersion(migration.version); err != nil {
+			return fmt.Errorf("记录数据迁移版本 %d 失败: %w", migration.version, err)
+		}
+		config.Log.Info("数据迁移完成", zap.Int("version", migration.version), zap.String("name", migration.name))
+	}
+	return nil
+}
@@ -43,16 +43,26 @@ type Order struct {
 	CustomerName     string             `bson:"customerName" json:"customerName"`
 	Phone            string             `bson:"phone" json:"phone"`
 	StartAddress     string             `bson:"startAddress" json:"startAddress"`
-	StartLng         string             `bson:"startLng" json:"startLng"`
-	StartLat         string             `bson:"startLat" json:"startLat"`
+	Start            common.LngLat      `bson:"start" json:"start"` // 起点坐标
 	StartOutletId    string             `bson:"startOutletId" json:"startOutletId"`
 	EndAddress       string             `bson:"endAddress" json:"endAddress"`
-	EndLng           string             `bson:"endLng" json:"endLng"`
-	EndLat           string             `bson:"endLat" json:"endLat"`
+	End              common.LngLat      `bson:"end" json:"end"` // 终点坐标
+	CRS              util.CRS           `bson:"crs" json:"crs"` // 坐标系，入库时统一为 WGS-84
 	EndOutletId      string             `bson:"endOutletId" json:"endOutletId"`
+	CurrentOutletId  string             `bson:"currentOutletId" json:"currentOutletId"` // 经枢纽中转时货物当前所在网点，为空表示尚在起点
+	LegOutletId      string             `bson:"legOutletId" json:"legOutletId"`         // 本段运输的目的网点，与终点网点不同表示送往中转枢纽
 	TransPortVehicle string             `bson:"transPortVehicle" json:"transPortVehicle"`
+	DriverID         string             `bson:"driverId" json:"driverId"`
+	TripID           string             `bson:"tripId" json:"tripId"`
+	RouteID          string             `bson:"routeId" json:"routeId"`
 	Weight           float64            `bson:"weight" json:"weight"`
+	Volume           float64            `bson:"volume" json:"volume"` // 体积，单位为立方米
 	Status           OrderStatus        `bson:"status" json:"status"`
+	PickupTime       primitive.DateTime `bson:"pickupTime,omitempty" json:"pickupTime"`
+	DeliveryTime     primitive.DateTime `bson:"deliveryTime,omitempty" json:"deliveryTime"`
+	ScheduledTime    primitive.DateTime `bson:"scheduledTime,omitempty" json:"scheduledTime"`       // 按时刻表安排的发车时间
+	EstimatedArrival primitive.DateTime `bson:"estimatedArrival,omitempty" json:"estimatedArrival"` // 按时刻表推算的预计到达时间
+	RetryAt          primitive.DateTime `bson:"retryAt,omitempty" json:"retryAt"`                   // 收件已满时自动重新调度的时间
 	CreateTime       primitive.DateTime `bson:"createTime" json:"createTime"`
 	UpdateTime       primitive.DateTime `bson:"updateTime" json:"-"`
 	Remark           string             `bson:"remark" json:"remark"`
@@ -60,12 +70,13 @@ type Order struct {
 
 // FindOrderListDTO 查询订单列表的参数
 type FindOrderListDTO struct {
-	OrderID   string      `json:"orderId"`
-	Phone     string      `json:"phone"`
-	Status    OrderStatus `json:"status"`
-	StartTime time.Time   `json:"startTime"`
-	EndTime   time.Time   `json:"endTime"`
-	Page      common.Page `json:"page"`
+	OrderID   string       `json:"orderId"`
+	Phone     string       `json:"phone"`
+	Status    OrderStatus  `json:"status"`
+	StartTime time.Time    `json:"startTime"`
+	EndTime   time.Time    `json:"endTime"`
+	Page      common.Page  `json:"page"`
+	Scope     *OutletScope `json:"-"` // 当前用户的网点范围，由接口层设置
 }
 
 func (dto *FindOrderListDTO) String() string {
@@ -73,8 +84,14 @@ func (dto *FindOrderListDTO) String() string {
 		dto.OrderID, dto.Phone, dto.Status, dto.StartTime, dto.EndTime, dto.Page.String())
 }
 
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FindOrderListDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
+}
+
 // InsertOrder 新建订单
 func InsertOrder(order *Order) error {
+	order.CRS = util.StorageCRS
 	// 填充时间
 	order.CreateTime = util.GetMongoTimeNow()
 	order.UpdateTime = util.GetMongoTimeNow()
@@ -106,15 +123,61 @@ func CompleteDataOrder(order *Order) error {
 			"status":           Processing,
 			"startOutletId":    order.StartOutletId,
 			"endOutletId":      order.EndOutletId,
+			"legOutletId":      order.LegOutletId,
 			"transPortVehicle": order.TransPortVehicle,
+			"driverId":         order.DriverID,
+			"tripId":           order.TripID,
+			"routeId":          order.RouteID,
+			"scheduledTime":    order.ScheduledTime,
+			"estimatedArrival": order.EstimatedArrival,
 			"updateTime":       util.GetMongoTimeNow(),
 			"remark":           order.Remark,
 		},
+		"$unset": bson.M{"retryAt": ""},
 	}
 	_, err := OrderCollection.UpdateOne(context.Background(), filter, update)
 	return err
 }
 
+// ScheduleOrderRetry 记录待处理订单自动重新调度的时间
+func ScheduleOrderRetry(orderId string, retryAt time.Time) error {
+	filter := bson.M{"orderId": orderId, "status": Pending}
+	update := bson.M{"$set": bson.M{
+		"retryAt":    primitive.NewDateTimeFromTime(retryAt),
+		"updateTime": util.GetMongoTimeNow(),
+	}}
+	_, err := OrderCollection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// ClaimDueOrderRetries 领取到期需要重新调度的待处理订单，领取时清除重新调度时间，多个实例并发领取时每个订单只会被领取一次
+func ClaimDueOrderRetries(now time.Time) ([]string, error) {
+	filter := bson.M{"status": Pending, "retryAt": bson.M{"$lte": primitive.NewDateTimeFromTime(now)}}
+	findOptions := options.Find().SetProjection(bson.M{"orderId": 1, "retryAt": 1})
+	cursor, err := OrderCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var orders []*Order
+	if err = cursor.All(context.Background(), &orders); err != nil {
+		return nil, err
+	}
+	orderIds := make([]string, 0, len(orders))
+	for _, order := range orders {
+		result, err := OrderCollection.UpdateOne(context.Background(),
+			bson.M{"orderId": order.OrderID, "status": Pending, "retryAt": order.RetryAt},
+			bson.M{"$unset": bson.M{"retryAt": ""}})
+		if err != nil {
+			return orderIds, err
+		}
+		if result.ModifiedCount > 0 {
+			orderIds = append(orderIds, order.OrderID)
+		}
+	}
+	return orderIds, nil
+}
+
 // DeleteOrder 删除订单
 func DeleteOrder(orderId string) error {
 	filter := bson.M{"orderId": orderId}
@@ -155,7 +218,7 @@ func GetOrderList(dto FindOrderListDTO) (orders []*Order, err error) {
 	findOptions.SetLimit(int64(dto.Page.Limit))
 	findOptions.SetSort(bson.M{"updateTime": -1})
 
-	cursor, err := OrderCollection.Find(context.Background(), filter, findOptions)
+	cursor, err := OrderCollection.Find(context.Background(), dto.Scope.ScopeOrders(filter), findOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -196,7 +259,7 @@ func GetOrderTotalCount(dto FindOrderListDTO) (count int64, err error) {
 		}
 		filter["createTime"] = timeFilter
 	}
-	documents, err := OrderCollection.CountDocuments(context.Background(), filter)
+	documents, err := OrderCollection.CountDocuments(context.Background(), dto.Scope.ScopeOrders(filter))
 	if err != nil {
 		return
 	}
@@ -243,6 +306,155 @@ func GetOrderById(orderId string) (*Order, error) {
 	return &order, nil
 }
 
+// GetInFlightOrdersByOutlet 查询起点或终点为该网点、尚未完成的订单
+func GetInFlightOrdersByOutlet(outletId string) ([]*Order, error) {
+	filter := bson.M{
+		"status": bson.M{"$in": bson.A{Pending, Processing}},
+		"$or": []bson.M{
+			{"startOutletId": outletId},
+			{"endOutletId": outletId},
+			{"currentOutletId": outletId},
+			{"legOutletId": outletId},
+		},
+	}
+	return findOrders(filter)
+}
+
+// GetInFlightOrdersByRoute 查询走该线路、尚未完成的订单；
+// 未记录线路的历史订单按运输车辆匹配
+func GetInFlightOrdersByRoute(routeId string, plateNumbers []string) ([]*Order, error) {
+	conditions := []bson.M{{"routeId": routeId}}
+	if len(plateNumbers) > 0 {
+		conditions = append(conditions, bson.M{
+			"routeId":          bson.M{"$in": bson.A{"", nil}},
+			"transPortVehicle": bson.M{"$in": plateNumbers},
+		})
+	}
+	filter := bson.M{
+		"status": bson.M{"$in": bson.A{Pending, Processing}},
+		"$or":    conditions,
+	}
+	return findOrders(filter)
+}
+
+func findOrders(filter bson.M) ([]*Order, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"createTime": 1})
+	cursor, err := OrderCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var orders []*Order
+	if err = cursor.All(context.Background(), &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ResetOrderDispatch 撤销订单的调度结果，订单回到待处理状态等待重新调度。
+// 已取件的订单不能撤销。
+func ResetOrderDispatch(orderId string, remark string) error {
+	filter := bson.M{
+		"orderId":    orderId,
+		"status":     bson.M{"$in": bson.A{Pending, Processing}},
+		"pickupTime": bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":           Pending,
+			"legOutletId":      "",
+			"transPortVehicle": "",
+			"driverId":         "",
+			"tripId":           "",
+			"routeId":          "",
+			"remark":           remark,
+			"updateTime":       util.GetMongoTimeNow(),
+		},
+		"$unset": bson.M{
+			"scheduledTime":    "",
+			"estimatedArrival": "",
+		},
+	}
+	result, err := OrderCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("订单已取件或已结束，无法重新调度")
+	}
+	return nil
+}
+
+// IsTransferLeg 判断订单当前这一段是否送往中转枢纽
+func (o *Order) IsTransferLeg() bool {
+	return o.LegOutletId != "" && o.LegOutletId != o.EndOutletId
+}
+
+// GetTransferOrdersByVehicle 获取车辆上本段送往中转枢纽的运输中订单
+func GetTransferOrdersByVehicle(plateNumber string) ([]*Order, error) {
+	filter := bson.M{
+		"transPortVehicle": plateNumber,
+		"status":           Processing,
+		"legOutletId":      bson.M{"$nin": bson.A{"", nil}},
+		"$expr":            bson.M{"$ne": bson.A{"$legOutletId", "$endOutletId"}},
+	}
+	return findOrders(filter)
+}
+
+// MarkVehicleOrdersPickedUp 为车辆上尚未确认取件的订单记录取件时间，调度员完成整车运输时调用
+func MarkVehicleOrdersPickedUp(plateNumber string) error {
+	filter := bson.M{
+		"transPortVehicle": plateNumber,
+		"status":           Processing,
+		"pickupTime":       bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"pickupTime": util.GetMongoTimeNow(),
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	_, err := OrderCollection.UpdateMany(context.Background(), filter, update)
+	return err
+}
+
+// ArriveOrderAtTransfer 订单到达中转枢纽：记录当前所在网点并恢复为待处理，等待下一段调度。订单需已取件
+func ArriveOrderAtTransfer(order *Order, plateNumber string) error {
+	filter := bson.M{
+		"orderId":          order.OrderID,
+		"transPortVehicle": plateNumber,
+		"status":           Processing,
+		"pickupTime":       bson.M{"$exists": true},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":           Pending,
+			"currentOutletId":  order.LegOutletId,
+			"legOutletId":      "",
+			"transPortVehicle": "",
+			"driverId":         "",
+			"tripId":           "",
+			"routeId":          "",
+			"remark":           "已到达中转网点，等待下一段调度",
+			"updateTime":       util.GetMongoTimeNow(),
+		},
+		"$unset": bson.M{
+			"pickupTime":       "",
+			"scheduledTime":    "",
+			"estimatedArrival": "",
+		},
+	}
+	result, err := OrderCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("订单不在当前车辆上或尚未取件")
+	}
+	return nil
+}
+
 // CompleteOrderByVehicle 以车辆为单位完成订单
 func CompleteOrderByVehicle(vehicle *Vehicle) error {
 	filter := bson.M{
@@ -281,3 +493,79 @@ func GetOrderByVehicle(plateNumber string) (order *Order, err error) {
 
 	return &result, nil
 }
+
+// GetProcessingOrdersByVehicle 获取车辆上所有运输中的订单
+func GetProcessingOrdersByVehicle(plateNumber string) (orders []*Order, err error) {
+	filter := bson.M{
+		"transPortVehicle": plateNumber,
+		"status":           Processing,
+	}
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"createTime": 1})
+
+	cursor, err := OrderCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var order Order
+		if err := cursor.Decode(&order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, &order)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ConfirmOrderPickup 司机确认取件
+func ConfirmOrderPickup(orderId string, plateNumber string) error {
+	filter := bson.M{
+		"orderId":          orderId,
+		"transPortVehicle": plateNumber,
+		"status":           Processing,
+		"pickupTime":       bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"pickupTime": util.GetMongoTimeNow(),
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	result, err := OrderCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("订单不在当前车辆上或已取件")
+	}
+	return nil
+}
+
+// ConfirmOrderDelivery 司机确认单个订单送达
+func ConfirmOrderDelivery(orderId string, plateNumber string) error {
+	filter := bson.M{
+		"orderId":          orderId,
+		"transPortVehicle": plateNumber,
+		"status":           Processing,
+		"pickupTime":       bson.M{"$exists": true},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":       Completed,
+			"deliveryTime": util.GetMongoTimeNow(),
+			"updateTime":   util.GetMongoTimeNow(),
+		},
+	}
+	result, err := OrderCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("订单不在当前车辆上或尚未取件")
+	}
+	return nil
+}
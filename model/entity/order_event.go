@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/config"
+	"go_logistics/util"
+)
+
+var OrderEventCollection = config.MongoClient.Database("logistics").Collection("order_event")
+
+// OrderEventType 订单时间线事件类型
+type OrderEventType int
+
+const (
+	OrderEventCreated    OrderEventType = 1 // 下单
+	OrderEventRerouted   OrderEventType = 2 // 改派网点
+	OrderEventDispatched OrderEventType = 3 // 调度车辆
+	OrderEventTransfer   OrderEventType = 4 // 到达中转网点
+	OrderEventInbound    OrderEventType = 5 // 网点入库
+	OrderEventOutbound   OrderEventType = 6 // 网点出库
+	OrderEventCompleted  OrderEventType = 7 // 运输完成
+	OrderEventIntakeFull OrderEventType = 8 // 收件已满，等待重新调度
+)
+
+func (t OrderEventType) String() string {
+	textMap := map[OrderEventType]string{
+		OrderEventCreated:    "下单",
+		OrderEventRerouted:   "改派网点",
+		OrderEventDispatched: "调度车辆",
+		OrderEventTransfer:   "到达中转网点",
+		OrderEventInbound:    "网点入库",
+		OrderEventOutbound:   "网点出库",
+		OrderEventCompleted:  "运输完成",
+		OrderEventIntakeFull: "收件已满",
+	}
+	return textMap[t]
+}
+
+// OrderEvent 订单时间线上的一条记录
+type OrderEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID     string             `bson:"orderId" json:"orderId"`
+	Type        OrderEventType     `bson:"type" json:"type"`
+	OutletID    string             `bson:"outletId,omitempty" json:"outletId"`
+	PlateNumber string             `bson:"plateNumber,omitempty" json:"plateNumber"`
+	Operator    string             `bson:"operator,omitempty" json:"operator"`
+	Description string             `bson:"description" json:"description"`
+	CreateTime  primitive.DateTime `bson:"createTime" json:"createTime"`
+}
+
+// InsertOrderEvent 记录订单事件
+func InsertOrderEvent(event *OrderEvent) error {
+	event.CreateTime = util.GetMongoTimeNow()
+	_, err := OrderEventCollection.InsertOne(context.Background(), event)
+	return err
+}
+
+// GetOrderEvents 获取订单时间线，按时间先后排列
+func GetOrderEvents(orderId string) ([]*OrderEvent, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "createTime", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := OrderEventCollection.Find(context.Background(), bson.M{"orderId": orderId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var events []*OrderEvent
+	if err = cursor.All(context.Background(), &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
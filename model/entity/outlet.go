@@ -25,21 +25,58 @@ func (s OutletStatus) String() string {
 	return [...]string{"营业中", "已关闭"}[s-1]
 }
 
+// OutletType 网点类型的枚举
+type OutletType int
+
+const (
+	OutletTypeHub           OutletType = 1 // 区域枢纽
+	OutletTypeSortingCenter OutletType = 2 // 分拣中心
+	OutletTypeStation       OutletType = 3 // 末端网点
+)
+
+func (t OutletType) String() string {
+	textMap := map[OutletType]string{
+		OutletTypeHub:           "区域枢纽",
+		OutletTypeSortingCenter: "分拣中心",
+		OutletTypeStation:       "末端网点",
+	}
+	return textMap[t]
+}
+
+// IsValid 判断网点类型是否合法
+func (t OutletType) IsValid() bool {
+	return t >= OutletTypeHub && t <= OutletTypeStation
+}
+
+// CanBeParent 区域枢纽与分拣中心可以作为其他网点的上级
+func (t OutletType) CanBeParent() bool {
+	return t == OutletTypeHub || t == OutletTypeSortingCenter
+}
+
 type Outlet struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name          string             `bson:"name" json:"name"`
-	Phone         string             `bson:"phone" json:"phone"`
-	Province      string             `bson:"province" json:"province"`
-	City          string             `bson:"city" json:"city"`
-	DetailAddress string             `bson:"detailAddress" json:"detailAddress"`
-	BusinessHours string             `bson:"businessHours" json:"businessHours"`
-	Lng           string             `bson:"lng" json:"lng"`
-	Lat           string             `bson:"lat" json:"lat"`
-	Scope         []common.GeoPoint  `bson:"scope" json:"scope"`
-	Status        OutletStatus       `bson:"status" json:"status"`
-	Remark        string             `bson:"remark" json:"remark"`
-	CreateTime    primitive.DateTime `bson:"createTime" json:"-"`
-	UpdateTime    primitive.DateTime `bson:"updateTime" json:"-"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name            string             `bson:"name" json:"name"`
+	Type            OutletType         `bson:"type" json:"type"`         // 网点类型，历史数据为空时按末端网点处理
+	ParentID        string             `bson:"parentId" json:"parentId"` // 上级枢纽或分拣中心，为空表示没有上级
+	Phone           string             `bson:"phone" json:"phone"`
+	Province        string             `bson:"province" json:"province"`
+	City            string             `bson:"city" json:"city"`
+	DetailAddress   string             `bson:"detailAddress" json:"detailAddress"`
+	BusinessHours   string             `bson:"businessHours" json:"businessHours"` // 营业时间的展示文本
+	Schedule        []BusinessDay      `bson:"schedule" json:"schedule"`           // 结构化的每周营业时间，为空表示全天营业
+	common.LngLat   `bson:",inline"`
+	Scope           []common.GeoPoint  `bson:"scope" json:"scope"`
+	CRS             util.CRS           `bson:"crs" json:"crs"`          // 坐标系，入库时统一为 WGS-84，接口输出时按 crs 参数转换
+	Location        common.GeoPoint    `bson:"location" json:"-"`       // 网点坐标，由 LngLat 生成，建有 2dsphere 索引
+	Area            *common.GeoPolygon `bson:"area,omitempty" json:"-"` // 营业范围多边形，由 Scope 生成，建有 2dsphere 索引
+	Status          OutletStatus       `bson:"status" json:"status"`
+	MaxParcels      int                `bson:"maxParcels" json:"maxParcels"`           // 最大在库件数，为 0 表示不限
+	MaxWeight       float64            `bson:"maxWeight" json:"maxWeight"`             // 最大在库重量（公斤），为 0 表示不限
+	DwellAlertHours int                `bson:"dwellAlertHours" json:"dwellAlertHours"` // 滞留告警阈值（小时），为 0 时使用默认值
+	DailyIntake     int                `bson:"dailyIntake" json:"dailyIntake"`         // 每日最大收件数，为 0 表示不限
+	Remark          string             `bson:"remark" json:"remark"`
+	CreateTime      primitive.DateTime `bson:"createTime" json:"-"`
+	UpdateTime      primitive.DateTime `bson:"updateTime" json:"-"`
 }
 
 // FindOutletListDTO 查询网点列表的参数
@@ -57,6 +94,13 @@ func (dto *FindOutletListDTO) String() string {
 
 // InsertOutlet 新建网点
 func InsertOutlet(outlet *Outlet) error {
+	if err := fillOutletGeometry(outlet); err != nil {
+		return err
+	}
+	if outlet.Type == 0 {
+		outlet.Type = OutletTypeStation
+	}
+	outlet.CRS = util.StorageCRS
 	// 填充时间
 	outlet.CreateTime = util.GetMongoTimeNow()
 	outlet.UpdateTime = util.GetMongoTimeNow()
@@ -72,17 +116,26 @@ func UpdateOutlet(outletId string, outlet *Outlet) error {
 		return fmt.Errorf("invalid outletId: %w", err)
 	}
 
+	if err = fillOutletGeometry(outlet); err != nil {
+		return err
+	}
+
 	// 构建过滤条件
 	filter := bson.M{"_id": objectId}
 	update := bson.M{
 		"$set": bson.M{
 			"name":          outlet.Name,
+			"type":          outlet.Type,
+			"parentId":      outlet.ParentID,
 			"phone":         outlet.Phone,
 			"detailAddress": outlet.DetailAddress,
 			"businessHours": outlet.BusinessHours,
 			"lng":           outlet.Lng,
 			"lat":           outlet.Lat,
 			"scope":         outlet.Scope,
+			"location":      outlet.Location,
+			"area":          outlet.Area,
+			"crs":           util.StorageCRS,
 			"status":        outlet.Status,
 			"remark":        outlet.Remark,
 			"updateTime":    util.GetMongoTimeNow(),
@@ -92,6 +145,53 @@ func UpdateOutlet(outletId string, outlet *Outlet) error {
 	return err
 }
 
+// UpdateOutletSchedule 修改网点的每周营业时间
+func UpdateOutletSchedule(outletId string, schedule []BusinessDay) error {
+	objectId, err := primitive.ObjectIDFromHex(outletId)
+	if err != nil {
+		return fmt.Errorf("invalid outletId: %w", err)
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"schedule":   schedule,
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	result, err := OutletCollection.UpdateOne(context.Background(), bson.M{"_id": objectId}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("网点 %s 不存在", outletId)
+	}
+	return nil
+}
+
+// UpdateOutletCapacity 修改网点的库容、滞留告警阈值与每日收件上限
+func UpdateOutletCapacity(outletId string, maxParcels int, maxWeight float64, dwellAlertHours int, dailyIntake int) error {
+	objectId, err := primitive.ObjectIDFromHex(outletId)
+	if err != nil {
+		return fmt.Errorf("invalid outletId: %w", err)
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"maxParcels":      maxParcels,
+			"maxWeight":       maxWeight,
+			"dwellAlertHours": dwellAlertHours,
+			"dailyIntake":     dailyIntake,
+			"updateTime":      util.GetMongoTimeNow(),
+		},
+	}
+	result, err := OutletCollection.UpdateOne(context.Background(), bson.M{"_id": objectId}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("网点 %s 不存在", outletId)
+	}
+	return nil
+}
+
 // DeleteOutlet 删除网点
 func DeleteOutlet(outletId string) error {
 	route, err := GetRouteByOutletId(outletId)
@@ -101,6 +201,13 @@ func DeleteOutlet(outletId string) error {
 	if route != nil {
 		return fmt.Errorf("该网点存在关联线路！禁止删除")
 	}
+	children, err := GetChildOutlets(outletId)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("该网点存在下级网点！禁止删除")
+	}
 	// 将 outletId 转换为 primitive.ObjectID
 	objectId, err := primitive.ObjectIDFromHex(outletId)
 	if err != nil {
@@ -0,0 +1,126 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+)
+
+// fillOutletGeometry 根据网点坐标与 Scope 生成用于地理索引的 GeoJSON 字段
+func fillOutletGeometry(outlet *Outlet) error {
+	location, err := util.NewGeoPoint(outlet.LngLat)
+	if err != nil {
+		return fmt.Errorf("网点坐标不合法: %w", err)
+	}
+	area, err := util.ScopeToPolygon(outlet.Scope)
+	if err != nil {
+		return fmt.Errorf("网点营业范围不合法: %w", err)
+	}
+	outlet.Location = location
+	outlet.Area = area
+	return nil
+}
+
+// geoPointFilter 构造 $geometry 查询使用的点
+func geoPointFilter(position common.LngLat) bson.M {
+	return bson.M{"type": "Point", "coordinates": bson.A{position.Lng, position.Lat}}
+}
+
+// FindOutletsContainingPoint 查询营业范围包含该点的营业中网点
+func FindOutletsContainingPoint(position common.LngLat) ([]*Outlet, error) {
+	filter := bson.M{
+		"status": OutletStatusOpen,
+		"area": bson.M{
+			"$geoIntersects": bson.M{"$geometry": geoPointFilter(position)},
+		},
+	}
+	cursor, err := OutletCollection.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var outlets []*Outlet
+	if err = cursor.All(context.Background(), &outlets); err != nil {
+		return nil, err
+	}
+	return outlets, nil
+}
+
+// FindNearestOutlets 按距离由近到远查询营业中的网点，maxDistance 单位为米，为 0 时不限距离
+func FindNearestOutlets(position common.LngLat, maxDistance float64, limit int64) ([]*Outlet, error) {
+	near := bson.M{"$geometry": geoPointFilter(position)}
+	if maxDistance > 0 {
+		near["$maxDistance"] = maxDistance
+	}
+	filter := bson.M{
+		"status":   OutletStatusOpen,
+		"location": bson.M{"$near": near},
+	}
+	findOptions := options.Find()
+	findOptions.SetLimit(limit)
+	cursor, err := OutletCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var outlets []*Outlet
+	if err = cursor.All(context.Background(), &outlets); err != nil {
+		return nil, err
+	}
+	return outlets, nil
+}
+
+// BackfillOutletGeo 为历史网点补全 GeoJSON 字段，需在创建 2dsphere 索引前执行
+func BackfillOutletGeo() error {
+	ctx := context.Background()
+	cursor, err := OutletCollection.Find(ctx, bson.M{"location": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	var outlets []*Outlet
+	if err = cursor.All(ctx, &outlets); err != nil {
+		return err
+	}
+	for _, outlet := range outlets {
+		set := bson.M{}
+		if location, err := util.NewGeoPoint(outlet.LngLat); err == nil {
+			set["location"] = location
+		} else {
+			config.Log.Warn("网点坐标不合法，跳过地理索引", zap.String("outletId", outlet.ID.Hex()), zap.Error(err))
+			continue
+		}
+		if area, err := util.ScopeToPolygon(outlet.Scope); err == nil {
+			set["area"] = area
+		} else {
+			config.Log.Warn("网点营业范围不合法，跳过地理索引", zap.String("outletId", outlet.ID.Hex()), zap.Error(err))
+		}
+		if _, err = OutletCollection.UpdateByID(ctx, outlet.ID, bson.M{"$set": set}); err != nil && set["area"] != nil {
+			// 自相交等 2dsphere 不接受的多边形只记录日志，仍然写入网点坐标
+			config.Log.Warn("网点营业范围无法建立地理索引", zap.String("outletId", outlet.ID.Hex()), zap.Error(err))
+			delete(set, "area")
+			_, err = OutletCollection.UpdateByID(ctx, outlet.ID, bson.M{"$set": set})
+		}
+		if err != nil {
+			config.Log.Warn("补全网点地理字段失败", zap.String("outletId", outlet.ID.Hex()), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// EnsureOutletIndexes 创建网点的 2dsphere 索引
+func EnsureOutletIndexes() error {
+	_, err := OutletCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+		{Keys: bson.D{{Key: "area", Value: "2dsphere"}}},
+	})
+	if err != nil {
+		return fmt.Errorf("创建网点地理索引失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// outletHierarchyMaxDepth 网点层级的最大深度，防止历史脏数据形成环时无限查找
+const outletHierarchyMaxDepth = 8
+
+// EffectiveType 返回网点类型，历史数据没有类型时按末端网点处理
+func (o *Outlet) EffectiveType() OutletType {
+	if !o.Type.IsValid() {
+		return OutletTypeStation
+	}
+	return o.Type
+}
+
+// GetChildOutlets 获取直属下级网点
+func GetChildOutlets(parentId string) ([]*Outlet, error) {
+	cursor, err := OutletCollection.Find(context.Background(), bson.M{"parentId": parentId})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var outlets []*Outlet
+	if err = cursor.All(context.Background(), &outlets); err != nil {
+		return nil, err
+	}
+	return outlets, nil
+}
+
+// ValidateOutletHierarchy 校验网点类型与上级：上级必须是枢纽或分拣中心且不能形成环，
+// 末端网点不能有下级。新建网点时 outletId 为空。
+func ValidateOutletHierarchy(outletId string, outletType OutletType, parentId string) error {
+	if !outletType.IsValid() {
+		return fmt.Errorf("网点类型不合法: %d", outletType)
+	}
+	if outletId != "" && !outletType.CanBeParent() {
+		children, err := GetChildOutlets(outletId)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return fmt.Errorf("该网点存在下级网点，不能设为%s", outletType)
+		}
+	}
+	if parentId == "" {
+		return nil
+	}
+	if parentId == outletId {
+		return fmt.Errorf("上级网点不能是网点自身")
+	}
+	parent, err := GetOutletById(parentId)
+	if err != nil {
+		return fmt.Errorf("上级网点 %s 不存在", parentId)
+	}
+	if !parent.EffectiveType().CanBeParent() {
+		return fmt.Errorf("上级网点 %s 是%s，只能选择区域枢纽或分拣中心", parent.Name, parent.EffectiveType())
+	}
+	// 沿上级向上查找，确认不会回到当前网点
+	current := parent
+	for depth := 0; current.ParentID != ""; depth++ {
+		if depth >= outletHierarchyMaxDepth {
+			return fmt.Errorf("网点层级超过%d层", outletHierarchyMaxDepth)
+		}
+		if current.ParentID == outletId {
+			return fmt.Errorf("上级网点 %s 是该网点的下级，不能形成环", parent.Name)
+		}
+		current, err = GetOutletById(current.ParentID)
+		if err != nil {
+			return fmt.Errorf("上级网点 %s 的上级不存在", parent.Name)
+		}
+	}
+	return nil
+}
+
+// ResolveHubId 返回网点所属的区域枢纽：枢纽本身返回自身，否则沿上级查找最近的枢纽，找不到时返回空。
+// outlets 为按 ID 索引的全部网点，避免逐级查询数据库。
+func ResolveHubId(outletId string, outlets map[string]*Outlet) string {
+	current, ok := outlets[outletId]
+	for depth := 0; ok && depth <= outletHierarchyMaxDepth; depth++ {
+		if current.EffectiveType() == OutletTypeHub {
+			return current.ID.Hex()
+		}
+		current, ok = outlets[current.ParentID]
+	}
+	return ""
+}
+
+// OutletFlowStat 按起点、终点、本段目的、当前所在网点与状态分组的订单数
+type OutletFlowStat struct {
+	StartOutletId   string      `bson:"startOutletId"`
+	EndOutletId     string      `bson:"endOutletId"`
+	LegOutletId     string      `bson:"legOutletId"`
+	CurrentOutletId string      `bson:"currentOutletId"`
+	Status          OrderStatus `bson:"status"`
+	Count           int         `bson:"count"`
+}
+
+// GetOutletHierarchy 获取全部网点的层级信息，只包含名称、类型与上级，用于按枢纽汇总
+func GetOutletHierarchy() ([]*Outlet, error) {
+	findOptions := options.Find().SetProjection(bson.M{"name": 1, "type": 1, "parentId": 1})
+	cursor, err := OutletCollection.Find(context.Background(), bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var outlets []*Outlet
+	if err = cursor.All(context.Background(), &outlets); err != nil {
+		return nil, err
+	}
+	return outlets, nil
+}
+
+// GetOutletFlowStats 使用聚合管道统计 [startTime, endTime] 内创建且已确定起点网点的订单，按网点与状态分组计数，只统计网点范围内的订单
+func GetOutletFlowStats(startTime time.Time, endTime time.Time, scope *OutletScope) ([]*OutletFlowStat, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: scope.ScopeOrders(bson.M{
+			"startOutletId": bson.M{"$nin": bson.A{"", nil}},
+			"createTime": bson.M{
+				"$gte": primitive.NewDateTimeFromTime(startTime.UTC()),
+				"$lte": primitive.NewDateTimeFromTime(endTime.UTC()),
+			},
+		})}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"startOutletId":   "$startOutletId",
+				"endOutletId":     "$endOutletId",
+				"legOutletId":     "$legOutletId",
+				"currentOutletId": "$currentOutletId",
+				"status":          "$status",
+			},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$replaceWith", Value: bson.M{"$mergeObjects": bson.A{"$_id", bson.M{"count": "$count"}}}}},
+	}
+	cursor, err := OrderCollection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var stats []*OutletFlowStat
+	if err = cursor.All(context.Background(), &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,308 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+	"sort"
+	"time"
+)
+
+var OutletHolidayCollection = config.MongoClient.Database("logistics").Collection("outlet_holiday")
+
+// holidayDateLayout 节假日日期格式
+const holidayDateLayout = "2006-01-02"
+
+// BusinessInterval 营业时段，结束时间早于开始时间表示跨零点，开始与结束相同表示全天营业
+type BusinessInterval struct {
+	StartTime string `bson:"startTime" json:"startTime"` // HH:mm
+	EndTime   string `bson:"endTime" json:"endTime"`     // HH:mm
+}
+
+// BusinessDay 某个星期几的营业时段，同一天可以有多个时段
+type BusinessDay struct {
+	Weekday   time.Weekday       `bson:"weekday" json:"weekday"`
+	Intervals []BusinessInterval `bson:"intervals" json:"intervals"`
+}
+
+// OutletHoliday 节假日与例外营业日。
+// 指定 OutletID 时只对该网点生效；否则按省、市匹配，省市都为空表示全国。
+type OutletHoliday struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Date       string             `bson:"date" json:"date"` // yyyy-MM-dd
+	OutletID   string             `bson:"outletId" json:"outletId"`
+	Province   string             `bson:"province" json:"province"`
+	City       string             `bson:"city" json:"city"`
+	Closed     bool               `bson:"closed" json:"closed"`       // 全天休息
+	Intervals  []BusinessInterval `bson:"intervals" json:"intervals"` // 不休息时当天的例外营业时段
+	CreateTime primitive.DateTime `bson:"createTime" json:"-"`
+}
+
+// FindOutletHolidayListDTO 查询节假日列表的参数
+type FindOutletHolidayListDTO struct {
+	OutletID  string      `json:"outletId"`
+	Province  string      `json:"province"`
+	City      string      `json:"city"`
+	StartDate string      `json:"startDate"`
+	EndDate   string      `json:"endDate"`
+	Page      common.Page `json:"page"`
+}
+
+// ValidateBusinessIntervals 校验营业时段格式
+func ValidateBusinessIntervals(intervals []BusinessInterval) error {
+	for _, interval := range intervals {
+		if _, err := parseShiftMinutes(interval.StartTime); err != nil {
+			return err
+		}
+		if _, err := parseShiftMinutes(interval.EndTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateSchedule 校验每周营业时间，每个星期几只能出现一次
+func ValidateSchedule(schedule []BusinessDay) error {
+	seen := make(map[time.Weekday]bool)
+	for _, day := range schedule {
+		if day.Weekday < time.Sunday || day.Weekday > time.Saturday {
+			return fmt.Errorf("营业日星期不合法: %d", day.Weekday)
+		}
+		if seen[day.Weekday] {
+			return fmt.Errorf("营业日星期重复: %d", day.Weekday)
+		}
+		seen[day.Weekday] = true
+		if err := ValidateBusinessIntervals(day.Intervals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertOutletHoliday 新建节假日，同一范围同一天只能有一条
+func InsertOutletHoliday(holiday *OutletHoliday) error {
+	if _, err := time.Parse(holidayDateLayout, holiday.Date); err != nil {
+		return fmt.Errorf("日期格式错误: %s", holiday.Date)
+	}
+	if err := ValidateBusinessIntervals(holiday.Intervals); err != nil {
+		return err
+	}
+	filter := bson.M{
+		"date":     holiday.Date,
+		"outletId": holiday.OutletID,
+		"province": holiday.Province,
+		"city":     holiday.City,
+	}
+	count, err := OutletHolidayCollection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%s 已存在相同范围的节假日", holiday.Date)
+	}
+	holiday.CreateTime = util.GetMongoTimeNow()
+	_, err = OutletHolidayCollection.InsertOne(context.Background(), holiday)
+	return err
+}
+
+// DeleteOutletHoliday 删除节假日
+func DeleteOutletHoliday(holidayId string) error {
+	objectId, err := primitive.ObjectIDFromHex(holidayId)
+	if err != nil {
+		return fmt.Errorf("invalid holidayId: %w", err)
+	}
+	_, err = OutletHolidayCollection.DeleteOne(context.Background(), bson.M{"_id": objectId})
+	return err
+}
+
+// GetOutletHolidayList 根据条件查询节假日列表
+func GetOutletHolidayList(dto FindOutletHolidayListDTO) (holidays []*OutletHoliday, err error) {
+	filter := bson.M{}
+	if dto.OutletID != "" {
+		filter["outletId"] = dto.OutletID
+	}
+	if dto.Province != "" {
+		filter["province"] = dto.Province
+	}
+	if dto.City != "" {
+		filter["city"] = dto.City
+	}
+	if dto.StartDate != "" || dto.EndDate != "" {
+		dateFilter := bson.M{}
+		if dto.StartDate != "" {
+			dateFilter["$gte"] = dto.StartDate
+		}
+		if dto.EndDate != "" {
+			dateFilter["$lte"] = dto.EndDate
+		}
+		filter["date"] = dateFilter
+	}
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
+	findOptions.SetLimit(int64(dto.Page.Limit))
+	findOptions.SetSort(bson.M{"date": 1})
+
+	cursor, err := OutletHolidayCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	if err = cursor.All(context.Background(), &holidays); err != nil {
+		return nil, err
+	}
+	return holidays, nil
+}
+
+// OutletCalendar 网点营业日历：每周营业时间叠加适用于该网点的节假日
+type OutletCalendar struct {
+	outlet   *Outlet
+	location *time.Location
+	holidays map[string]*OutletHoliday // 按日期索引，已按优先级合并
+}
+
+// holidayPriority 节假日适用范围的优先级：网点 > 市 > 省 > 全国
+func holidayPriority(holiday *OutletHoliday) int {
+	switch {
+	case holiday.OutletID != "":
+		return 3
+	case holiday.City != "":
+		return 2
+	case holiday.Province != "":
+		return 1
+	}
+	return 0
+}
+
+// LoadOutletCalendar 加载网点在 [from, from+days) 内适用的节假日
+func LoadOutletCalendar(outlet *Outlet, from time.Time, days int) (*OutletCalendar, error) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	if loc == nil {
+		loc = time.Local
+	}
+	calendar := &OutletCalendar{
+		outlet:   outlet,
+		location: loc,
+		holidays: make(map[string]*OutletHoliday),
+	}
+	start := from.In(loc).AddDate(0, 0, -1).Format(holidayDateLayout)
+	end := from.In(loc).AddDate(0, 0, days).Format(holidayDateLayout)
+	filter := bson.M{
+		"date": bson.M{"$gte": start, "$lte": end},
+		"$or": []bson.M{
+			{"outletId": outlet.ID.Hex()},
+			{"outletId": "", "province": "", "city": ""},
+			{"outletId": "", "province": outlet.Province, "city": ""},
+			{"outletId": "", "province": outlet.Province, "city": outlet.City},
+		},
+	}
+	cursor, err := OutletHolidayCollection.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var holidays []*OutletHoliday
+	if err = cursor.All(context.Background(), &holidays); err != nil {
+		return nil, err
+	}
+	for _, holiday := range holidays {
+		current, ok := calendar.holidays[holiday.Date]
+		if !ok || holidayPriority(holiday) > holidayPriority(current) {
+			calendar.holidays[holiday.Date] = holiday
+		}
+	}
+	return calendar, nil
+}
+
+// intervalsOn 返回某天实际生效的营业时段：节假日优先，其次每周营业时间；都没有配置时视为全天营业
+func (c *OutletCalendar) intervalsOn(day time.Time) []BusinessInterval {
+	if holiday, ok := c.holidays[day.Format(holidayDateLayout)]; ok {
+		if holiday.Closed {
+			return nil
+		}
+		return holiday.Intervals
+	}
+	if len(c.outlet.Schedule) == 0 {
+		return []BusinessInterval{{StartTime: "00:00", EndTime: "00:00"}}
+	}
+	for _, businessDay := range c.outlet.Schedule {
+		if businessDay.Weekday == day.Weekday() {
+			return businessDay.Intervals
+		}
+	}
+	return nil
+}
+
+// IsOpenAt 判断网点在指定时间是否营业
+func (c *OutletCalendar) IsOpenAt(t time.Time) bool {
+	local := t.In(c.location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location)
+	minutes := local.Hour()*60 + local.Minute()
+	for _, interval := range c.intervalsOn(today) {
+		start, end, err := parseInterval(interval)
+		if err != nil {
+			continue
+		}
+		switch {
+		case start == end:
+			return true
+		case start < end && minutes >= start && minutes < end:
+			return true
+		case start > end && minutes >= start:
+			return true
+		}
+	}
+	// 前一天跨零点的时段延续到今天
+	for _, interval := range c.intervalsOn(today.AddDate(0, 0, -1)) {
+		start, end, err := parseInterval(interval)
+		if err == nil && start > end && minutes < end {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpenTime 返回 t 及之后最近的营业时间，horizonDays 天内都不营业时返回 false
+func (c *OutletCalendar) NextOpenTime(t time.Time, horizonDays int) (time.Time, bool) {
+	if c.IsOpenAt(t) {
+		return t, true
+	}
+	local := t.In(c.location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location)
+	for offset := 0; offset <= horizonDays; offset++ {
+		day := today.AddDate(0, 0, offset)
+		var starts []time.Time
+		for _, interval := range c.intervalsOn(day) {
+			start, _, err := parseInterval(interval)
+			if err != nil {
+				continue
+			}
+			startAt := day.Add(time.Duration(start) * time.Minute)
+			if !startAt.Before(t) {
+				starts = append(starts, startAt)
+			}
+		}
+		if len(starts) > 0 {
+			sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+			return starts[0], true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseInterval(interval BusinessInterval) (int, int, error) {
+	start, err := parseShiftMinutes(interval.StartTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseShiftMinutes(interval.EndTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
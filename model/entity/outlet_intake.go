@@ -0,0 +1,148 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/config"
+	"go_logistics/util"
+	"slices"
+	"time"
+)
+
+var OutletIntakeCollection = config.MongoClient.Database("logistics").Collection("outlet_intake")
+
+// outletIntakeTTL 收件计数的保留时长
+const outletIntakeTTL = 30 * 24 * time.Hour
+
+// OutletIntake 网点某天（北京时间）的收件计数，订单在选择收件网点时原子地占用名额
+type OutletIntake struct {
+	OutletID   string             `bson:"outletId" json:"outletId"`
+	Date       string             `bson:"date" json:"date"` // yyyy-MM-dd
+	Count      int                `bson:"count" json:"count"`
+	OrderIDs   []string           `bson:"orderIds" json:"orderIds"` // 占用名额的订单
+	CreateTime primitive.DateTime `bson:"createTime" json:"createTime"`
+	UpdateTime primitive.DateTime `bson:"updateTime" json:"updateTime"`
+}
+
+// EnsureOutletIntakeIndexes 创建收件计数索引
+func EnsureOutletIntakeIndexes() error {
+	_, err := OutletIntakeCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "outletId", Value: 1}, {Key: "date", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "orderIds", Value: 1}}},
+		{Keys: bson.D{{Key: "createTime", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(outletIntakeTTL.Seconds()))},
+	})
+	if err != nil {
+		return fmt.Errorf("创建收件计数索引失败: %w", err)
+	}
+	return nil
+}
+
+// intakeDay 返回 day 所在的北京时间日期与当天零点
+func intakeDay(day time.Time) (string, time.Time) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	if loc == nil {
+		loc = time.Local
+	}
+	local := day.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return start.Format("2006-01-02"), start
+}
+
+// initOutletIntake 当天第一次收件时按已有订单初始化计数，兼容启用计数之前已收件的订单
+func initOutletIntake(outletId string, date string, start time.Time) error {
+	filter := bson.M{
+		"startOutletId": outletId,
+		"status":        bson.M{"$ne": Cancelled},
+		"createTime": bson.M{
+			"$gte": primitive.NewDateTimeFromTime(start),
+			"$lt":  primitive.NewDateTimeFromTime(start.AddDate(0, 0, 1)),
+		},
+	}
+	values, err := OrderCollection.Distinct(context.Background(), "orderId", filter)
+	if err != nil {
+		return err
+	}
+	orderIds := make([]string, 0, len(values))
+	for _, value := range values {
+		if orderId, ok := value.(string); ok {
+			orderIds = append(orderIds, orderId)
+		}
+	}
+	now := util.GetMongoTimeNow()
+	update := bson.M{"$setOnInsert": bson.M{
+		"count":      len(orderIds),
+		"orderIds":   orderIds,
+		"createTime": now,
+		"updateTime": now,
+	}}
+	_, err = OutletIntakeCollection.UpdateOne(context.Background(), bson.M{"outletId": outletId, "date": date}, update,
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// 并发初始化时由另一个请求完成
+		return nil
+	}
+	return err
+}
+
+// ReserveOutletIntake 原子地为订单占用网点 day 当天的收件名额，limit 为每日上限。
+// 名额已满时返回 false；订单已占用该网点当天的名额时直接返回 true，占用成功后释放订单在其他网点或日期占用的名额
+func ReserveOutletIntake(outletId string, day time.Time, orderId string, limit int) (bool, error) {
+	date, start := intakeDay(day)
+	filter := bson.M{"outletId": outletId, "date": date}
+	var intake OutletIntake
+	err := OutletIntakeCollection.FindOne(context.Background(), filter).Decode(&intake)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = initOutletIntake(outletId, date, start)
+	}
+	if err != nil {
+		return false, err
+	}
+	if slices.Contains(intake.OrderIDs, orderId) {
+		return true, nil
+	}
+	reserveFilter := bson.M{
+		"outletId": outletId,
+		"date":     date,
+		"count":    bson.M{"$lt": limit},
+		"orderIds": bson.M{"$ne": orderId},
+	}
+	update := bson.M{
+		"$inc":  bson.M{"count": 1},
+		"$push": bson.M{"orderIds": orderId},
+		"$set":  bson.M{"updateTime": util.GetMongoTimeNow()},
+	}
+	result, err := OutletIntakeCollection.UpdateOne(context.Background(), reserveFilter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 0 {
+		// 名额已满，或初始化时已包含该订单
+		count, err := OutletIntakeCollection.CountDocuments(context.Background(), bson.M{"outletId": outletId, "date": date, "orderIds": orderId})
+		return count > 0, err
+	}
+	releaseFilter := bson.M{
+		"orderIds": orderId,
+		"$or":      bson.A{bson.M{"outletId": bson.M{"$ne": outletId}}, bson.M{"date": bson.M{"$ne": date}}},
+	}
+	return true, releaseOutletIntake(releaseFilter, orderId)
+}
+
+// ReleaseOutletIntake 释放订单占用的收件名额，订单取消或删除时调用
+func ReleaseOutletIntake(orderId string) error {
+	return releaseOutletIntake(bson.M{"orderIds": orderId}, orderId)
+}
+
+func releaseOutletIntake(filter bson.M, orderId string) error {
+	update := bson.M{
+		"$inc":  bson.M{"count": -1},
+		"$pull": bson.M{"orderIds": orderId},
+		"$set":  bson.M{"updateTime": util.GetMongoTimeNow()},
+	}
+	_, err := OutletIntakeCollection.UpdateMany(context.Background(), filter, update)
+	return err
+}
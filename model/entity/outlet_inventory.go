@@ -0,0 +1,301 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+	"time"
+)
+
+var (
+	OutletScanCollection      = config.MongoClient.Database("logistics").Collection("outlet_scan")
+	OutletInventoryCollection = config.MongoClient.Database("logistics").Collection("outlet_inventory")
+	OutletAlertCollection     = config.MongoClient.Database("logistics").Collection("outlet_alert")
+)
+
+// DefaultDwellAlertHours 网点未配置滞留告警阈值时使用的默认值（小时）
+const DefaultDwellAlertHours = 24
+
+// ScanType 网点扫描类型
+type ScanType int
+
+const (
+	ScanInbound  ScanType = 1 // 入库扫描
+	ScanOutbound ScanType = 2 // 出库扫描
+)
+
+func (t ScanType) String() string {
+	textMap := map[ScanType]string{
+		ScanInbound:  "入库扫描",
+		ScanOutbound: "出库扫描",
+	}
+	return textMap[t]
+}
+
+// OutletScan 网点扫描记录
+type OutletScan struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type        ScanType           `bson:"type" json:"type"`
+	OrderID     string             `bson:"orderId" json:"orderId"`
+	OutletID    string             `bson:"outletId" json:"outletId"`
+	PlateNumber string             `bson:"plateNumber,omitempty" json:"plateNumber"` // 出库时装载的车辆
+	Operator    string             `bson:"operator" json:"operator"`
+	CreateTime  primitive.DateTime `bson:"createTime" json:"createTime"`
+}
+
+// InventoryItem 网点当前在库的包裹，入库时新建，出库时删除，每个订单同一时间只能在一个网点
+type InventoryItem struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID     string             `bson:"orderId" json:"orderId"`
+	OutletID    string             `bson:"outletId" json:"outletId"`
+	Weight      float64            `bson:"weight" json:"weight"`
+	Volume      float64            `bson:"volume" json:"volume"`
+	InboundTime primitive.DateTime `bson:"inboundTime" json:"inboundTime"`
+	Alerted     bool               `bson:"alerted" json:"alerted"` // 是否已触发滞留告警
+}
+
+// InventoryAlert 包裹在网点滞留超过阈值的告警
+type InventoryAlert struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OutletID       string             `bson:"outletId" json:"outletId"`
+	OrderID        string             `bson:"orderId" json:"orderId"`
+	InboundTime    primitive.DateTime `bson:"inboundTime" json:"inboundTime"`
+	ThresholdHours int                `bson:"thresholdHours" json:"thresholdHours"`
+	Acknowledged   bool               `bson:"acknowledged" json:"acknowledged"`
+	AckUser        string             `bson:"ackUser,omitempty" json:"ackUser"`
+	AckTime        primitive.DateTime `bson:"ackTime,omitempty" json:"ackTime"`
+	CreateTime     primitive.DateTime `bson:"createTime" json:"createTime"`
+}
+
+// FindInventoryAlertListDTO 查询滞留告警的参数
+type FindInventoryAlertListDTO struct {
+	OutletID     string       `json:"outletId"`
+	Acknowledged *bool        `json:"acknowledged"`
+	Page         common.Page  `json:"page"`
+	Scope        *OutletScope `json:"-"` // 当前用户的网点范围，由接口层设置
+}
+
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FindInventoryAlertListDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
+}
+
+// InventoryUsage 网点在库汇总
+type InventoryUsage struct {
+	OutletID    string  `bson:"_id" json:"outletId"`
+	ParcelCount int     `bson:"parcelCount" json:"parcelCount"`
+	TotalWeight float64 `bson:"totalWeight" json:"totalWeight"`
+	TotalVolume float64 `bson:"totalVolume" json:"totalVolume"`
+}
+
+// EffectiveDwellAlertHours 返回网点的滞留告警阈值，未配置时使用默认值
+func (o *Outlet) EffectiveDwellAlertHours() int {
+	if o.DwellAlertHours <= 0 {
+		return DefaultDwellAlertHours
+	}
+	return o.DwellAlertHours
+}
+
+// InsertOutletScan 新建扫描记录
+func InsertOutletScan(scan *OutletScan) error {
+	scan.CreateTime = util.GetMongoTimeNow()
+	_, err := OutletScanCollection.InsertOne(context.Background(), scan)
+	return err
+}
+
+// GetOutletScansByOrder 获取订单的全部扫描记录，按时间先后排列
+func GetOutletScansByOrder(orderId string) ([]*OutletScan, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"createTime": 1})
+	cursor, err := OutletScanCollection.Find(context.Background(), bson.M{"orderId": orderId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var scans []*OutletScan
+	if err = cursor.All(context.Background(), &scans); err != nil {
+		return nil, err
+	}
+	return scans, nil
+}
+
+// GetInventoryItemByOrder 查询订单当前所在的网点库存，不在任何网点时返回 nil
+func GetInventoryItemByOrder(orderId string) (*InventoryItem, error) {
+	var item InventoryItem
+	err := OutletInventoryCollection.FindOne(context.Background(), bson.M{"orderId": orderId}).Decode(&item)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// InsertInventoryItem 包裹入库，订单已在其他网点库存中时返回错误
+func InsertInventoryItem(item *InventoryItem) error {
+	item.InboundTime = util.GetMongoTimeNow()
+	_, err := OutletInventoryCollection.InsertOne(context.Background(), item)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("订单 %s 已在网点库存中，请先出库", item.OrderID)
+	}
+	return err
+}
+
+// DeleteInventoryItem 包裹出库
+func DeleteInventoryItem(orderId string, outletId string) error {
+	result, err := OutletInventoryCollection.DeleteOne(context.Background(), bson.M{"orderId": orderId, "outletId": outletId})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("订单 %s 不在该网点库存中", orderId)
+	}
+	return nil
+}
+
+// GetOutletInventory 获取网点在库包裹，按入库时间先后排列
+func GetOutletInventory(outletId string) ([]*InventoryItem, error) {
+	return findInventoryItems(bson.M{"outletId": outletId})
+}
+
+// GetUnalertedInventory 获取尚未触发滞留告警的在库包裹
+func GetUnalertedInventory() ([]*InventoryItem, error) {
+	return findInventoryItems(bson.M{"alerted": false})
+}
+
+func findInventoryItems(filter bson.M) ([]*InventoryItem, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"inboundTime": 1})
+	cursor, err := OutletInventoryCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var items []*InventoryItem
+	if err = cursor.All(context.Background(), &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetInventoryUsage 按网点汇总在库件数、重量与体积，outletIds 为空时汇总全部网点
+func GetInventoryUsage(outletIds []string) ([]*InventoryUsage, error) {
+	match := bson.M{}
+	if len(outletIds) > 0 {
+		match["outletId"] = bson.M{"$in": outletIds}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":         "$outletId",
+			"parcelCount": bson.M{"$sum": 1},
+			"totalWeight": bson.M{"$sum": "$weight"},
+			"totalVolume": bson.M{"$sum": "$volume"},
+		}}},
+	}
+	cursor, err := OutletInventoryCollection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var usages []*InventoryUsage
+	if err = cursor.All(context.Background(), &usages); err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// RaiseInventoryAlert 记录滞留告警并标记包裹已告警，同一包裹在一个网点只告警一次
+func RaiseInventoryAlert(item *InventoryItem, thresholdHours int) (*InventoryAlert, error) {
+	result, err := OutletInventoryCollection.UpdateOne(context.Background(),
+		bson.M{"_id": item.ID, "alerted": false},
+		bson.M{"$set": bson.M{"alerted": true}})
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount == 0 {
+		return nil, nil
+	}
+	alert := &InventoryAlert{
+		OutletID:       item.OutletID,
+		OrderID:        item.OrderID,
+		InboundTime:    item.InboundTime,
+		ThresholdHours: thresholdHours,
+		CreateTime:     util.GetMongoTimeNow(),
+	}
+	_, err = OutletAlertCollection.InsertOne(context.Background(), alert)
+	return alert, err
+}
+
+// AcknowledgeInventoryAlert 确认滞留告警
+func AcknowledgeInventoryAlert(alertId string, user string) error {
+	objectId, err := primitive.ObjectIDFromHex(alertId)
+	if err != nil {
+		return fmt.Errorf("invalid alertId: %w", err)
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"acknowledged": true,
+			"ackUser":      user,
+			"ackTime":      util.GetMongoTimeNow(),
+		},
+	}
+	result, err := OutletAlertCollection.UpdateOne(context.Background(), bson.M{"_id": objectId, "acknowledged": false}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("告警不存在或已确认")
+	}
+	return nil
+}
+
+// GetInventoryAlertList 根据条件查询滞留告警
+func GetInventoryAlertList(dto FindInventoryAlertListDTO) (alerts []*InventoryAlert, err error) {
+	filter := bson.M{}
+	if dto.OutletID != "" {
+		filter["outletId"] = dto.OutletID
+	}
+	if dto.Acknowledged != nil {
+		filter["acknowledged"] = *dto.Acknowledged
+	}
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
+	findOptions.SetLimit(int64(dto.Page.Limit))
+	findOptions.SetSort(bson.M{"createTime": -1})
+
+	cursor, err := OutletAlertCollection.Find(context.Background(), dto.Scope.ScopeByOutlet(filter, "outletId"), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	if err = cursor.All(context.Background(), &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// DwellDuration 包裹在网点的滞留时长
+func (i *InventoryItem) DwellDuration(now time.Time) time.Duration {
+	return now.Sub(i.InboundTime.Time())
+}
+
+// EnsureInventoryIndexes 创建网点库存的索引，每个订单同一时间只能在一个网点库存中
+func EnsureInventoryIndexes() error {
+	_, err := OutletInventoryCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "orderId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "outletId", Value: 1}, {Key: "inboundTime", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("创建网点库存索引失败: %w", err)
+	}
+	return nil
+}
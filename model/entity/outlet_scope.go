@@ -0,0 +1,311 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/util"
+	"slices"
+)
+
+// OutletScope 用户可见的网点范围。nil 表示不限，管理员以及未分配网点的非网点操作员角色不受限；
+// 网点操作员未分配网点时看不到任何数据
+type OutletScope struct {
+	OutletIDs []string
+}
+
+// ScopedQuery 可按网点范围过滤的列表查询参数
+type ScopedQuery interface {
+	SetOutletScope(scope *OutletScope)
+}
+
+// NewOutletScope 根据用户角色与分配的网点计算可见范围
+func NewOutletScope(role util.Role, outletIds []string) *OutletScope {
+	if role == util.RoleAdmin {
+		return nil
+	}
+	if len(outletIds) == 0 && role != util.RoleOutletOperator {
+		return nil
+	}
+	// 复制为非 nil 切片，空切片作为 $in 条件时不匹配任何记录
+	return &OutletScope{OutletIDs: append([]string{}, outletIds...)}
+}
+
+// HasOutlet 网点是否在范围内
+func (s *OutletScope) HasOutlet(outletId string) bool {
+	return s == nil || slices.Contains(s.OutletIDs, outletId)
+}
+
+// orderCondition 订单的起点、终点、当前所在或本段目的网点在范围内
+func (s *OutletScope) orderCondition() bson.M {
+	outlets := bson.M{"$in": s.OutletIDs}
+	return bson.M{"$or": bson.A{
+		bson.M{"startOutletId": outlets},
+		bson.M{"endOutletId": outlets},
+		bson.M{"currentOutletId": outlets},
+		bson.M{"legOutletId": outlets},
+	}}
+}
+
+// routeCondition 线路的起点或终点网点在范围内
+func (s *OutletScope) routeCondition() bson.M {
+	outlets := bson.M{"$in": s.OutletIDs}
+	return bson.M{"$or": bson.A{
+		bson.M{"startOutlet": outlets},
+		bson.M{"endOutlet": outlets},
+	}}
+}
+
+// vehicleCondition 车辆所属线路经过范围内的网点
+func (s *OutletScope) vehicleCondition() (bson.M, error) {
+	routeIds, err := RouteCollection.Distinct(context.Background(), "routeId", s.routeCondition())
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"routeId": bson.M{"$in": routeIds}}, nil
+}
+
+// ScopeOrders 为订单查询条件追加网点范围
+func (s *OutletScope) ScopeOrders(filter bson.M) bson.M {
+	if s == nil {
+		return filter
+	}
+	return bson.M{"$and": bson.A{filter, s.orderCondition()}}
+}
+
+// ScopeRoutes 为线路查询条件追加网点范围
+func (s *OutletScope) ScopeRoutes(filter bson.M) bson.M {
+	if s == nil {
+		return filter
+	}
+	return bson.M{"$and": bson.A{filter, s.routeCondition()}}
+}
+
+// ScopeVehicles 为车辆查询条件追加网点范围
+func (s *OutletScope) ScopeVehicles(filter bson.M) (bson.M, error) {
+	if s == nil {
+		return filter, nil
+	}
+	condition, err := s.vehicleCondition()
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$and": bson.A{filter, condition}}, nil
+}
+
+// HasOrder 订单是否在范围内，订单不存在时返回 false
+func (s *OutletScope) HasOrder(orderId string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	count, err := OrderCollection.CountDocuments(context.Background(), s.ScopeOrders(bson.M{"orderId": orderId}))
+	return count > 0, err
+}
+
+// HasRoute 线路是否在范围内，线路不存在时返回 false
+func (s *OutletScope) HasRoute(routeId string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	count, err := RouteCollection.CountDocuments(context.Background(), s.ScopeRoutes(bson.M{"routeId": routeId}))
+	return count > 0, err
+}
+
+// HasVehicle 车辆是否在范围内，车辆不存在时返回 false
+func (s *OutletScope) HasVehicle(plateNumber string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	filter, err := s.ScopeVehicles(bson.M{"plateNumber": plateNumber})
+	if err != nil {
+		return false, err
+	}
+	count, err := VehicleCollection.CountDocuments(context.Background(), filter)
+	return count > 0, err
+}
+
+// HasDeliveryPlan 派送计划所属网点是否在范围内，计划不存在时返回 false
+func (s *OutletScope) HasDeliveryPlan(planId string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	objectId, err := primitive.ObjectIDFromHex(planId)
+	if err != nil {
+		return false, nil
+	}
+	filter := bson.M{"_id": objectId, "outletId": bson.M{"$in": s.OutletIDs}}
+	count, err := DeliveryPlanCollection.CountDocuments(context.Background(), filter)
+	return count > 0, err
+}
+
+// HasTrip 趟次所属线路或派送计划是否在范围内，趟次不存在时返回 false
+func (s *OutletScope) HasTrip(tripId string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	objectId, err := primitive.ObjectIDFromHex(tripId)
+	if err != nil {
+		return false, nil
+	}
+	var trip Trip
+	findOptions := options.FindOne().SetProjection(bson.M{"routeId": 1, "deliveryPlanId": 1})
+	err = TripCollection.FindOne(context.Background(), bson.M{"_id": objectId}, findOptions).Decode(&trip)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if trip.DeliveryPlanID != "" {
+		return s.HasDeliveryPlan(trip.DeliveryPlanID)
+	}
+	return s.HasRoute(trip.RouteID)
+}
+
+// ScopeByOutlet 为按网点归属的记录（库存、告警等）的查询条件追加网点范围，field 为记录所属网点的字段
+func (s *OutletScope) ScopeByOutlet(filter bson.M, field string) bson.M {
+	if s == nil {
+		return filter
+	}
+	return bson.M{"$and": bson.A{filter, bson.M{field: bson.M{"$in": s.OutletIDs}}}}
+}
+
+// plateNumbers 范围内车辆的车牌号
+func (s *OutletScope) plateNumbers() ([]any, error) {
+	condition, err := s.vehicleCondition()
+	if err != nil {
+		return nil, err
+	}
+	return VehicleCollection.Distinct(context.Background(), "plateNumber", condition)
+}
+
+// tripCondition 末端派送趟次按派送计划所属网点，干线趟次按所属线路判断是否在范围内，与 HasTrip 一致
+func (s *OutletScope) tripCondition() (bson.M, error) {
+	routeIds, err := RouteCollection.Distinct(context.Background(), "routeId", s.routeCondition())
+	if err != nil {
+		return nil, err
+	}
+	planIds, err := DeliveryPlanCollection.Distinct(context.Background(), "_id", bson.M{"outletId": bson.M{"$in": s.OutletIDs}})
+	if err != nil {
+		return nil, err
+	}
+	planHexIds := make([]string, 0, len(planIds))
+	for _, planId := range planIds {
+		if objectId, ok := planId.(primitive.ObjectID); ok {
+			planHexIds = append(planHexIds, objectId.Hex())
+		}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"deliveryPlanId": bson.M{"$in": planHexIds}},
+		bson.M{"deliveryPlanId": bson.M{"$in": bson.A{"", nil}}, "routeId": bson.M{"$in": routeIds}},
+	}}, nil
+}
+
+// driverEventCondition 司机事件的车辆或网点在范围内
+func (s *OutletScope) driverEventCondition() (bson.M, error) {
+	plateNumbers, err := s.plateNumbers()
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"plateNumber": bson.M{"$in": plateNumbers}},
+		bson.M{"outletId": bson.M{"$in": s.OutletIDs}},
+	}}, nil
+}
+
+// ScopeTrips 为趟次查询条件追加网点范围
+func (s *OutletScope) ScopeTrips(filter bson.M) (bson.M, error) {
+	if s == nil {
+		return filter, nil
+	}
+	condition, err := s.tripCondition()
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$and": bson.A{filter, condition}}, nil
+}
+
+// ScopeDriverEvents 为司机事件查询条件追加网点范围
+func (s *OutletScope) ScopeDriverEvents(filter bson.M) (bson.M, error) {
+	if s == nil {
+		return filter, nil
+	}
+	condition, err := s.driverEventCondition()
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$and": bson.A{filter, condition}}, nil
+}
+
+// ScopeDriverAssignments 为司机搭档记录查询条件追加网点范围，只保留范围内车辆的记录
+func (s *OutletScope) ScopeDriverAssignments(filter bson.M) (bson.M, error) {
+	if s == nil {
+		return filter, nil
+	}
+	plateNumbers, err := s.plateNumbers()
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$and": bson.A{filter, bson.M{"plateNumber": bson.M{"$in": plateNumbers}}}}, nil
+}
+
+// ScopeFiles 为文件查询条件追加网点范围：司机照片只保留范围内司机事件关联的照片，其余文件不受限
+func (s *OutletScope) ScopeFiles(filter bson.M) (bson.M, error) {
+	if s == nil {
+		return filter, nil
+	}
+	condition, err := s.driverEventCondition()
+	if err != nil {
+		return nil, err
+	}
+	condition = bson.M{"$and": bson.A{condition, bson.M{"fileId": bson.M{"$nin": bson.A{"", nil}}}}}
+	fileIds, err := DriverEventCollection.Distinct(context.Background(), "fileId", condition)
+	if err != nil {
+		return nil, err
+	}
+	photoIds := make([]primitive.ObjectID, 0, len(fileIds))
+	for _, fileId := range fileIds {
+		hexId, _ := fileId.(string)
+		if objectId, err := primitive.ObjectIDFromHex(hexId); err == nil {
+			photoIds = append(photoIds, objectId)
+		}
+	}
+	return bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+		bson.M{"fileType": bson.M{"$ne": DriverPhoto}},
+		bson.M{"_id": bson.M{"$in": photoIds}},
+	}}}}, nil
+}
+
+// HasInventoryAlert 滞留告警所属网点是否在范围内，告警不存在时返回 false
+func (s *OutletScope) HasInventoryAlert(alertId string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	objectId, err := primitive.ObjectIDFromHex(alertId)
+	if err != nil {
+		return false, nil
+	}
+	filter := s.ScopeByOutlet(bson.M{"_id": objectId}, "outletId")
+	count, err := OutletAlertCollection.CountDocuments(context.Background(), filter)
+	return count > 0, err
+}
+
+// HasFile 文件是否在范围内，文件不存在时返回 false
+func (s *OutletScope) HasFile(fileId string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	objectId, err := primitive.ObjectIDFromHex(fileId)
+	if err != nil {
+		return false, nil
+	}
+	filter, err := s.ScopeFiles(bson.M{"_id": objectId})
+	if err != nil {
+		return false, err
+	}
+	count, err := FileCollection.CountDocuments(context.Background(), filter)
+	return count > 0, err
+}
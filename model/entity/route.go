@@ -46,28 +46,37 @@ type Route struct {
 	Status      RouteStatus        `bson:"status" json:"status"`
 	Description string             `bson:"description" json:"description"`
 	Points      []common.GeoPoint  `bson:"points" json:"points"`     // 线路点位的坐标，采用 GeoJSON 格式
+	CRS         util.CRS           `bson:"crs" json:"crs"`           // 坐标系，入库时统一为 WGS-84
 	Distance    float64            `bson:"distance" json:"distance"` // 线路总里程，单位为公里
 	StartOutlet string             `bson:"startOutlet" json:"startOutlet"`
 	EndOutlet   string             `bson:"endOutlet" json:"endOutlet"`
+	Timetable   []RouteDeparture   `bson:"timetable" json:"timetable"` // 班次时刻表，为空表示随时发车
 	CreateTime  primitive.DateTime `bson:"createTime" json:"-"`
 	UpdateTime  primitive.DateTime `bson:"updateTime" json:"-"`
 }
 
 // FindRouteListDTO 查询线路列表的参数
 type FindRouteListDTO struct {
-	RouteID string      `json:"routeId"`
-	Name    string      `json:"name"`
-	Status  RouteStatus `json:"status"`
-	Type    RouteType   `json:"type"`
-	Page    common.Page `json:"page"`
+	RouteID string       `json:"routeId"`
+	Name    string       `json:"name"`
+	Status  RouteStatus  `json:"status"`
+	Type    RouteType    `json:"type"`
+	Page    common.Page  `json:"page"`
+	Scope   *OutletScope `json:"-"` // 当前用户的网点范围，由接口层设置
 }
 
 func (dto *FindRouteListDTO) String() string {
 	return fmt.Sprintf("routeId: %s, name: %s, status: %s, type: %s, page: %s", dto.RouteID, dto.Name, dto.Status, dto.Type, dto.Page.String())
 }
 
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FindRouteListDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
+}
+
 // InsertRoute 新建线路
 func InsertRoute(route *Route) error {
+	route.CRS = util.StorageCRS
 	// 填充时间
 	route.CreateTime = util.GetMongoTimeNow()
 	route.UpdateTime = util.GetMongoTimeNow()
@@ -92,6 +101,7 @@ func UpdateRoute(route *Route) error {
 			"type":        route.Type,
 			"description": route.Description,
 			"points":      route.Points,
+			"crs":         util.StorageCRS,
 			"distance":    route.Distance,
 			"status":      route.Status,
 			"startOutlet": route.StartOutlet,
@@ -103,6 +113,38 @@ func UpdateRoute(route *Route) error {
 	return err
 }
 
+// UpdateRouteDistance 修改线路里程
+func UpdateRouteDistance(routeId string, distance float64) error {
+	filter := bson.M{"routeId": routeId}
+	update := bson.M{
+		"$set": bson.M{
+			"distance":   distance,
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	_, err := RouteCollection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// UpdateRouteTimetable 修改线路时刻表
+func UpdateRouteTimetable(routeId string, timetable []RouteDeparture) error {
+	filter := bson.M{"routeId": routeId}
+	update := bson.M{
+		"$set": bson.M{
+			"timetable":  timetable,
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	result, err := RouteCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("线路 %s 不存在", routeId)
+	}
+	return nil
+}
+
 // DeleteRoute 删除线路
 func DeleteRoute(routeId string) error {
 	vehicles, err := GetVehicleList(FindVehicleListDTO{
@@ -147,7 +189,7 @@ func GetRouteList(dto FindRouteListDTO) (routes []*Route, err error) {
 	findOptions.SetLimit(int64(dto.Page.Limit))
 	findOptions.SetSort(bson.M{"updateTime": -1})
 
-	cursor, err := RouteCollection.Find(context.Background(), filter, findOptions)
+	cursor, err := RouteCollection.Find(context.Background(), dto.Scope.ScopeRoutes(filter), findOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -165,6 +207,26 @@ func GetRouteList(dto FindRouteListDTO) (routes []*Route, err error) {
 	return routes, nil
 }
 
+// EachRoute 按 _id 顺序用同一个游标遍历全部线路，遍历期间修改线路不会导致重复或遗漏
+func EachRoute(fn func(route *Route) error) error {
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := RouteCollection.Find(context.Background(), bson.M{}, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var route Route
+		if err := cursor.Decode(&route); err != nil {
+			return err
+		}
+		if err := fn(&route); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
 // GetRouteTotalCount 获取线路总数
 func GetRouteTotalCount(dto FindRouteListDTO) (count int64, err error) {
 	filter := bson.M{}
@@ -180,7 +242,7 @@ func GetRouteTotalCount(dto FindRouteListDTO) (count int64, err error) {
 	if dto.Status != 0 {
 		filter["status"] = dto.Status
 	}
-	documents, err := RouteCollection.CountDocuments(context.Background(), filter)
+	documents, err := RouteCollection.CountDocuments(context.Background(), dto.Scope.ScopeRoutes(filter))
 	if err != nil {
 		return
 	}
@@ -193,9 +255,11 @@ func GetRouteByOutlets(startOutletID, endOutletID string) ([]*Route, error) {
 		return nil, fmt.Errorf("起点或终点网点ID不能为空")
 	}
 
+	// 调度只使用启用中的线路
 	filter := bson.M{
 		"startOutlet": bson.M{"$regex": startOutletID, "$options": "i"},
 		"endOutlet":   bson.M{"$regex": endOutletID, "$options": "i"},
+		"status":      RouteStatusActive,
 	}
 
 	var routes []*Route
@@ -220,6 +284,26 @@ func GetRouteByOutlets(startOutletID, endOutletID string) ([]*Route, error) {
 	return routes, nil
 }
 
+// GetRoutesByOutletId 查询以该网点为起点或终点的全部线路
+func GetRoutesByOutletId(outletId string) ([]*Route, error) {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"startOutlet": outletId},
+			{"endOutlet": outletId},
+		},
+	}
+	cursor, err := RouteCollection.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var routes []*Route
+	if err = cursor.All(context.Background(), &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
 // GetRouteByOutletId 查询开始网点或结束网点包含指定网点ID的线路
 func GetRouteByOutletId(outletId string) (route *Route, err error) {
 	if outletId == "" {
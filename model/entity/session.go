@@ -0,0 +1,165 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/config"
+	"go_logistics/util"
+	"time"
+)
+
+var SessionCollection = config.MongoClient.Database("logistics").Collection("session")
+
+// ErrRefreshTokenReused 已轮换的刷新 Token 被再次使用，可能已泄露，所在会话会被吊销
+var ErrRefreshTokenReused = errors.New("刷新 Token 已失效，请重新登录")
+
+// maxPreviousHashes 每个会话保留的已轮换刷新 Token 哈希数量
+const maxPreviousHashes = 100
+
+// Session 登录会话，每次刷新轮换刷新 Token，会话ID保持不变
+type Session struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	SessionID      string             `bson:"sessionId" json:"sessionId"`
+	Subject        string             `bson:"subject" json:"subject"` // 后台用户为用户名，司机为司机ID
+	Role           util.Role          `bson:"role" json:"role"`
+	TokenHash      string             `bson:"tokenHash" json:"-"`      // 当前刷新 Token 的哈希
+	PreviousHashes []string           `bson:"previousHashes" json:"-"` // 已轮换的刷新 Token 哈希，用于发现重放
+	IP             string             `bson:"ip" json:"ip"`
+	UserAgent      string             `bson:"userAgent" json:"userAgent"`
+	Revoked        bool               `bson:"revoked" json:"revoked"`
+	RevokeTime     primitive.DateTime `bson:"revokeTime,omitempty" json:"revokeTime"`
+	ExpireTime     primitive.DateTime `bson:"expireTime" json:"expireTime"` // 刷新 Token 过期时间，过期后由 TTL 索引清理
+	CreateTime     primitive.DateTime `bson:"createTime" json:"createTime"`
+	UpdateTime     primitive.DateTime `bson:"updateTime" json:"updateTime"`
+}
+
+// EnsureSessionIndexes 创建会话索引，过期的会话由 TTL 索引自动删除
+func EnsureSessionIndexes() error {
+	_, err := SessionCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sessionId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}},
+		{Keys: bson.D{{Key: "previousHashes", Value: 1}}},
+		{Keys: bson.D{{Key: "subject", Value: 1}, {Key: "role", Value: 1}}},
+		{Keys: bson.D{{Key: "revokeTime", Value: 1}}},
+		{Keys: bson.D{{Key: "expireTime", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return fmt.Errorf("创建会话索引失败: %w", err)
+	}
+	return nil
+}
+
+// InsertSession 新建会话
+func InsertSession(session *Session) error {
+	now := time.Now()
+	session.ExpireTime = primitive.NewDateTimeFromTime(now.Add(util.RefreshTokenTTL))
+	session.CreateTime = primitive.NewDateTimeFromTime(now)
+	session.UpdateTime = session.CreateTime
+	_, err := SessionCollection.InsertOne(context.Background(), session)
+	return err
+}
+
+// RotateSession 使用刷新 Token 换取新的刷新 Token，有效期从本次刷新重新计算。
+// 已轮换的刷新 Token 被再次使用时吊销所在会话并返回 ErrRefreshTokenReused
+func RotateSession(tokenHash string, newHash string) (*Session, error) {
+	now := time.Now()
+	filter := bson.M{
+		"tokenHash":  tokenHash,
+		"revoked":    false,
+		"expireTime": bson.M{"$gt": primitive.NewDateTimeFromTime(now)},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"tokenHash":  newHash,
+			"expireTime": primitive.NewDateTimeFromTime(now.Add(util.RefreshTokenTTL)),
+			"updateTime": primitive.NewDateTimeFromTime(now),
+		},
+		"$push": bson.M{"previousHashes": bson.M{"$each": bson.A{tokenHash}, "$slice": -maxPreviousHashes}},
+	}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var session Session
+	err := SessionCollection.FindOneAndUpdate(context.Background(), filter, update, findOptions).Decode(&session)
+	if err == nil {
+		return &session, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	if err = SessionCollection.FindOne(context.Background(), bson.M{"previousHashes": tokenHash}).Decode(&session); err == nil {
+		if _, err = RevokeSession(session.SessionID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("刷新 Token 无效或已过期")
+	}
+	return nil, err
+}
+
+// RevokeSession 吊销会话，返回吊销时间
+func RevokeSession(sessionId string) (time.Time, error) {
+	now := time.Now()
+	filter := bson.M{"sessionId": sessionId, "revoked": false}
+	update := bson.M{"$set": bson.M{"revoked": true, "revokeTime": primitive.NewDateTimeFromTime(now), "updateTime": primitive.NewDateTimeFromTime(now)}}
+	_, err := SessionCollection.UpdateOne(context.Background(), filter, update)
+	return now, err
+}
+
+// RevokeSubjectSessions 吊销某个账号的全部会话，driver 区分司机与后台用户，exceptSessionId 不为空时保留该会话，
+// 返回被吊销的会话ID
+func RevokeSubjectSessions(subject string, driver bool, exceptSessionId string) ([]string, error) {
+	filter := bson.M{"subject": subject, "revoked": false}
+	if driver {
+		filter["role"] = util.RoleDriver
+	} else {
+		filter["role"] = bson.M{"$ne": util.RoleDriver}
+	}
+	if exceptSessionId != "" {
+		filter["sessionId"] = bson.M{"$ne": exceptSessionId}
+	}
+	sessionIds, err := SessionCollection.Distinct(context.Background(), "sessionId", filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessionIds) == 0 {
+		return nil, nil
+	}
+	now := primitive.NewDateTimeFromTime(time.Now())
+	update := bson.M{"$set": bson.M{"revoked": true, "revokeTime": now, "updateTime": now}}
+	if _, err = SessionCollection.UpdateMany(context.Background(), bson.M{"sessionId": bson.M{"$in": sessionIds}}, update); err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(sessionIds))
+	for _, id := range sessionIds {
+		if sessionId, ok := id.(string); ok {
+			result = append(result, sessionId)
+		}
+	}
+	return result, nil
+}
+
+// GetRevokedSessionsSince 查询某时刻之后吊销的会话，用于同步各实例的吊销列表
+func GetRevokedSessionsSince(since time.Time) (map[string]time.Time, error) {
+	filter := bson.M{"revoked": true, "revokeTime": bson.M{"$gte": primitive.NewDateTimeFromTime(since)}}
+	findOptions := options.Find().SetProjection(bson.M{"sessionId": 1, "revokeTime": 1})
+	cursor, err := SessionCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var sessions []*Session
+	if err = cursor.All(context.Background(), &sessions); err != nil {
+		return nil, err
+	}
+	result := make(map[string]time.Time, len(sessions))
+	for _, session := range sessions {
+		result[session.SessionID] = session.RevokeTime.Time()
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package entity
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RouteDeparture 线路班次：在指定的星期按固定时间发车
+type RouteDeparture struct {
+	Weekdays      []time.Weekday `bson:"weekdays" json:"weekdays"`
+	DepartureTime string         `bson:"departureTime" json:"departureTime"` // 发车时间 HH:mm
+	CutOffTime    string         `bson:"cutOffTime" json:"cutOffTime"`       // 截止装货时间 HH:mm，晚于发车时间表示发车前一天
+	TransitHours  float64        `bson:"transitHours" json:"transitHours"`   // 预计在途时长，单位小时
+}
+
+// ScheduledDeparture 根据时刻表推算出的某一班次
+type ScheduledDeparture struct {
+	RouteID       string    `json:"routeId"`
+	RouteName     string    `json:"routeName"`
+	DepartureTime time.Time `json:"departureTime"`
+	CutOffTime    time.Time `json:"cutOffTime"`
+	ArrivalTime   time.Time `json:"arrivalTime"` // 预计到达时间 = 发车时间 + 在途时长
+}
+
+// ValidateTimetable 校验时刻表数据是否合法
+func ValidateTimetable(timetable []RouteDeparture) error {
+	for i, departure := range timetable {
+		if len(departure.Weekdays) == 0 {
+			return fmt.Errorf("第%d个班次未设置发车日", i+1)
+		}
+		for _, weekday := range departure.Weekdays {
+			if weekday < time.Sunday || weekday > time.Saturday {
+				return fmt.Errorf("第%d个班次发车日不合法: %d", i+1, weekday)
+			}
+		}
+		if _, err := parseShiftMinutes(departure.DepartureTime); err != nil {
+			return err
+		}
+		if _, err := parseShiftMinutes(departure.CutOffTime); err != nil {
+			return err
+		}
+		if departure.TransitHours <= 0 {
+			return fmt.Errorf("第%d个班次在途时长必须大于0", i+1)
+		}
+	}
+	return nil
+}
+
+// UpcomingDepartures 返回 now 之后截止装货时间未过的班次，按发车时间升序，最多 limit 个。
+// 时刻表按北京时间解释，只向后推算一周。
+func (r *Route) UpcomingDepartures(now time.Time, limit int) []*ScheduledDeparture {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	if loc == nil {
+		loc = time.Local
+	}
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	var result []*ScheduledDeparture
+	// 多看一天，保证跨日截止的班次也能被推算到
+	for offset := 0; offset <= 7; offset++ {
+		day := today.AddDate(0, 0, offset)
+		for _, departure := range r.Timetable {
+			if !containsWeekday(departure.Weekdays, day.Weekday()) {
+				continue
+			}
+			departMinutes, err := parseShiftMinutes(departure.DepartureTime)
+			if err != nil {
+				continue
+			}
+			cutOffMinutes, err := parseShiftMinutes(departure.CutOffTime)
+			if err != nil {
+				continue
+			}
+			departAt := day.Add(time.Duration(departMinutes) * time.Minute)
+			cutOffAt := day.Add(time.Duration(cutOffMinutes) * time.Minute)
+			if cutOffMinutes > departMinutes {
+				cutOffAt = cutOffAt.AddDate(0, 0, -1)
+			}
+			if !cutOffAt.After(now) {
+				continue
+			}
+			result = append(result, &ScheduledDeparture{
+				RouteID:       r.RouteID,
+				RouteName:     r.Name,
+				DepartureTime: departAt,
+				CutOffTime:    cutOffAt,
+				ArrivalTime:   departAt.Add(time.Duration(departure.TransitHours * float64(time.Hour))),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DepartureTime.Before(result[j].DepartureTime)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// NextDeparture 返回截止装货时间未过的最近一班，没有时刻表时返回 nil
+func (r *Route) NextDeparture(now time.Time) *ScheduledDeparture {
+	departures := r.UpcomingDepartures(now, 1)
+	if len(departures) == 0 {
+		return nil
+	}
+	return departures[0]
+}
+
+func containsWeekday(weekdays []time.Weekday, weekday time.Weekday) bool {
+	for _, w := range weekdays {
+		if w == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,292 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/util"
+	"time"
+)
+
+var TripCollection = config.MongoClient.Database("logistics").Collection("trip")
+
+// TripStatus 趟次状态
+type TripStatus int
+
+const (
+	TripInProgress TripStatus = 1 // 进行中
+	TripCompleted  TripStatus = 2 // 已完成
+)
+
+func (s TripStatus) String() string {
+	textMap := map[TripStatus]string{
+		TripInProgress: "进行中",
+		TripCompleted:  "已完成",
+	}
+	return textMap[s]
+}
+
+// Trip 车辆的一次运输趟次，从第一单分配开始，到完成运输结束
+type Trip struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PlateNumber    string             `bson:"plateNumber" json:"plateNumber"`
+	VehicleType    VehicleType        `bson:"vehicleType" json:"vehicleType"`
+	RouteID        string             `bson:"routeId" json:"routeId"`
+	RouteName      string             `bson:"routeName" json:"routeName"`
+	DeliveryPlanID string             `bson:"deliveryPlanId,omitempty" json:"deliveryPlanId"` // 末端派送趟次所属的派送计划
+	DriverID       string             `bson:"driverId" json:"driverId"`
+	DriverName     string             `bson:"driverName" json:"driverName"`
+	Status         TripStatus         `bson:"status" json:"status"`
+	OrderIDs       []string           `bson:"orderIds" json:"orderIds"`
+	TotalWeight    float64            `bson:"totalWeight" json:"totalWeight"`               // 趟次累计装载重量
+	PeakLoad       float64            `bson:"peakLoad" json:"peakLoad"`                     // 趟次内车辆最大载重
+	LoadCapacity   float64            `bson:"loadCapacity" json:"loadCapacity"`             // 趟次开始时的核定载重
+	LoadFactor     float64            `bson:"loadFactor" json:"loadFactor"`                 // 满载率 = PeakLoad / LoadCapacity
+	Distance       float64            `bson:"distance" json:"distance"`                     // 行驶里程，单位公里
+	EmptyDistance  float64            `bson:"emptyDistance" json:"emptyDistance"`           // 空驶里程：上一趟终点到本趟起点，单位公里
+	IdleHours      float64            `bson:"idleHours" json:"idleHours"`                   // 上一趟到达至本趟分配的空闲时长，单位小时
+	Cost           float64            `bson:"cost" json:"cost"`                             // 趟次成本（含空驶），单位元
+	Start          common.LngLat      `bson:"start" json:"start"`                           // 趟次开始时的车辆位置
+	End            common.LngLat      `bson:"end" json:"end"`                               // 趟次结束时的车辆位置
+	CRS            util.CRS           `bson:"crs" json:"crs"`                               // 坐标系，入库时统一为 WGS-84
+	AssignTime     primitive.DateTime `bson:"assignTime" json:"assignTime"`                 // 第一单分配时间
+	DepartureTime  primitive.DateTime `bson:"departureTime,omitempty" json:"departureTime"` // 发车时间（首次确认取件）
+	ArrivalTime    primitive.DateTime `bson:"arrivalTime,omitempty" json:"arrivalTime"`     // 到达时间（完成运输）
+	CreateTime     primitive.DateTime `bson:"createTime" json:"-"`
+	UpdateTime     primitive.DateTime `bson:"updateTime" json:"-"`
+}
+
+// FindTripListDTO 查询趟次列表的参数
+type FindTripListDTO struct {
+	PlateNumber string       `json:"plateNumber"`
+	RouteID     string       `json:"routeId"`
+	DriverID    string       `json:"driverId"`
+	Status      TripStatus   `json:"status"`
+	StartTime   time.Time    `json:"startTime"`
+	EndTime     time.Time    `json:"endTime"`
+	Page        common.Page  `json:"page"`
+	Scope       *OutletScope `json:"-"` // 当前用户的网点范围，由接口层设置
+}
+
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FindTripListDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
+}
+
+func (dto *FindTripListDTO) String() string {
+	return fmt.Sprintf("plateNumber: %s, routeId: %s, driverId: %s, status: %d, startTime: %s, endTime: %s, page: %s",
+		dto.PlateNumber, dto.RouteID, dto.DriverID, dto.Status, dto.StartTime, dto.EndTime, dto.Page.String())
+}
+
+// InsertTrip 新建趟次
+func InsertTrip(trip *Trip) error {
+	now := util.GetMongoTimeNow()
+	trip.Status = TripInProgress
+	trip.CRS = util.StorageCRS
+	trip.AssignTime = now
+	trip.CreateTime = now
+	trip.UpdateTime = now
+	if trip.OrderIDs == nil {
+		trip.OrderIDs = make([]string, 0)
+	}
+	result, err := TripCollection.InsertOne(context.Background(), trip)
+	if err != nil {
+		return err
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		trip.ID = id
+	}
+	return nil
+}
+
+// DeleteTrip 删除趟次，用于回滚未能完成下发的派送趟次
+func DeleteTrip(tripId primitive.ObjectID) error {
+	_, err := TripCollection.DeleteOne(context.Background(), bson.M{"_id": tripId})
+	return err
+}
+
+// GetOpenTripByVehicle 获取车辆进行中的趟次，没有时返回 nil, nil
+func GetOpenTripByVehicle(plateNumber string) (*Trip, error) {
+	filter := bson.M{
+		"plateNumber": plateNumber,
+		"status":      TripInProgress,
+	}
+	var trip Trip
+	err := TripCollection.FindOne(context.Background(), filter).Decode(&trip)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trip, nil
+}
+
+// GetLastCompletedTripByVehicle 获取车辆最近一次已完成的趟次，没有时返回 nil, nil
+func GetLastCompletedTripByVehicle(plateNumber string) (*Trip, error) {
+	filter := bson.M{
+		"plateNumber": plateNumber,
+		"status":      TripCompleted,
+	}
+	findOptions := options.FindOne()
+	findOptions.SetSort(bson.M{"arrivalTime": -1})
+	var trip Trip
+	err := TripCollection.FindOne(context.Background(), filter, findOptions).Decode(&trip)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trip, nil
+}
+
+// GetTripById 根据ID获取趟次
+func GetTripById(tripId string) (trip *Trip, err error) {
+	objectId, err := primitive.ObjectIDFromHex(tripId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tripId: %w", err)
+	}
+	err = TripCollection.FindOne(context.Background(), bson.M{"_id": objectId}).Decode(&trip)
+	return
+}
+
+// AddOrderToTrip 将订单加入趟次，并记录趟次内的最大载重
+func AddOrderToTrip(trip *Trip, orderId string, weight float64, currentLoad float64) error {
+	update := bson.M{
+		"$addToSet": bson.M{"orderIds": orderId},
+		"$inc":      bson.M{"totalWeight": weight},
+		"$max":      bson.M{"peakLoad": currentLoad},
+		"$set":      bson.M{"updateTime": util.GetMongoTimeNow()},
+	}
+	_, err := TripCollection.UpdateOne(context.Background(), bson.M{"_id": trip.ID}, update)
+	return err
+}
+
+// RemoveOrderFromTrip 将订单从趟次中移除（重新调度时使用）
+func RemoveOrderFromTrip(tripId string, orderId string, weight float64) error {
+	objectId, err := primitive.ObjectIDFromHex(tripId)
+	if err != nil {
+		return fmt.Errorf("invalid tripId: %w", err)
+	}
+	update := bson.M{
+		"$pull": bson.M{"orderIds": orderId},
+		"$inc":  bson.M{"totalWeight": -weight},
+		"$set":  bson.M{"updateTime": util.GetMongoTimeNow()},
+	}
+	_, err = TripCollection.UpdateOne(context.Background(), bson.M{"_id": objectId, "orderIds": orderId}, update)
+	return err
+}
+
+// MarkTripDeparture 记录车辆进行中趟次的发车时间（仅首次生效）
+func MarkTripDeparture(plateNumber string) error {
+	filter := bson.M{
+		"plateNumber":   plateNumber,
+		"status":        TripInProgress,
+		"departureTime": bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"departureTime": util.GetMongoTimeNow(),
+			"updateTime":    util.GetMongoTimeNow(),
+		},
+	}
+	_, err := TripCollection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// CloseTrip 完成趟次，写入到达时间、里程、满载率与成本
+func CloseTrip(trip *Trip, distance float64, end common.LngLat) error {
+	now := util.GetMongoTimeNow()
+	var loadFactor float64
+	if trip.LoadCapacity > 0 {
+		loadFactor = trip.PeakLoad / trip.LoadCapacity
+	}
+	set := bson.M{
+		"status":      TripCompleted,
+		"distance":    distance,
+		"cost":        (distance + trip.EmptyDistance) * trip.VehicleType.CostPerKm(),
+		"end":         end,
+		"loadFactor":  loadFactor,
+		"arrivalTime": now,
+		"updateTime":  now,
+	}
+	// 没有司机确认取件时，以第一单分配时间作为发车时间
+	if trip.DepartureTime == 0 {
+		set["departureTime"] = trip.AssignTime
+	}
+	_, err := TripCollection.UpdateOne(context.Background(), bson.M{"_id": trip.ID}, bson.M{"$set": set})
+	return err
+}
+
+// buildTripFilter 构建趟次查询条件
+func buildTripFilter(dto FindTripListDTO) bson.M {
+	filter := bson.M{}
+	if dto.PlateNumber != "" {
+		filter["plateNumber"] = bson.M{"$regex": dto.PlateNumber, "$options": "i"}
+	}
+	if dto.RouteID != "" {
+		filter["routeId"] = dto.RouteID
+	}
+	if dto.DriverID != "" {
+		filter["driverId"] = dto.DriverID
+	}
+	if dto.Status != 0 {
+		filter["status"] = dto.Status
+	}
+	if !dto.StartTime.IsZero() || !dto.EndTime.IsZero() {
+		timeFilter := bson.M{}
+		if !dto.StartTime.IsZero() {
+			timeFilter["$gte"] = primitive.NewDateTimeFromTime(dto.StartTime.UTC())
+		}
+		if !dto.EndTime.IsZero() {
+			timeFilter["$lte"] = primitive.NewDateTimeFromTime(dto.EndTime.UTC())
+		}
+		filter["assignTime"] = timeFilter
+	}
+	return filter
+}
+
+// GetTripList 根据条件查询趟次列表
+func GetTripList(dto FindTripListDTO) (trips []*Trip, err error) {
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
+	findOptions.SetLimit(int64(dto.Page.Limit))
+	findOptions.SetSort(bson.M{"assignTime": -1})
+
+	filter, err := dto.Scope.ScopeTrips(buildTripFilter(dto))
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := TripCollection.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var trip Trip
+		if err := cursor.Decode(&trip); err != nil {
+			return nil, err
+		}
+		trips = append(trips, &trip)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return trips, nil
+}
+
+// GetTripTotalCount 获取趟次总数
+func GetTripTotalCount(dto FindTripListDTO) (count int64, err error) {
+	filter, err := dto.Scope.ScopeTrips(buildTripFilter(dto))
+	if err != nil {
+		return 0, err
+	}
+	return TripCollection.CountDocuments(context.Background(), filter)
+}
@@ -2,10 +2,13 @@ package entity
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
 	"go_logistics/common"
 	"go_logistics/config"
 	"go_logistics/util"
@@ -34,6 +37,8 @@ type User struct {
 	Email      string             `bson:"email" json:"email"`
 	Password   string             `bson:"password" json:"-"`
 	Status     UserStatus         `bson:"status" json:"status"`
+	Role       util.Role          `bson:"role" json:"role"`
+	OutletIDs  []string           `bson:"outletIds" json:"outletIds"` // 分配的网点，非管理员只能看到与这些网点相关的数据
 	Salt       string             `bson:"salt" json:"-"`
 	CreateTime primitive.DateTime `bson:"createTime" json:"-"`
 	UpdateTime primitive.DateTime `bson:"updateTime" json:"-"`
@@ -45,11 +50,12 @@ type FindUserListDTO struct {
 	Phone  string      `json:"phone"`
 	Email  string      `json:"email"`
 	Status UserStatus  `json:"status"`
+	Role   util.Role   `json:"role"`
 	Page   common.Page `json:"page"`
 }
 
 func (dto *FindUserListDTO) String() string {
-	return fmt.Sprintf("name: %s, phone: %s, email: %s, status: %d, page: %s", dto.Name, dto.Phone, dto.Email, dto.Status, dto.Page.String())
+	return fmt.Sprintf("name: %s, phone: %s, email: %s, status: %d, role: %s, page: %s", dto.Name, dto.Phone, dto.Email, dto.Status, dto.Role, dto.Page.String())
 }
 
 // InsertUser 新建用户
@@ -114,6 +120,9 @@ func GetUserList(dto FindUserListDTO) (users []*User, err error) {
 	if dto.Status != 0 {
 		filter["status"] = dto.Status
 	}
+	if dto.Role != "" {
+		filter["role"] = dto.Role
+	}
 	findOptions := options.Find()
 	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
 	findOptions.SetLimit(int64(dto.Page.Limit))
@@ -152,9 +161,112 @@ func GetTotalCount(dto FindUserListDTO) (count int64, err error) {
 	if dto.Status != 0 {
 		filter["status"] = dto.Status
 	}
+	if dto.Role != "" {
+		filter["role"] = dto.Role
+	}
 	documents, err := UserCollection.CountDocuments(context.Background(), filter)
 	if err != nil {
 		return
 	}
 	return documents, nil
 }
+
+// UpdateUserRole 修改用户角色
+func UpdateUserRole(name string, role util.Role) error {
+	update := bson.M{
+		"$set": bson.M{
+			"role":       role,
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	result, err := UserCollection.UpdateOne(context.Background(), bson.M{"name": name, "status": bson.M{"$ne": Deleted}}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("用户 %s 不存在", name)
+	}
+	return nil
+}
+
+// UpdateUserPassword 修改用户密码哈希，新哈希自带盐值，清空旧版 MD5 使用的盐值
+func UpdateUserPassword(name string, password string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"password":   password,
+			"salt":       "",
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	_, err := UserCollection.UpdateOne(context.Background(), bson.M{"name": name}, update)
+	return err
+}
+
+// RehashUserPassword 登录成功后将旧哈希替换为新哈希，仅当库中仍是校验通过的旧哈希时才更新，
+// 避免覆盖期间被修改的密码
+func RehashUserPassword(name string, oldPassword string, password string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"password":   password,
+			"salt":       "",
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	_, err := UserCollection.UpdateOne(context.Background(), bson.M{"name": name, "password": oldPassword}, update)
+	return err
+}
+
+// UpdateUserOutlets 修改用户分配的网点
+func UpdateUserOutlets(name string, outletIds []string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"outletIds":  outletIds,
+			"updateTime": util.GetMongoTimeNow(),
+		},
+	}
+	result, err := UserCollection.UpdateOne(context.Background(), bson.M{"name": name, "status": bson.M{"$ne": Deleted}}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("用户 %s 不存在", name)
+	}
+	return nil
+}
+
+// CountActiveUsersByRole 统计某角色未封禁、未删除的用户数
+func CountActiveUsersByRole(role util.Role) (int64, error) {
+	return UserCollection.CountDocuments(context.Background(), bson.M{"role": role, "status": Active})
+}
+
+// MigrateUserRoles 为引入角色前创建的用户补充权限最小的默认角色，之后由管理员按需调整。
+// 没有可用的管理员时，将最早创建的正常用户设为管理员，避免升级后无人能分配角色
+func MigrateUserRoles() error {
+	ctx := context.Background()
+	roleless := bson.M{"role": bson.M{"$in": bson.A{nil, ""}}}
+	admins, err := CountActiveUsersByRole(util.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("补充用户角色失败: %w", err)
+	}
+	if admins == 0 {
+		var first User
+		findOptions := options.FindOne().SetSort(bson.D{{Key: "createTime", Value: 1}, {Key: "_id", Value: 1}})
+		err = UserCollection.FindOne(ctx, bson.M{"$and": bson.A{roleless, bson.M{"status": Active}}}, findOptions).Decode(&first)
+		if err == nil {
+			if _, err = UserCollection.UpdateByID(ctx, first.ID, bson.M{"$set": bson.M{"role": util.RoleAdmin}}); err != nil {
+				return fmt.Errorf("补充用户角色失败: %w", err)
+			}
+			config.Log.Warn("没有可用的管理员，已将最早创建的用户设为管理员", zap.String("name", first.Name))
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("补充用户角色失败: %w", err)
+		}
+	}
+	result, err := UserCollection.UpdateMany(ctx, roleless, bson.M{"$set": bson.M{"role": util.DefaultUserRole}})
+	if err != nil {
+		return fmt.Errorf("补充用户角色失败: %w", err)
+	}
+	if result.ModifiedCount > 0 {
+		config.Log.Info("已为历史用户补充默认角色", zap.String("role", string(util.DefaultUserRole)), zap.Int64("count", result.ModifiedCount))
+	}
+	return nil
+}
@@ -48,21 +48,33 @@ func (s VehicleType) String() string {
 	return textMap[s]
 }
 
+// vehicleCostPerKm 各车型每公里运行成本（油耗、路桥、折旧等），单位元
+var vehicleCostPerKm = map[VehicleType]float64{
+	Truck:   4.5,
+	Minibus: 2.2,
+	Pickup:  1.8,
+}
+
+// CostPerKm 获取车型每公里运行成本
+func (s VehicleType) CostPerKm() float64 {
+	return vehicleCostPerKm[s]
+}
+
 // Vehicle 车辆结构
 type Vehicle struct {
-	ID           string             `bson:"_id,omitempty" json:"id"`
-	PlateNumber  string             `bson:"plateNumber" json:"plateNumber"`
-	Type         VehicleType        `bson:"type" json:"type"`
-	LoadCapacity float64            `bson:"loadCapacity" json:"loadCapacity"`
-	CurrentLoad  float64            `bson:"currentLoad" json:"currentLoad"`
-	Status       VehicleStatus      `bson:"status" json:"status"`
-	RouteID      string             `bson:"routeId" json:"routeId"`
-	RouteName    string             `bson:"routeName" json:"routeName"`
-	Remarks      string             `bson:"remarks" json:"remarks"`
-	Lng          string             `bson:"lng" json:"lng"`
-	Lat          string             `bson:"lat" json:"lat"`
-	CreateTime   primitive.DateTime `bson:"createTime" json:"-"`
-	UpdateTime   primitive.DateTime `bson:"updateTime" json:"-"`
+	ID            string             `bson:"_id,omitempty" json:"id"`
+	PlateNumber   string             `bson:"plateNumber" json:"plateNumber"`
+	Type          VehicleType        `bson:"type" json:"type"`
+	LoadCapacity  float64            `bson:"loadCapacity" json:"loadCapacity"`
+	CurrentLoad   float64            `bson:"currentLoad" json:"currentLoad"`
+	Status        VehicleStatus      `bson:"status" json:"status"`
+	RouteID       string             `bson:"routeId" json:"routeId"`
+	RouteName     string             `bson:"routeName" json:"routeName"`
+	Remarks       string             `bson:"remarks" json:"remarks"`
+	common.LngLat `bson:",inline"`   // 车辆当前位置，零值表示未知
+	CRS           util.CRS           `bson:"crs" json:"crs"` // 坐标系，入库时统一为 WGS-84
+	CreateTime    primitive.DateTime `bson:"createTime" json:"-"`
+	UpdateTime    primitive.DateTime `bson:"updateTime" json:"-"`
 }
 
 // FindVehicleListDTO 查询车辆列表的参数
@@ -73,6 +85,7 @@ type FindVehicleListDTO struct {
 	RouteID     string        `json:"routeId"`
 	RouteName   string        `json:"routeName"`
 	Page        common.Page   `json:"page"`
+	Scope       *OutletScope  `json:"-"` // 当前用户的网点范围，由接口层设置
 }
 
 func (dto *FindVehicleListDTO) String() string {
@@ -80,6 +93,11 @@ func (dto *FindVehicleListDTO) String() string {
 		dto.PlateNumber, dto.Type, dto.Status, dto.RouteID, dto.RouteName, dto.Page.String())
 }
 
+// SetOutletScope 设置当前用户的网点范围
+func (dto *FindVehicleListDTO) SetOutletScope(scope *OutletScope) {
+	dto.Scope = scope
+}
+
 // InsertVehicle 新建车辆
 func InsertVehicle(vehicle *Vehicle) error {
 	// 检查车牌号是否已存在
@@ -104,6 +122,7 @@ func InsertVehicle(vehicle *Vehicle) error {
 			Name: "",
 		}
 	}
+	vehicle.CRS = util.StorageCRS
 	// 填充时间
 	vehicle.CreateTime = util.GetMongoTimeNow()
 	vehicle.UpdateTime = util.GetMongoTimeNow()
@@ -145,8 +164,7 @@ func UpdateVehicle(vehicle *Vehicle) error {
 			points := route.Points
 			if len(points) > 0 {
 				firstPoint := points[0]
-				vehicle.Lng = fmt.Sprintf("%v", firstPoint.Coordinates[0])
-				vehicle.Lat = fmt.Sprintf("%v", firstPoint.Coordinates[1])
+				vehicle.LngLat = firstPoint.LngLat()
 			}
 		}
 
@@ -163,6 +181,7 @@ func UpdateVehicle(vehicle *Vehicle) error {
 			"remarks":      vehicle.Remarks,
 			"lng":          vehicle.Lng,
 			"lat":          vehicle.Lat,
+			"crs":          util.StorageCRS,
 			"currentLoad":  vehicle.CurrentLoad,
 			"updateTime":   now,
 		},
@@ -191,6 +210,15 @@ func DeleteVehicle(plateNumber string) error {
 	}
 	filter := bson.M{"plateNumber": plateNumber}
 	_, err = VehicleCollection.DeleteOne(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	// 解除该车辆与司机的搭档关系
+	if err = closeDriverAssignments(bson.M{"plateNumber": plateNumber}); err != nil {
+		return err
+	}
+	_, err = DriverCollection.UpdateMany(context.Background(), filter,
+		bson.M{"$set": bson.M{"plateNumber": "", "updateTime": util.GetMongoTimeNow()}})
 	return err
 }
 
@@ -212,6 +240,9 @@ func GetVehicleList(dto FindVehicleListDTO) (vehicles []*Vehicle, err error) {
 	if dto.RouteName != "" {
 		filter["routeName"] = bson.M{"$regex": dto.RouteName, "$options": "i"}
 	}
+	if filter, err = dto.Scope.ScopeVehicles(filter); err != nil {
+		return nil, err
+	}
 	findOptions := options.Find()
 	findOptions.SetSkip(int64((dto.Page.Skip - 1) * dto.Page.Limit))
 	findOptions.SetLimit(int64(dto.Page.Limit))
@@ -253,6 +284,9 @@ func GetVehicleTotalCount(dto FindVehicleListDTO) (count int64, err error) {
 	if dto.RouteName != "" {
 		filter["routeName"] = bson.M{"$regex": dto.RouteName, "$options": "i"}
 	}
+	if filter, err = dto.Scope.ScopeVehicles(filter); err != nil {
+		return
+	}
 	documents, err := VehicleCollection.CountDocuments(context.Background(), filter)
 	if err != nil {
 		return
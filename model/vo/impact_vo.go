@@ -0,0 +1,62 @@
+package vo
+
+import "go_logistics/model/entity"
+
+// ImpactedOrder 受网点关闭或线路停用影响的订单
+type ImpactedOrder struct {
+	OrderID          string             `json:"orderId"`
+	CustomerName     string             `json:"customerName"`
+	Status           entity.OrderStatus `json:"status"`
+	StartOutletId    string             `json:"startOutletId"`
+	EndOutletId      string             `json:"endOutletId"`
+	RouteID          string             `json:"routeId"`
+	TransPortVehicle string             `json:"transPortVehicle"`
+	Weight           float64            `json:"weight"`
+	PickedUp         bool               `json:"pickedUp"`
+	Redispatchable   bool               `json:"redispatchable"` // 未取件的订单可重新调度
+}
+
+// ImpactedVehicle 受网点关闭或线路停用影响的车辆
+type ImpactedVehicle struct {
+	PlateNumber string               `json:"plateNumber"`
+	RouteID     string               `json:"routeId"`
+	RouteName   string               `json:"routeName"`
+	Status      entity.VehicleStatus `json:"status"`
+	CurrentLoad float64              `json:"currentLoad"`
+}
+
+// StatusImpactVO 网点关闭或线路停用的影响报告
+type StatusImpactVO struct {
+	TargetType string            `json:"targetType"` // outlet 或 route
+	TargetID   string            `json:"targetId"`
+	TargetName string            `json:"targetName"`
+	Orders     []ImpactedOrder   `json:"orders"`
+	Vehicles   []ImpactedVehicle `json:"vehicles"`
+}
+
+// ToImpactedOrder 转换为受影响订单
+func ToImpactedOrder(order *entity.Order) ImpactedOrder {
+	return ImpactedOrder{
+		OrderID:          order.OrderID,
+		CustomerName:     order.CustomerName,
+		Status:           order.Status,
+		StartOutletId:    order.StartOutletId,
+		EndOutletId:      order.EndOutletId,
+		RouteID:          order.RouteID,
+		TransPortVehicle: order.TransPortVehicle,
+		Weight:           order.Weight,
+		PickedUp:         order.PickupTime != 0,
+		Redispatchable:   order.PickupTime == 0,
+	}
+}
+
+// ToImpactedVehicle 转换为受影响车辆
+func ToImpactedVehicle(vehicle *entity.Vehicle) ImpactedVehicle {
+	return ImpactedVehicle{
+		PlateNumber: vehicle.PlateNumber,
+		RouteID:     vehicle.RouteID,
+		RouteName:   vehicle.RouteName,
+		Status:      vehicle.Status,
+		CurrentLoad: vehicle.CurrentLoad,
+	}
+}
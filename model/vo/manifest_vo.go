@@ -0,0 +1,36 @@
+package vo
+
+import "go_logistics/model/entity"
+
+// ManifestItem 装载清单中的单个订单
+type ManifestItem struct {
+	Sequence      int                `json:"sequence"`  // 建议装车顺序，1 表示最先装车
+	StopIndex     int                `json:"stopIndex"` // 卸货站点顺序，1 表示最先卸货
+	OrderID       string             `json:"orderId"`
+	CustomerName  string             `json:"customerName"`
+	Phone         string             `json:"phone"`
+	EndAddress    string             `json:"endAddress"`
+	EndOutletId   string             `json:"endOutletId"`
+	EndOutletName string             `json:"endOutletName"`
+	Weight        float64            `json:"weight"`
+	Volume        float64            `json:"volume"`
+	Status        entity.OrderStatus `json:"status"`
+	PickedUp      bool               `json:"pickedUp"`
+}
+
+// ManifestVO 车辆装载清单
+type ManifestVO struct {
+	PlateNumber       string             `json:"plateNumber"`
+	VehicleType       entity.VehicleType `json:"vehicleType"`
+	DriverName        string             `json:"driverName"`
+	DriverPhone       string             `json:"driverPhone"`
+	RouteID           string             `json:"routeId"`
+	RouteName         string             `json:"routeName"`
+	LoadCapacity      float64            `json:"loadCapacity"`
+	CurrentLoad       float64            `json:"currentLoad"`
+	RemainingCapacity float64            `json:"remainingCapacity"`
+	TotalWeight       float64            `json:"totalWeight"`
+	TotalVolume       float64            `json:"totalVolume"`
+	OrderCount        int                `json:"orderCount"`
+	Items             []ManifestItem     `json:"items"`
+}
@@ -2,7 +2,9 @@ package vo
 
 import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go_logistics/common"
 	"go_logistics/model/entity"
+	"go_logistics/util"
 )
 
 type OrderVO struct {
@@ -11,17 +13,22 @@ type OrderVO struct {
 	CustomerName     string             `bson:"customerName" json:"customerName"`
 	Phone            string             `bson:"phone" json:"phone"`
 	StartAddress     string             `bson:"startAddress" json:"startAddress"`
-	StartLng         string             `bson:"startLng" json:"startLng"`
-	StartLat         string             `bson:"startLat" json:"startLat"`
+	Start            common.LngLat      `bson:"start" json:"start"`
 	StartOutlet      entity.Outlet      `bson:"startOutlet" json:"startOutlet"`
 	EndAddress       string             `bson:"endAddress" json:"endAddress"`
-	EndLng           string             `bson:"endLng" json:"endLng"`
-	EndLat           string             `bson:"endLat" json:"endLat"`
+	End              common.LngLat      `bson:"end" json:"end"`
 	EndOutlet        entity.Outlet      `bson:"endOutlet" json:"endOutlet"`
+	CRS              util.CRS           `bson:"crs" json:"crs"`
+	CurrentOutletId  string             `bson:"currentOutletId" json:"currentOutletId"`
+	LegOutletId      string             `bson:"legOutletId" json:"legOutletId"`
 	TransPortVehicle entity.Vehicle     `bson:"transPortVehicle" json:"transPortVehicle"`
+	Driver           entity.Driver      `bson:"driver" json:"driver"`
 	Route            entity.Route       `bson:"route" json:"route"`
 	Weight           float64            `bson:"weight" json:"weight"`
+	Volume           float64            `bson:"volume" json:"volume"`
 	Status           entity.OrderStatus `bson:"status" json:"status"`
+	ScheduledTime    primitive.DateTime `bson:"scheduledTime" json:"scheduledTime"`
+	EstimatedArrival primitive.DateTime `bson:"estimatedArrival" json:"estimatedArrival"`
 	CreateTime       primitive.DateTime `bson:"createTime" json:"createTime"`
 	UpdateTime       primitive.DateTime `bson:"updateTime" json:"-"`
 	Remark           string             `bson:"remark" json:"remark"`
@@ -32,6 +39,7 @@ func ToOrderVO(order *entity.Order) (OrderVO, error) {
 	var endOutlet *entity.Outlet
 	var vehicle *entity.Vehicle
 	var route *entity.Route
+	var driver *entity.Driver
 
 	// 处理开始网点
 	if order.StartOutletId != "" {
@@ -64,9 +72,26 @@ func ToOrderVO(order *entity.Order) (OrderVO, error) {
 		}
 	}
 
-	// 处理线路（依赖车辆）
-	if vehicle != nil && vehicle.RouteID != "" {
-		r, err := entity.GetRouteById(vehicle.RouteID)
+	// 处理司机（优先使用调度时记录的司机，否则取车辆当前搭档司机）
+	if order.DriverID != "" {
+		d, err := entity.GetDriverById(order.DriverID)
+		if err == nil {
+			driver = d
+		}
+	} else if order.TransPortVehicle != "" {
+		d, err := entity.GetDriverByVehicle(order.TransPortVehicle)
+		if err == nil {
+			driver = d
+		}
+	}
+
+	// 处理线路（优先使用调度时记录的线路，否则取车辆当前线路）
+	routeId := order.RouteID
+	if routeId == "" && vehicle != nil {
+		routeId = vehicle.RouteID
+	}
+	if routeId != "" {
+		r, err := entity.GetRouteById(routeId)
 		if err != nil {
 			// log.Printf("获取线路失败: %v", err)
 		} else {
@@ -80,8 +105,7 @@ func ToOrderVO(order *entity.Order) (OrderVO, error) {
 		CustomerName: order.CustomerName,
 		Phone:        order.Phone,
 		StartAddress: order.StartAddress,
-		StartLng:     order.StartLng,
-		StartLat:     order.StartLat,
+		Start:        order.Start,
 		StartOutlet: func() entity.Outlet {
 			if startOutlet != nil {
 				return *startOutlet
@@ -89,35 +113,58 @@ func ToOrderVO(order *entity.Order) (OrderVO, error) {
 			return entity.Outlet{}
 		}(),
 		EndAddress: order.EndAddress,
-		EndLng:     order.EndLng,
-		EndLat:     order.EndLat,
+		End:        order.End,
+		CRS:        order.CRS,
 		EndOutlet: func() entity.Outlet {
 			if endOutlet != nil {
 				return *endOutlet
 			}
 			return entity.Outlet{}
 		}(),
+		CurrentOutletId: order.CurrentOutletId,
+		LegOutletId:     order.LegOutletId,
 		TransPortVehicle: func() entity.Vehicle {
 			if vehicle != nil {
 				return *vehicle
 			}
 			return entity.Vehicle{}
 		}(),
+		Driver: func() entity.Driver {
+			if driver != nil {
+				return *driver
+			}
+			return entity.Driver{}
+		}(),
 		Route: func() entity.Route {
 			if route != nil {
 				return *route
 			}
 			return entity.Route{}
 		}(),
-		Weight:     order.Weight,
-		Status:     order.Status,
-		CreateTime: order.CreateTime,
-		UpdateTime: order.UpdateTime,
-		Remark:     order.Remark,
+		Weight:           order.Weight,
+		Volume:           order.Volume,
+		Status:           order.Status,
+		ScheduledTime:    order.ScheduledTime,
+		EstimatedArrival: order.EstimatedArrival,
+		CreateTime:       order.CreateTime,
+		UpdateTime:       order.UpdateTime,
+		Remark:           order.Remark,
 	}
 	return orderVO, nil
 }
 
+// ConvertCRS 将订单及关联的网点、车辆、线路坐标转换到目标坐标系
+func (o *OrderVO) ConvertCRS(to util.CRS) {
+	from := o.CRS.OrLegacy()
+	o.Start = util.ConvertLngLat(o.Start, from, to)
+	o.End = util.ConvertLngLat(o.End, from, to)
+	o.CRS = to
+	o.StartOutlet.ConvertCRS(to)
+	o.EndOutlet.ConvertCRS(to)
+	o.TransPortVehicle.ConvertCRS(to)
+	o.Route.ConvertCRS(to)
+}
+
 func ToOrderVOList(orders []*entity.Order) ([]OrderVO, error) {
 	orderVOs := make([]OrderVO, 0)
 	for _, order := range orders {
@@ -0,0 +1,41 @@
+package vo
+
+import (
+	"go_logistics/common"
+	"go_logistics/model/entity"
+)
+
+// OutletMapVO 首页地图上的网点，附带上级网点位置与所属枢纽，用于绘制枢纽到下级网点的连线
+type OutletMapVO struct {
+	*entity.Outlet
+	ParentName   string         `json:"parentName"`
+	ParentLngLat *common.LngLat `json:"parentLngLat"` // 上级网点坐标，没有上级时为空
+	HubID        string         `json:"hubId"`        // 所属区域枢纽，枢纽为自身，没有归属时为空
+	ChildCount   int            `json:"childCount"`   // 直属下级网点数
+}
+
+// ToOutletMapVOList 根据上下级关系组装地图网点列表
+func ToOutletMapVOList(outlets []*entity.Outlet) []OutletMapVO {
+	outletMap := make(map[string]*entity.Outlet, len(outlets))
+	childCount := make(map[string]int)
+	for _, outlet := range outlets {
+		outletMap[outlet.ID.Hex()] = outlet
+		if outlet.ParentID != "" {
+			childCount[outlet.ParentID]++
+		}
+	}
+	result := make([]OutletMapVO, 0, len(outlets))
+	for _, outlet := range outlets {
+		outletVO := OutletMapVO{
+			Outlet:     outlet,
+			HubID:      entity.ResolveHubId(outlet.ID.Hex(), outletMap),
+			ChildCount: childCount[outlet.ID.Hex()],
+		}
+		if parent, ok := outletMap[outlet.ParentID]; ok {
+			outletVO.ParentName = parent.Name
+			outletVO.ParentLngLat = &parent.LngLat
+		}
+		result = append(result, outletVO)
+	}
+	return result
+}
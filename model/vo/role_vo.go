@@ -0,0 +1,32 @@
+package vo
+
+import "go_logistics/util"
+
+// RoleVO 角色及其在各接口分组的访问级别
+type RoleVO struct {
+	Role        string            `json:"role"`
+	Name        string            `json:"name"`
+	Assignable  bool              `json:"assignable"` // 能否分配给后台用户
+	Permissions map[string]string `json:"permissions"`
+}
+
+// ToRoleVOList 将全部角色与权限矩阵转换为 RoleVO 列表
+func ToRoleVOList() []RoleVO {
+	roles := make([]RoleVO, 0, len(util.Roles))
+	for _, role := range util.Roles {
+		_, err := util.ParseUserRole(string(role))
+		permissions := make(map[string]string)
+		for group, access := range util.PermissionMatrix {
+			if access[role] > util.AccessNone {
+				permissions[group] = access[role].String()
+			}
+		}
+		roles = append(roles, RoleVO{
+			Role:        string(role),
+			Name:        role.String(),
+			Assignable:  err == nil,
+			Permissions: permissions,
+		})
+	}
+	return roles
+}
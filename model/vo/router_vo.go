@@ -4,21 +4,26 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go_logistics/common"
 	"go_logistics/model/entity"
+	"go_logistics/util"
+	"time"
 )
 
 type RouteVO struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	RouteID     string             `bson:"routeId" json:"routeId"`
-	Name        string             `bson:"name" json:"name"`
-	Type        entity.RouteType   `bson:"type" json:"type"`
-	Status      entity.RouteStatus `bson:"status" json:"status"`
-	Description string             `bson:"description" json:"description"`
-	Points      []common.GeoPoint  `bson:"points" json:"points"`
-	Distance    float64            `bson:"distance" json:"distance"`
-	StartOutlet *entity.Outlet     `bson:"startOutlet" json:"startOutlet"`
-	EndOutlet   *entity.Outlet     `bson:"endOutlet" json:"endOutlet"`
-	CreateTime  primitive.DateTime `bson:"createTime" json:"-"`
-	UpdateTime  primitive.DateTime `bson:"updateTime" json:"-"`
+	ID            primitive.ObjectID         `bson:"_id,omitempty" json:"id"`
+	RouteID       string                     `bson:"routeId" json:"routeId"`
+	Name          string                     `bson:"name" json:"name"`
+	Type          entity.RouteType           `bson:"type" json:"type"`
+	Status        entity.RouteStatus         `bson:"status" json:"status"`
+	Description   string                     `bson:"description" json:"description"`
+	Points        []common.GeoPoint          `bson:"points" json:"points"`
+	CRS           util.CRS                   `bson:"crs" json:"crs"`
+	Distance      float64                    `bson:"distance" json:"distance"`
+	StartOutlet   *entity.Outlet             `bson:"startOutlet" json:"startOutlet"`
+	EndOutlet     *entity.Outlet             `bson:"endOutlet" json:"endOutlet"`
+	Timetable     []entity.RouteDeparture    `bson:"timetable" json:"timetable"`
+	NextDeparture *entity.ScheduledDeparture `bson:"-" json:"nextDeparture"`
+	CreateTime    primitive.DateTime         `bson:"createTime" json:"-"`
+	UpdateTime    primitive.DateTime         `bson:"updateTime" json:"-"`
 }
 
 func ToRouteVO(route *entity.Route) (RouteVO, error) {
@@ -42,21 +47,42 @@ func ToRouteVO(route *entity.Route) (RouteVO, error) {
 	}
 
 	return RouteVO{
-		ID:          route.ID,
-		RouteID:     route.RouteID,
-		Name:        route.Name,
-		Type:        route.Type,
-		Status:      route.Status,
-		Description: route.Description,
-		Points:      route.Points,
-		Distance:    route.Distance,
-		StartOutlet: startOutlet,
-		EndOutlet:   endOutlet,
-		CreateTime:  route.CreateTime,
-		UpdateTime:  route.UpdateTime,
+		ID:            route.ID,
+		RouteID:       route.RouteID,
+		Name:          route.Name,
+		Type:          route.Type,
+		Status:        route.Status,
+		Description:   route.Description,
+		Points:        route.Points,
+		CRS:           route.CRS,
+		Distance:      route.Distance,
+		StartOutlet:   startOutlet,
+		EndOutlet:     endOutlet,
+		Timetable:     route.Timetable,
+		NextDeparture: route.NextDeparture(time.Now()),
+		CreateTime:    route.CreateTime,
+		UpdateTime:    route.UpdateTime,
 	}, nil
 }
 
+// ConvertCRS 将线路点位及起止网点坐标转换到目标坐标系
+func (r *RouteVO) ConvertCRS(to util.CRS) {
+	r.Points = util.ConvertGeoPoints(r.Points, r.CRS.OrLegacy(), to)
+	r.CRS = to
+	for _, outlet := range []*entity.Outlet{r.StartOutlet, r.EndOutlet} {
+		if outlet != nil {
+			outlet.ConvertCRS(to)
+		}
+	}
+}
+
+// ConvertRouteVOListCRS 批量转换线路坐标
+func ConvertRouteVOListCRS(routeVOs []RouteVO, to util.CRS) {
+	for i := range routeVOs {
+		routeVOs[i].ConvertCRS(to)
+	}
+}
+
 func ToRouteVOList(routes []*entity.Route) ([]RouteVO, error) {
 	var routeVOs []RouteVO
 	for _, route := range routes {
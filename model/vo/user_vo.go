@@ -9,12 +9,15 @@ import (
 
 // UserVO User 用户结构
 type UserVO struct {
-	Name       string `json:"name"`
-	Phone      string `json:"phone"`
-	Email      string `json:"email"`
-	Status     string `json:"status"`
-	CreateTime string `json:"createTime"`
-	UpdateTime string `json:"updateTime"`
+	Name       string   `json:"name"`
+	Phone      string   `json:"phone"`
+	Email      string   `json:"email"`
+	Status     string   `json:"status"`
+	Role       string   `json:"role"`
+	RoleName   string   `json:"roleName"`
+	OutletIDs  []string `json:"outletIds"`
+	CreateTime string   `json:"createTime"`
+	UpdateTime string   `json:"updateTime"`
 }
 
 // ToUserVO 将User模型转换为UserVO值对象
@@ -34,6 +37,9 @@ func ToUserVO(user *entity.User) UserVO {
 		Phone:      user.Phone,
 		Email:      user.Email,
 		Status:     strconv.Itoa(int(user.Status)),
+		Role:       string(user.Role),
+		RoleName:   user.Role.String(),
+		OutletIDs:  user.OutletIDs,
 		CreateTime: user.CreateTime.Time().In(loc).Format("2006-01-02 15:04:05"),
 		UpdateTime: user.UpdateTime.Time().In(loc).Format("2006-01-02 15:04:05"),
 	}
@@ -56,6 +62,8 @@ func (vo UserVO) String() string {
 		"phone":      vo.Phone,
 		"email":      vo.Email,
 		"status":     entity.UserStatus(statusInt).String(),
+		"role":       vo.RoleName,
+		"outletIds":  vo.OutletIDs,
 		"createTime": vo.CreateTime,
 		"updateTime": vo.UpdateTime,
 	}
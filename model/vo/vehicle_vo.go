@@ -2,24 +2,26 @@ package vo
 
 import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go_logistics/common"
 	"go_logistics/model/entity"
+	"go_logistics/util"
 )
 
 type VehicleVO struct {
-	ID           string               `bson:"_id,omitempty" json:"id"`
-	PlateNumber  string               `bson:"plateNumber" json:"plateNumber"`
-	Type         entity.VehicleType   `bson:"type" json:"type"`
-	LoadCapacity float64              `bson:"loadCapacity" json:"loadCapacity"`
-	CurrentLoad  float64              `bson:"currentLoad" json:"currentLoad"`
-	Status       entity.VehicleStatus `bson:"status" json:"status"`
-	RouteID      string               `bson:"routeId" json:"routeId"`
-	RouteName    string               `bson:"routeName" json:"routeName"`
-	Remarks      string               `bson:"remarks" json:"remarks"`
-	Lng          string               `bson:"lng" json:"lng"`
-	Lat          string               `bson:"lat" json:"lat"`
-	Route        *entity.Route        `bson:"route" json:"route"`
-	CreateTime   primitive.DateTime   `bson:"createTime" json:"-"`
-	UpdateTime   primitive.DateTime   `bson:"updateTime" json:"-"`
+	ID            string               `bson:"_id,omitempty" json:"id"`
+	PlateNumber   string               `bson:"plateNumber" json:"plateNumber"`
+	Type          entity.VehicleType   `bson:"type" json:"type"`
+	LoadCapacity  float64              `bson:"loadCapacity" json:"loadCapacity"`
+	CurrentLoad   float64              `bson:"currentLoad" json:"currentLoad"`
+	Status        entity.VehicleStatus `bson:"status" json:"status"`
+	RouteID       string               `bson:"routeId" json:"routeId"`
+	RouteName     string               `bson:"routeName" json:"routeName"`
+	Remarks       string               `bson:"remarks" json:"remarks"`
+	common.LngLat `bson:",inline"`
+	CRS           util.CRS           `bson:"crs" json:"crs"`
+	Route         *entity.Route      `bson:"route" json:"route"`
+	CreateTime    primitive.DateTime `bson:"createTime" json:"-"`
+	UpdateTime    primitive.DateTime `bson:"updateTime" json:"-"`
 }
 
 func ToVehicleVO(vehicle *entity.Vehicle) (VehicleVO, error) {
@@ -43,14 +45,30 @@ func ToVehicleVO(vehicle *entity.Vehicle) (VehicleVO, error) {
 		RouteID:      vehicle.RouteID,
 		RouteName:    vehicle.RouteName,
 		Remarks:      vehicle.Remarks,
-		Lng:          vehicle.Lng,
-		Lat:          vehicle.Lat,
+		LngLat:       vehicle.LngLat,
+		CRS:          vehicle.CRS,
 		Route:        route,
 		CreateTime:   vehicle.CreateTime,
 		UpdateTime:   vehicle.UpdateTime,
 	}, nil
 }
 
+// ConvertCRS 将车辆位置及所属线路坐标转换到目标坐标系
+func (v *VehicleVO) ConvertCRS(to util.CRS) {
+	v.LngLat = util.ConvertLngLat(v.LngLat, v.CRS.OrLegacy(), to)
+	v.CRS = to
+	if v.Route != nil {
+		v.Route.ConvertCRS(to)
+	}
+}
+
+// ConvertVehicleVOListCRS 批量转换车辆坐标
+func ConvertVehicleVOListCRS(vehicleVOs []VehicleVO, to util.CRS) {
+	for i := range vehicleVOs {
+		vehicleVOs[i].ConvertCRS(to)
+	}
+}
+
 func ToVehicleVOList(vehicles []*entity.Vehicle) ([]VehicleVO, error) {
 	var vehicleVOs []VehicleVO
 
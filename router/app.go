@@ -9,6 +9,7 @@ import (
 	"go_logistics/model/entity"
 	"go_logistics/service"
 	"go_logistics/util"
+	"strings"
 	"time"
 )
 
@@ -17,19 +18,27 @@ func Router() (server *gin.Engine) {
 	// 日志中间件
 	server.Use(ginzap.Ginzap(config.Log, "2006-01-02 15:04:05.000 CST", false))
 	server.Use(ginzap.RecoveryWithZap(config.Log, true))
+	// 只信任配置的代理转发的 X-Forwarded-For，否则客户端可伪造 IP 绕过登录限制
+	if err := server.SetTrustedProxies(trustedProxies()); err != nil {
+		panic(err)
+	}
 
 	// CORS 配置（严格模式）
 	server.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"}, // 明确指定前端地址
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Content-Type", "Authorization", "Logistics-Custom-Header", "logistics_token"},
-		ExposeHeaders:    []string{"Content-Length", "logistics_token"},
+		AllowHeaders:     []string{"Content-Type", "Authorization", "Logistics-Custom-Header", service.AccessTokenHeader},
+		ExposeHeaders:    []string{"Content-Length", service.AccessTokenHeader, service.RefreshTokenHeader},
 		AllowCredentials: true, // 关键配置
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// 全局中间件（所有路由都会经过）
 	server.Use(TokenAuthMiddleware())
+	// 按角色校验接口权限，需在 Token 校验之后
+	server.Use(PermissionMiddleware())
+	// 网点操作员等受限用户只能操作所属网点的数据
+	server.Use(OutletScopeMiddleware())
 
 	apiGroup := server.Group("/api")
 	userGroup := apiGroup.Group("/user")
@@ -40,8 +49,21 @@ func Router() (server *gin.Engine) {
 		userGroup.PUT("/update", service.UpdateUser)
 		userGroup.DELETE("/delete", service.DeleteUser)
 		userGroup.POST("/login", service.LoginUser)
+		userGroup.POST("/refresh", service.RefreshToken)
+		userGroup.POST("/logout", service.Logout)
+		userGroup.POST("/logoutAll", service.LogoutAll)
 		userGroup.GET("/loginStatus", service.GetUserLoginStatus)
+		userGroup.PUT("/password", service.ChangePassword)
 		userGroup.POST("/total", service.GetTotalCount)
+		userGroup.PUT("/outlets", service.AssignUserOutlets)
+		userGroup.PUT("/unlock", service.UnlockLogin)
+		userGroup.POST("/loginAudit/list", service.GetLoginAuditList)
+		userGroup.POST("/loginAudit/total", service.GetLoginAuditTotalCount)
+	}
+	roleGroup := apiGroup.Group("/role")
+	{
+		roleGroup.GET("/list", service.GetRoleList)
+		roleGroup.PUT("/assign", service.AssignUserRole)
 	}
 	orderGroup := apiGroup.Group("/order")
 	{
@@ -51,7 +73,9 @@ func Router() (server *gin.Engine) {
 		orderGroup.PUT("/update", service.UpdateOrder)
 		orderGroup.DELETE("/delete", service.DeleteOrder)
 		orderGroup.GET("/detail", service.GetOrderVO)
+		orderGroup.POST("/redispatch", service.RedispatchOrder)
 		orderGroup.PUT("/dispatch", service.DispatchOrder)
+		orderGroup.GET("/timeline", service.GetOrderTimeline)
 	}
 	outletGroup := apiGroup.Group("/outlet")
 	{
@@ -62,6 +86,24 @@ func Router() (server *gin.Engine) {
 		outletGroup.DELETE("/delete", service.DeleteOutlet)
 		outletGroup.GET("/allProvincesAndCities", service.GetAllProvincesAndCities)
 		outletGroup.GET("/id", service.GetOutletById)
+		outletGroup.GET("/impact", service.GetOutletImpact)
+		outletGroup.PUT("/schedule", service.UpdateOutletSchedule)
+		outletGroup.GET("/isOpen", service.IsOutletOpen)
+		outletGroup.GET("/overlaps", service.GetOutletOverlaps)
+		outletGroup.GET("/coverage", service.GetCoverageReport)
+		outletGroup.GET("/servable", service.GetServableOutlets)
+		outletGroup.GET("/children", service.GetChildOutlets)
+		outletGroup.PUT("/capacity", service.UpdateOutletCapacity)
+		outletGroup.POST("/inventory/inbound", service.InboundScan)
+		outletGroup.POST("/inventory/outbound", service.OutboundScan)
+		outletGroup.GET("/inventory", service.GetOutletInventory)
+		outletGroup.GET("/inventory/utilization", service.GetInventoryUtilization)
+		outletGroup.GET("/inventory/scans", service.GetOrderScans)
+		outletGroup.POST("/inventory/alert/list", service.GetInventoryAlertList)
+		outletGroup.PUT("/inventory/alert/ack", service.AcknowledgeInventoryAlert)
+		outletGroup.POST("/holiday/create", service.CreateOutletHoliday)
+		outletGroup.POST("/holiday/list", service.GetOutletHolidayList)
+		outletGroup.DELETE("/holiday/delete", service.DeleteOutletHoliday)
 	}
 	routeGroup := apiGroup.Group("/route")
 	{
@@ -70,6 +112,12 @@ func Router() (server *gin.Engine) {
 		routeGroup.POST("/total", service.GetRouteTotalCount)
 		routeGroup.PUT("/update", service.UpdateRoute)
 		routeGroup.DELETE("/delete", service.DeleteRoute)
+		routeGroup.POST("/backfill", service.BackfillRouteDistance)
+		routeGroup.POST("/import", service.ImportRoute)
+		routeGroup.GET("/export", service.ExportRoute)
+		routeGroup.PUT("/timetable", service.UpdateRouteTimetable)
+		routeGroup.GET("/departures", service.GetRouteDepartures)
+		routeGroup.GET("/impact", service.GetRouteImpact)
 	}
 
 	vehicleGroup := apiGroup.Group("/vehicle")
@@ -80,6 +128,54 @@ func Router() (server *gin.Engine) {
 		vehicleGroup.PUT("/update", service.UpdateVehicle)
 		vehicleGroup.DELETE("/delete", service.DeleteVehicle)
 		vehicleGroup.GET("/complete", service.CompleteTransport)
+		vehicleGroup.GET("/manifest", service.GetVehicleManifest)
+		vehicleGroup.GET("/manifestPdf", service.DownloadVehicleManifest)
+	}
+	deliveryGroup := apiGroup.Group("/delivery")
+	{
+		deliveryGroup.POST("/plan", service.PlanDelivery)
+		deliveryGroup.GET("/plan/list", service.GetDeliveryPlanList)
+		deliveryGroup.GET("/plan/detail", service.GetDeliveryPlanDetail)
+		deliveryGroup.GET("/plan/geojson", service.GetDeliveryPlanGeoJSON)
+		deliveryGroup.PUT("/plan/commit", service.CommitDeliveryPlan)
+		deliveryGroup.GET("/complete", service.CompleteDelivery)
+	}
+	tripGroup := apiGroup.Group("/trip")
+	{
+		tripGroup.POST("/list", service.GetTripList)
+		tripGroup.POST("/total", service.GetTripTotalCount)
+		tripGroup.GET("/detail", service.GetTripDetail)
+	}
+	driverGroup := apiGroup.Group("/driver")
+	{
+		driverGroup.POST("/create", service.CreateDriver)
+		driverGroup.POST("/list", service.GetDriverList)
+		driverGroup.POST("/total", service.GetDriverTotalCount)
+		driverGroup.PUT("/update", service.UpdateDriver)
+		driverGroup.DELETE("/delete", service.DeleteDriver)
+		driverGroup.PUT("/assign", service.AssignDriver)
+		driverGroup.POST("/assignments", service.GetDriverAssignmentList)
+		driverGroup.POST("/events", service.GetDriverEventList)
+	}
+	// 司机端接口，仅允许司机 Token 访问
+	driverAppGroup := apiGroup.Group("/driverApp")
+	{
+		driverAppGroup.POST("/login", service.DriverLogin)
+		driverAppGroup.POST("/refresh", service.RefreshDriverToken)
+		driverAppGroup.POST("/logout", service.Logout)
+		driverAppGroup.GET("/profile", service.GetDriverProfile)
+		driverAppGroup.GET("/manifest", service.GetDriverManifest)
+		driverAppGroup.PUT("/pickup", service.ConfirmPickup)
+		driverAppGroup.PUT("/arrive", service.ReportArrival)
+		driverAppGroup.PUT("/deliver", service.ConfirmDelivery)
+		driverAppGroup.POST("/incident", service.ReportIncident)
+		driverAppGroup.POST("/photo", service.UploadDriverPhoto)
+	}
+	geoGroup := apiGroup.Group("/geo")
+	{
+		geoGroup.GET("/geocode", service.Geocode)
+		geoGroup.GET("/reverse", service.ReverseGeocode)
+		geoGroup.POST("/poi/import", service.ImportGeoPOI)
 	}
 	homeGroup := apiGroup.Group("/home")
 	{
@@ -87,6 +183,8 @@ func Router() (server *gin.Engine) {
 		homeGroup.GET("/order", service.GetOrderView)
 		homeGroup.GET("/vehicle", service.GetVehicleView)
 		homeGroup.GET("/route", service.GetRouteView)
+		homeGroup.POST("/fleet", service.GetFleetView)
+		homeGroup.GET("/hub", service.GetHubView)
 	}
 	generateGroup := apiGroup.Group("/generate")
 	{
@@ -112,13 +210,19 @@ func Router() (server *gin.Engine) {
 	return
 }
 
+// driverApiPrefix 司机端接口前缀
+const driverApiPrefix = "/api/driverApp/"
+
+// whitelist 无需登录即可访问的接口（精确匹配）
+var whitelist = map[string]bool{
+	"/api/user/login":        true, // 登录接口
+	"/api/user/refresh":      true, // 刷新 Token 接口，访问 Token 过期后调用
+	"/api/driverApp/login":   true, // 司机端登录接口
+	"/api/driverApp/refresh": true, // 司机端刷新 Token 接口
+}
+
 // TokenAuthMiddleware Token 校验中间件
 func TokenAuthMiddleware() gin.HandlerFunc {
-	// 定义白名单路径（支持精确匹配）
-	whitelist := map[string]bool{
-		"/api/user/login": true, // 登录接口
-	}
-
 	return func(c *gin.Context) {
 		currentPath := c.FullPath() // 获取注册的路由路径（非请求URI）
 		// 检查白名单
@@ -127,8 +231,7 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		var token string
-		token = c.GetHeader("logistics_token")
+		token := c.GetHeader(service.AccessTokenHeader)
 		if token == "" {
 			common.AbortResponse(c, common.NotLogin)
 			return
@@ -139,26 +242,62 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 			common.AbortResponse(c, common.NotLogin)
 			return
 		}
-		if claims == nil {
+		// 封禁、删除、修改角色或退出登录时会吊销会话，这里只检查内存中的吊销列表，不再逐次查询用户
+		if claims == nil || claims.SessionID == "" || util.IsSessionRevoked(claims.SessionID) {
 			common.AbortResponse(c, common.NotLogin)
 			return
 		}
-		name := claims.Name
-		user, err := entity.GetUserByName(name)
-		if err != nil {
-			common.AbortResponse(c, common.RecordNotFound)
-			return
-		}
-		if user.Status == entity.Banned {
-			common.AbortResponse(c, common.UserBanned)
+		// 司机 Token 只能访问司机端接口，后台用户不能访问司机端接口
+		isDriverApi := strings.HasPrefix(currentPath, driverApiPrefix)
+		if claims.Role == util.DriverRole {
+			if !isDriverApi {
+				common.AbortResponse(c, common.PermissionDenied)
+				return
+			}
+			c.Set("driverId", claims.Name)
+			c.Set("role", claims.Role)
+			c.Set("sessionId", claims.SessionID)
+			c.Next()
 			return
 		}
-		if user.Status == entity.Deleted {
-			common.AbortResponse(c, common.UserDeleted)
+		if isDriverApi {
+			common.AbortResponse(c, common.PermissionDenied)
 			return
 		}
 		c.Set("name", claims.Name)
+		c.Set("role", claims.Role)
+		c.Set("sessionId", claims.SessionID)
+		c.Set("outletScope", entity.NewOutletScope(util.Role(claims.Role), claims.OutletIDs))
 		c.Next()
 		return
 	}
 }
+
+// PermissionMiddleware 按权限矩阵校验当前角色能否访问接口
+func PermissionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentPath := c.FullPath()
+		// 白名单接口与未注册的路径不做校验
+		if currentPath == "" || whitelist[currentPath] {
+			c.Next()
+			return
+		}
+		role := util.Role(c.GetString("role"))
+		if !util.HasPermission(role, c.Request.Method, currentPath) {
+			common.AbortResponse(c, common.PermissionDenied)
+			return
+		}
+		c.Next()
+	}
+}
+
+// trustedProxies 解析受信任的代理地址，未配置时返回 nil，不信任任何代理
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(config.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
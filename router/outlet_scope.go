@@ -0,0 +1,192 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+)
+
+// scopeKind 接口参数指向的数据类型
+type scopeKind int
+
+const (
+	scopeOutlet scopeKind = iota + 1
+	scopeOrder
+	scopeVehicle
+	scopeRoute
+	scopeDeliveryPlan
+	scopeTrip
+	scopeInventoryAlert
+	scopeFile
+)
+
+// paramSource 参数的读取位置，必须与接口读取参数的位置一致，否则可以用另一处的参数绕过校验
+type paramSource int
+
+const (
+	fromQuery paramSource = iota + 1
+	fromForm
+)
+
+// scopedParam 按单条数据操作的接口中标识数据的参数
+type scopedParam struct {
+	name   string
+	kind   scopeKind
+	source paramSource
+}
+
+// value 按接口读取参数的位置取值
+func (p scopedParam) value(c *gin.Context) string {
+	if p.source == fromForm {
+		return c.PostForm(p.name)
+	}
+	return c.Query(p.name)
+}
+
+// scopedRoutes 按单条数据操作、需要校验参数所指数据是否在网点范围内的接口
+var scopedRoutes = map[string]scopedParam{
+	"/api/order/detail":               {"orderId", scopeOrder, fromQuery},
+	"/api/order/update":               {"orderId", scopeOrder, fromForm},
+	"/api/order/delete":               {"orderId", scopeOrder, fromQuery},
+	"/api/order/dispatch":             {"orderId", scopeOrder, fromQuery},
+	"/api/order/redispatch":           {"orderId", scopeOrder, fromForm},
+	"/api/order/timeline":             {"orderId", scopeOrder, fromQuery},
+	"/api/outlet/update":              {"id", scopeOutlet, fromForm},
+	"/api/outlet/delete":              {"outletId", scopeOutlet, fromQuery},
+	"/api/outlet/impact":              {"outletId", scopeOutlet, fromQuery},
+	"/api/outlet/schedule":            {"outletId", scopeOutlet, fromForm},
+	"/api/outlet/capacity":            {"id", scopeOutlet, fromForm},
+	"/api/outlet/inventory/scans":     {"orderId", scopeOrder, fromQuery},
+	"/api/outlet/inventory":           {"outletId", scopeOutlet, fromQuery},
+	"/api/outlet/inventory/inbound":   {"outletId", scopeOutlet, fromForm},
+	"/api/outlet/inventory/outbound":  {"outletId", scopeOutlet, fromForm},
+	"/api/outlet/inventory/alert/ack": {"id", scopeInventoryAlert, fromForm},
+	"/api/route/create":               {"startOutlet", scopeOutlet, fromForm},
+	"/api/vehicle/create":             {"routeId", scopeRoute, fromForm},
+	"/api/vehicle/update":             {"plateNumber", scopeVehicle, fromForm},
+	"/api/vehicle/delete":             {"plateNumber", scopeVehicle, fromQuery},
+	"/api/vehicle/complete":           {"plateNumber", scopeVehicle, fromQuery},
+	"/api/vehicle/manifest":           {"plateNumber", scopeVehicle, fromQuery},
+	"/api/vehicle/manifestPdf":        {"plateNumber", scopeVehicle, fromQuery},
+	"/api/route/update":               {"routeId", scopeRoute, fromForm},
+	"/api/route/delete":               {"routeId", scopeRoute, fromQuery},
+	"/api/route/timetable":            {"routeId", scopeRoute, fromForm},
+	"/api/route/departures":           {"routeId", scopeRoute, fromQuery},
+	"/api/route/impact":               {"routeId", scopeRoute, fromQuery},
+	"/api/route/export":               {"routeId", scopeRoute, fromQuery},
+	"/api/delivery/plan":              {"outletId", scopeOutlet, fromForm},
+	"/api/delivery/plan/list":         {"outletId", scopeOutlet, fromQuery},
+	"/api/delivery/plan/detail":       {"planId", scopeDeliveryPlan, fromQuery},
+	"/api/delivery/plan/geojson":      {"planId", scopeDeliveryPlan, fromQuery},
+	"/api/delivery/plan/commit":       {"planId", scopeDeliveryPlan, fromQuery},
+	"/api/delivery/complete":          {"plateNumber", scopeVehicle, fromQuery},
+	"/api/trip/detail":                {"tripId", scopeTrip, fromQuery},
+	"/api/driver/assign":              {"plateNumber", scopeVehicle, fromForm},
+	"/api/file/download":              {"fileId", scopeFile, fromQuery},
+}
+
+// queryScopedRoutes 列表、总数与统计接口，接口绑定查询参数时附加网点范围，在数据库查询中按范围过滤
+var queryScopedRoutes = map[string]bool{
+	"/api/order/list":                   true,
+	"/api/order/total":                  true,
+	"/api/outlet/inventory/utilization": true,
+	"/api/outlet/inventory/alert/list":  true,
+	"/api/route/list":                   true,
+	"/api/route/total":                  true,
+	"/api/vehicle/list":                 true,
+	"/api/vehicle/total":                true,
+	"/api/trip/list":                    true,
+	"/api/trip/total":                   true,
+	"/api/driver/assignments":           true,
+	"/api/driver/events":                true,
+	"/api/home/order":                   true,
+	"/api/home/vehicle":                 true,
+	"/api/home/route":                   true,
+	"/api/home/fleet":                   true,
+	"/api/home/hub":                     true,
+	"/api/file/list":                    true,
+}
+
+// unscopedRoutes 不涉及网点数据的接口：当前用户自身的会话与密码、网点目录与营业日历、司机档案、地理编码与对话。
+// 网点目录对所有用户公开，用于选择网点与展示网点地图
+var unscopedRoutes = map[string]bool{
+	"/api/user/loginStatus":             true,
+	"/api/user/password":                true,
+	"/api/user/logout":                  true,
+	"/api/user/logoutAll":               true,
+	"/api/order/create":                 true,
+	"/api/outlet/list":                  true,
+	"/api/outlet/total":                 true,
+	"/api/outlet/allProvincesAndCities": true,
+	"/api/outlet/id":                    true,
+	"/api/outlet/isOpen":                true,
+	"/api/outlet/overlaps":              true,
+	"/api/outlet/coverage":              true,
+	"/api/outlet/servable":              true,
+	"/api/outlet/children":              true,
+	"/api/outlet/holiday/list":          true,
+	"/api/home/outlet":                  true,
+	"/api/driver/list":                  true,
+	"/api/driver/total":                 true,
+	"/api/geo/geocode":                  true,
+	"/api/geo/reverse":                  true,
+	"/api/llm/chat":                     true,
+	"/api/llm/chatList":                 true,
+	"/api/llm/createChat":               true,
+}
+
+// OutletScopeMiddleware 校验受限用户访问的数据是否在其网点范围内，管理员等不受限的用户直接放行。
+// 受限用户只能访问以上三类登记过的接口，新增接口未登记时一律拒绝，避免遗漏范围校验
+func OutletScopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("outletScope")
+		scope, _ := value.(*entity.OutletScope)
+		currentPath := c.FullPath()
+		if scope == nil || currentPath == "" || queryScopedRoutes[currentPath] || unscopedRoutes[currentPath] {
+			c.Next()
+			return
+		}
+		param, ok := scopedRoutes[currentPath]
+		if !ok {
+			common.AbortResponse(c, common.PermissionDenied)
+			return
+		}
+		id := param.value(c)
+		// 缺少参数时交由接口返回参数错误
+		if id == "" {
+			c.Next()
+			return
+		}
+		var (
+			visible bool
+			err     error
+		)
+		switch param.kind {
+		case scopeOutlet:
+			visible = scope.HasOutlet(id)
+		case scopeOrder:
+			visible, err = scope.HasOrder(id)
+		case scopeVehicle:
+			visible, err = scope.HasVehicle(id)
+		case scopeRoute:
+			visible, err = scope.HasRoute(id)
+		case scopeDeliveryPlan:
+			visible, err = scope.HasDeliveryPlan(id)
+		case scopeTrip:
+			visible, err = scope.HasTrip(id)
+		case scopeInventoryAlert:
+			visible, err = scope.HasInventoryAlert(id)
+		case scopeFile:
+			visible, err = scope.HasFile(id)
+		}
+		if err != nil {
+			common.AbortResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		if !visible {
+			common.AbortResponse(c, common.PermissionDenied)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+)
+
+// requestCRS 读取请求的 crs 参数（查询参数或表单），用于解释入参坐标与转换出参坐标，
+// 未指定时使用 defaultCRS，前端接口传 util.DefaultClientCRS，文件导入导出传 util.StorageCRS
+func requestCRS(c *gin.Context, defaultCRS util.CRS) (util.CRS, error) {
+	name := c.Query("crs")
+	if name == "" {
+		name = c.PostForm("crs")
+	}
+	return util.ParseCRS(name, defaultCRS)
+}
+
+// parseStorageLngLat 解析请求坐标系下的经纬度并转换为存储坐标系，经纬度均为空时返回零值表示未设置
+func parseStorageLngLat(lng string, lat string, from util.CRS) (common.LngLat, error) {
+	if lng == "" && lat == "" {
+		return common.LngLat{}, nil
+	}
+	position, err := common.ParseLngLat(lng, lat)
+	if err != nil {
+		return common.LngLat{}, err
+	}
+	return util.ConvertLngLat(position, from, util.StorageCRS), nil
+}
+
+// convertOutletsCRS 将网点坐标转换到请求的坐标系
+func convertOutletsCRS(outlets []*entity.Outlet, to util.CRS) {
+	for _, outlet := range outlets {
+		outlet.ConvertCRS(to)
+	}
+}
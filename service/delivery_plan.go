@@ -0,0 +1,599 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDeliveryDeparture 派送计划默认的出发时间
+	DefaultDeliveryDeparture = "08:00"
+	// DefaultServiceMinutes 每个停靠点默认的停留时长（分钟）
+	DefaultServiceMinutes = 5
+)
+
+// PlanDelivery 为网点当天待派送的包裹生成派送计划：
+// 参与规划的是在库且终点为本网点的订单，车辆需空闲且没有进行中的趟次，
+// 时间窗与出发时间为 HH:mm，计划生成后需确认下发才会生成趟次
+func PlanDelivery(c *gin.Context) {
+	outletId := c.PostForm("outletId")
+	plateNumbersStr := c.PostForm("plateNumbers")
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if outletId == "" || plateNumbersStr == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	var plateNumbers []string
+	if err := json.Unmarshal([]byte(plateNumbersStr), &plateNumbers); err != nil || len(plateNumbers) == 0 {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	var windows []entity.DeliveryTimeWindow
+	if windowsStr := c.PostForm("timeWindows"); windowsStr != "" {
+		if err := json.Unmarshal([]byte(windowsStr), &windows); err != nil {
+			common.ErrorResponse(c, common.ParamError)
+			return
+		}
+	}
+	serviceMinutes := DefaultServiceMinutes
+	if value := c.PostForm("serviceMinutes"); value != "" {
+		serviceMinutes, err = strconv.Atoi(value)
+		if err != nil || serviceMinutes < 0 {
+			common.ErrorResponse(c, common.ParamError)
+			return
+		}
+	}
+	day, departure, err := parseDeliveryDeparture(c.PostForm("date"), c.DefaultPostForm("departureTime", DefaultDeliveryDeparture))
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+
+	outlet, err := entity.GetOutletById(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	if outlet.LngLat.IsZero() {
+		common.ErrorResponse(c, common.ValidateError("网点未设置坐标"))
+		return
+	}
+	vehicles, err := deliveryVehicles(plateNumbers)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	orders, err := deliveryOrders(outletId, day.AddDate(0, 0, 1))
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	windowOf := make(map[string]entity.DeliveryTimeWindow, len(windows))
+	for _, window := range windows {
+		windowOf[window.OrderID] = window
+	}
+
+	plan := &entity.DeliveryPlan{
+		OutletID:      outletId,
+		OutletName:    outlet.Name,
+		Date:          day.Format("2006-01-02"),
+		Depot:         outlet.LngLat,
+		DepartureTime: primitive.NewDateTimeFromTime(departure),
+		Provider:      getDistanceProvider().Name(),
+		Routes:        make([]*entity.DeliveryRoute, 0),
+		Unassigned:    make([]string, 0),
+		Operator:      c.GetString("name"),
+	}
+	// 下标 0 为网点，其余为参与规划的订单终点
+	points := []common.LngLat{outlet.LngLat}
+	var (
+		planned []*entity.Order
+		stops   []util.VRPStop
+		ranges  [][2]time.Time
+	)
+	for _, order := range orders {
+		if order.End.IsZero() {
+			plan.Unassigned = append(plan.Unassigned, order.OrderID)
+			continue
+		}
+		stop := util.VRPStop{Weight: order.Weight}
+		start, end, err := windowOf[order.OrderID].Parse(day)
+		if err != nil {
+			common.ErrorResponse(c, common.ValidateError(err.Error()))
+			return
+		}
+		if !start.IsZero() {
+			stop.WindowStart = start.Sub(departure).Hours()
+		}
+		if !end.IsZero() {
+			stop.WindowEnd = end.Sub(departure).Hours()
+			if stop.WindowEnd <= 0 {
+				plan.Unassigned = append(plan.Unassigned, order.OrderID)
+				continue
+			}
+		}
+		planned = append(planned, order)
+		stops = append(stops, stop)
+		ranges = append(ranges, [2]time.Time{start, end})
+		points = append(points, order.End)
+	}
+
+	problem := &util.VRPProblem{
+		Stops:        stops,
+		Capacities:   make([]float64, len(vehicles)),
+		Matrix:       routeMatrix(c.Request.Context(), points, points),
+		ServiceHours: float64(serviceMinutes) / 60,
+	}
+	for i, vehicle := range vehicles {
+		problem.Capacities[i] = vehicle.LoadCapacity
+	}
+	solution := util.SolveVRP(problem)
+	for _, route := range solution.Routes {
+		vehicle := vehicles[route.Vehicle]
+		deliveryRoute := &entity.DeliveryRoute{
+			PlateNumber:  vehicle.PlateNumber,
+			VehicleType:  vehicle.Type,
+			LoadCapacity: vehicle.LoadCapacity,
+			Load:         roundToPrecision(route.Load, 10000),
+			Distance:     roundToPrecision(route.Distance, 10000),
+			Duration:     roundToPrecision(route.Duration, 100),
+			ReturnTime:   primitive.NewDateTimeFromTime(departure.Add(hoursToDuration(route.Duration))),
+		}
+		for i, index := range route.Stops {
+			order := planned[index]
+			stop := entity.DeliveryStop{
+				Sequence: i + 1,
+				OrderID:  order.OrderID,
+				Address:  order.EndAddress,
+				Position: order.End,
+				Weight:   order.Weight,
+				Arrival:  primitive.NewDateTimeFromTime(departure.Add(hoursToDuration(route.Arrivals[i]))),
+			}
+			window := ranges[index]
+			if !window[0].IsZero() {
+				stop.WindowStart = primitive.NewDateTimeFromTime(window[0])
+			}
+			if !window[1].IsZero() {
+				stop.WindowEnd = primitive.NewDateTimeFromTime(window[1])
+			}
+			deliveryRoute.Stops = append(deliveryRoute.Stops, stop)
+		}
+		plan.Routes = append(plan.Routes, deliveryRoute)
+		plan.TotalDistance += deliveryRoute.Distance
+	}
+	for _, index := range solution.Unassigned {
+		plan.Unassigned = append(plan.Unassigned, planned[index].OrderID)
+	}
+	plan.TotalDistance = roundToPrecision(plan.TotalDistance, 10000)
+
+	if err = entity.InsertDeliveryPlan(plan); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	plan.ConvertCRS(crs)
+	common.SuccessResponseWithData(c, plan)
+}
+
+// GetDeliveryPlanList 获取网点的派送计划列表
+func GetDeliveryPlanList(c *gin.Context) {
+	outletId := c.Query("outletId")
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if outletId == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	plans, err := entity.GetDeliveryPlanList(outletId, c.Query("date"))
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	for _, plan := range plans {
+		plan.ConvertCRS(crs)
+	}
+	common.SuccessResponseWithData(c, plans)
+}
+
+// GetDeliveryPlanDetail 获取派送计划详情
+func GetDeliveryPlanDetail(c *gin.Context) {
+	planId := c.Query("planId")
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if planId == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	plan, err := entity.GetDeliveryPlanById(planId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	plan.ConvertCRS(crs)
+	common.SuccessResponseWithData(c, plan)
+}
+
+// GetDeliveryPlanGeoJSON 以 GeoJSON 返回派送计划：网点与停靠点为 Point，每辆车的路线为 LineString。
+// 路线按派送顺序直线连接各停靠点，用于在地图上查看顺序，不代表实际行驶轨迹
+func GetDeliveryPlanGeoJSON(c *gin.Context) {
+	planId := c.Query("planId")
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if planId == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	plan, err := entity.GetDeliveryPlanById(planId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	plan.ConvertCRS(crs)
+	depot := orb.Point{plan.Depot.Lng, plan.Depot.Lat}
+	collection := geojson.NewFeatureCollection()
+	feature := geojson.NewFeature(depot)
+	feature.Properties["type"] = "depot"
+	feature.Properties["name"] = plan.OutletName
+	collection.Append(feature)
+	for _, route := range plan.Routes {
+		line := orb.LineString{depot}
+		for _, stop := range route.Stops {
+			point := orb.Point{stop.Position.Lng, stop.Position.Lat}
+			line = append(line, point)
+			feature := geojson.NewFeature(point)
+			feature.Properties["type"] = "stop"
+			feature.Properties["plateNumber"] = route.PlateNumber
+			feature.Properties["sequence"] = stop.Sequence
+			feature.Properties["orderId"] = stop.OrderID
+			feature.Properties["address"] = stop.Address
+			feature.Properties["arrival"] = stop.Arrival.Time()
+			collection.Append(feature)
+		}
+		line = append(line, depot)
+		feature := geojson.NewFeature(line)
+		feature.Properties["type"] = "route"
+		feature.Properties["plateNumber"] = route.PlateNumber
+		feature.Properties["load"] = route.Load
+		feature.Properties["distance"] = route.Distance
+		feature.Properties["duration"] = route.Duration
+		collection.Append(feature)
+	}
+	collection.ExtraMembers = geojson.Properties{"crs": plan.CRS}
+	common.SuccessResponseWithData(c, collection)
+}
+
+// CommitDeliveryPlan 确认下发派送计划：每条路线生成一个派送趟次，包裹出库装车，车辆转为运行中
+func CommitDeliveryPlan(c *gin.Context) {
+	planId := c.Query("planId")
+	if planId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	plan, err := entity.GetDeliveryPlanById(planId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	if plan.Status != entity.DeliveryPlanDraft {
+		common.ErrorResponse(c, common.ValidateError("派送计划"+plan.Status.String()))
+		return
+	}
+	operator := c.GetString("name")
+	// 先原子地认领计划再下发路线，并发的下发请求只有一个能继续
+	if err = entity.ClaimDeliveryPlan(plan, operator); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	plateNumbers := make([]string, len(plan.Routes))
+	for i, route := range plan.Routes {
+		plateNumbers[i] = route.PlateNumber
+	}
+	// 规划后车辆可能已被调度，下发前重新检查
+	vehicles, err := deliveryVehicles(plateNumbers)
+	if err != nil {
+		if releaseErr := entity.ReleaseDeliveryPlan(plan); releaseErr != nil {
+			config.Log.Warn("派送计划恢复为待确认失败", zap.String("planId", planId), zap.Error(releaseErr))
+		}
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	now := time.Now()
+	failed := 0
+	for i, route := range plan.Routes {
+		trip, err := commitDeliveryRoute(plan, route, vehicles[i], operator, now)
+		if trip != nil {
+			route.TripID = trip.ID.Hex()
+		}
+		if err != nil {
+			config.Log.Warn("下发派送路线失败", zap.String("planId", planId),
+				zap.String("plateNumber", route.PlateNumber), zap.Error(err))
+			route.CommitError = err.Error()
+			failed++
+		}
+	}
+	if err = entity.FinishDeliveryPlanCommit(plan); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	if failed > 0 {
+		common.ErrorResponse(c, common.ServerError(fmt.Sprintf("%d 条派送路线下发失败，详见派送计划", failed)))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// commitDeliveryRoute 下发一条派送路线：新建趟次、更新车辆状态并将包裹出库。
+// 车辆更新失败时删除新建的趟次；部分包裹无法装车时从趟次中移除这些包裹，返回趟次与错误
+func commitDeliveryRoute(plan *entity.DeliveryPlan, route *entity.DeliveryRoute, vehicle *entity.Vehicle,
+	operator string, now time.Time) (*entity.Trip, error) {
+	vehicleMu := util.GetVehicleLock(vehicle.PlateNumber)
+	vehicleMu.Lock()
+	defer vehicleMu.Unlock()
+
+	orderIds := make([]string, len(route.Stops))
+	for i, stop := range route.Stops {
+		orderIds[i] = stop.OrderID
+	}
+	trip := &entity.Trip{
+		PlateNumber:    vehicle.PlateNumber,
+		VehicleType:    vehicle.Type,
+		DeliveryPlanID: plan.ID.Hex(),
+		OrderIDs:       orderIds,
+		TotalWeight:    route.Load,
+		PeakLoad:       route.Load,
+		LoadCapacity:   vehicle.LoadCapacity,
+		Start:          plan.Depot,
+	}
+	driver, _ := getOnShiftDriver(vehicle, now)
+	if driver != nil {
+		trip.DriverID = driver.ID.Hex()
+		trip.DriverName = driver.Name
+	}
+	if err := entity.InsertTrip(trip); err != nil {
+		return nil, err
+	}
+	previous := *vehicle
+	vehicle.Status = entity.InTransit
+	vehicle.CurrentLoad = route.Load
+	vehicle.LngLat = plan.Depot
+	if err := entity.UpdateVehicle(vehicle); err != nil {
+		*vehicle = previous
+		if deleteErr := entity.DeleteTrip(trip.ID); deleteErr != nil {
+			config.Log.Warn("回滚派送趟次失败", zap.String("tripId", trip.ID.Hex()), zap.Error(deleteErr))
+			return trip, err
+		}
+		return nil, err
+	}
+	var failedOrders []string
+	for _, stop := range route.Stops {
+		if err := loadDeliveryOrder(plan.OutletID, stop.OrderID, vehicle.PlateNumber, trip, operator); err != nil {
+			// 无法装车的包裹从趟次中移除，其余包裹继续派送
+			failedOrders = append(failedOrders, err.Error())
+			vehicle.CurrentLoad -= stop.Weight
+			if err = entity.RemoveOrderFromTrip(trip.ID.Hex(), stop.OrderID, stop.Weight); err != nil {
+				config.Log.Warn("从派送趟次移除包裹失败", zap.String("orderId", stop.OrderID), zap.Error(err))
+			}
+		}
+	}
+	if len(failedOrders) > 0 {
+		if err := entity.UpdateVehicle(vehicle); err != nil {
+			config.Log.Warn("更新派送车辆载重失败", zap.String("plateNumber", vehicle.PlateNumber), zap.Error(err))
+		}
+		return trip, errors.New(strings.Join(failedOrders, "；"))
+	}
+	return trip, nil
+}
+
+// loadDeliveryOrder 包裹出库装车并记录派送车辆
+func loadDeliveryOrder(outletId string, orderId string, plateNumber string, trip *entity.Trip, operator string) error {
+	orderMu := util.GetOrderLock(orderId)
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	if err := entity.AssignOrderDelivery(orderId, plateNumber, trip.ID.Hex(), trip.DriverID); err != nil {
+		return err
+	}
+	if err := entity.DeleteInventoryItem(orderId, outletId); err != nil {
+		// 已手工出库的包裹不影响派送
+		config.Log.Warn("派送包裹出库失败", zap.String("orderId", orderId), zap.Error(err))
+		return nil
+	}
+	_ = entity.InsertOutletScan(&entity.OutletScan{
+		Type:        entity.ScanOutbound,
+		OrderID:     orderId,
+		OutletID:    outletId,
+		PlateNumber: plateNumber,
+		Operator:    operator,
+	})
+	recordOrderEvent(&entity.OrderEvent{
+		OrderID:     orderId,
+		Type:        entity.OrderEventOutbound,
+		OutletID:    outletId,
+		PlateNumber: plateNumber,
+		Operator:    operator,
+		Description: "装车派送",
+	})
+	return nil
+}
+
+// CompleteDelivery 派送车辆返回网点：结束派送趟次，里程取计划里程，未送达的包裹退回网点库存
+func CompleteDelivery(c *gin.Context) {
+	plateNumber := c.Query("plateNumber")
+	if plateNumber == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	vehicle, err := entity.GetVehicleById(plateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	trip, err := entity.GetOpenTripByVehicle(plateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	if trip == nil || trip.DeliveryPlanID == "" {
+		common.ErrorResponse(c, common.ValidateError("车辆没有进行中的派送趟次"))
+		return
+	}
+	plan, err := entity.GetDeliveryPlanById(trip.DeliveryPlanID)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	var distance float64
+	for _, route := range plan.Routes {
+		if route.TripID == trip.ID.Hex() {
+			distance = route.Distance
+		}
+	}
+	if err = entity.CloseTrip(trip, distance, plan.Depot); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+
+	undelivered, err := entity.GetProcessingOrdersByVehicle(plateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	for _, order := range undelivered {
+		if err = returnUndeliveredOrder(plan.OutletID, order, plateNumber, c.GetString("name")); err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+	}
+
+	vehicleMu := util.GetVehicleLock(plateNumber)
+	vehicleMu.Lock()
+	defer vehicleMu.Unlock()
+	vehicle.Status = entity.Free
+	vehicle.CurrentLoad = 0
+	vehicle.LngLat = plan.Depot
+	if err = entity.UpdateVehicle(vehicle); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, gin.H{"returned": len(undelivered)})
+}
+
+// returnUndeliveredOrder 未送达的包裹退回网点并重新入库
+func returnUndeliveredOrder(outletId string, order *entity.Order, plateNumber string, operator string) error {
+	orderMu := util.GetOrderLock(order.OrderID)
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	if err := entity.ReturnUndeliveredOrder(order.OrderID, plateNumber); err != nil {
+		return err
+	}
+	err := entity.InsertInventoryItem(&entity.InventoryItem{
+		OrderID:  order.OrderID,
+		OutletID: outletId,
+		Weight:   order.Weight,
+		Volume:   order.Volume,
+	})
+	if err != nil {
+		return err
+	}
+	_ = entity.InsertOutletScan(&entity.OutletScan{
+		Type:     entity.ScanInbound,
+		OrderID:  order.OrderID,
+		OutletID: outletId,
+		Operator: operator,
+	})
+	recordOrderEvent(&entity.OrderEvent{
+		OrderID:     order.OrderID,
+		Type:        entity.OrderEventInbound,
+		OutletID:    outletId,
+		PlateNumber: plateNumber,
+		Operator:    operator,
+		Description: "派送未完成，退回网点",
+	})
+	return nil
+}
+
+// parseDeliveryDeparture 解析派送日期（yyyy-MM-dd，为空取今天）与出发时间（HH:mm），均按北京时间
+func parseDeliveryDeparture(date string, departureTime string) (time.Time, time.Time, error) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	if loc == nil {
+		loc = time.Local
+	}
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return day, day, fmt.Errorf("派送日期格式错误: %s", date)
+		}
+		day = parsed
+	}
+	clock, err := time.Parse("15:04", departureTime)
+	if err != nil {
+		return day, day, fmt.Errorf("出发时间格式错误: %s", departureTime)
+	}
+	return day, day.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute), nil
+}
+
+// deliveryVehicles 查询参与派送的车辆，车辆需空闲且没有进行中的趟次
+func deliveryVehicles(plateNumbers []string) ([]*entity.Vehicle, error) {
+	vehicles := make([]*entity.Vehicle, 0, len(plateNumbers))
+	seen := make(map[string]bool, len(plateNumbers))
+	for _, plateNumber := range plateNumbers {
+		if seen[plateNumber] {
+			continue
+		}
+		seen[plateNumber] = true
+		vehicle, err := entity.GetVehicleById(plateNumber)
+		if err != nil {
+			return nil, fmt.Errorf("车辆 %s 不存在", plateNumber)
+		}
+		if vehicle.Status != entity.Free {
+			return nil, fmt.Errorf("车辆 %s %s，不能派送", plateNumber, vehicle.Status.String())
+		}
+		trip, err := entity.GetOpenTripByVehicle(plateNumber)
+		if err != nil {
+			return nil, err
+		}
+		if trip != nil {
+			return nil, fmt.Errorf("车辆 %s 有进行中的趟次", plateNumber)
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+	return vehicles, nil
+}
+
+// deliveryOrders 查询网点在 before 之前入库、由本网点派送的未完成订单
+func deliveryOrders(outletId string, before time.Time) ([]*entity.Order, error) {
+	items, err := entity.GetOutletInventory(outletId)
+	if err != nil {
+		return nil, err
+	}
+	var orders []*entity.Order
+	for _, item := range items {
+		if !item.InboundTime.Time().Before(before) {
+			continue
+		}
+		order, err := entity.GetOrderById(item.OrderID)
+		if err != nil {
+			continue
+		}
+		if nextOutletOf(order, outletId) != "" || order.Status == entity.Completed || order.Status == entity.Cancelled {
+			continue
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// hoursToDuration 将小时数转换为 time.Duration
+func hoursToDuration(hours float64) time.Duration {
+	return time.Duration(hours * float64(time.Hour))
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"strings"
+	"sync"
+)
+
+var (
+	distanceProvider     util.DistanceProvider
+	distanceProviderOnce sync.Once
+	haversineProvider    = &util.HaversineProvider{}
+)
+
+// getDistanceProvider 按配置选择行驶距离服务：DISTANCE_PROVIDER 为 baidu、osrm（需配置 ROAD_GRAPH_FILE）或 haversine，
+// 未配置时使用直线距离。路网服务的结果缓存在 Mongo 中
+func getDistanceProvider() util.DistanceProvider {
+	distanceProviderOnce.Do(func() {
+		distanceProvider = haversineProvider
+		switch strings.ToLower(config.DistanceProvider) {
+		case "baidu":
+			if config.BaiduMapAk == "" {
+				config.Log.Warn("未配置百度地图 AK，行驶距离使用直线距离")
+				return
+			}
+			distanceProvider = &entity.CachedDistanceProvider{Provider: util.NewBaiduDistanceProvider(config.BaiduMapAk)}
+		case "osrm":
+			graph, err := util.LoadRoadGraph(config.RoadGraphFile)
+			if err != nil {
+				config.Log.Warn("加载路网文件失败，行驶距离使用直线距离", zap.Error(err))
+				return
+			}
+			distanceProvider = &entity.CachedDistanceProvider{Provider: graph}
+		}
+	})
+	return distanceProvider
+}
+
+// routeMatrix 计算行驶距离矩阵，路网服务出错时退化为直线距离，保证调度不因地图服务不可用而中断
+func routeMatrix(ctx context.Context, origins []common.LngLat, destinations []common.LngLat) [][]util.RouteCost {
+	provider := getDistanceProvider()
+	matrix, err := provider.Matrix(ctx, origins, destinations)
+	if err == nil {
+		return matrix
+	}
+	config.Log.Warn("行驶距离计算失败，使用直线距离", zap.String("provider", provider.Name()), zap.Error(err))
+	matrix, _ = haversineProvider.Matrix(ctx, origins, destinations)
+	return matrix
+}
+
+// routeCost 计算两点之间的行驶距离与时长
+func routeCost(ctx context.Context, origin common.LngLat, destination common.LngLat) util.RouteCost {
+	return routeMatrix(ctx, []common.LngLat{origin}, []common.LngLat{destination})[0][0]
+}
+
+// outletRouteDistances 计算 position 到各网点的行驶距离，单位公里
+func outletRouteDistances(ctx context.Context, position common.LngLat, outlets []*entity.Outlet) []float64 {
+	distances := make([]float64, len(outlets))
+	if len(outlets) == 0 {
+		return distances
+	}
+	destinations := make([]common.LngLat, len(outlets))
+	for i, outlet := range outlets {
+		destinations[i] = outlet.LngLat
+	}
+	for i, cost := range routeMatrix(ctx, []common.LngLat{position}, destinations)[0] {
+		distances[i] = cost.Distance
+	}
+	return distances
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"strconv"
+	"time"
+)
+
+// CreateDriver 创建司机
+func CreateDriver(c *gin.Context) {
+	name := c.PostForm("name")
+	phone := c.PostForm("phone")
+	licenseNo := c.PostForm("licenseNo")
+	licenseClass := c.PostForm("licenseClass")
+	licenseClassInt, err := strconv.Atoi(licenseClass)
+	status := c.PostForm("status")
+	statusInt, err2 := strconv.Atoi(status)
+	shiftsStr := c.PostForm("shifts")
+	remark := c.PostForm("remark")
+	password := c.PostForm("password")
+	if name == "" || phone == "" || licenseNo == "" || licenseClass == "" || status == "" ||
+		password == "" || err != nil || err2 != nil || !entity.LicenseClass(licenseClassInt).IsValid() ||
+		!entity.DriverStatus(statusInt).IsValid() {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	shifts, err := parseShifts(shiftsStr)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	// 司机端以手机号登录，密码不能包含手机号
+	if err = util.CheckPasswordPolicy(password, phone); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+
+	driver := &entity.Driver{
+		Name:         name,
+		Phone:        phone,
+		LicenseNo:    licenseNo,
+		LicenseClass: entity.LicenseClass(licenseClassInt),
+		Status:       entity.DriverStatus(statusInt),
+		Shifts:       shifts,
+		Remark:       remark,
+	}
+	driver.Password, err = util.HashPassword(password)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	err = entity.InsertDriver(driver)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// GetDriverList 获取司机列表
+func GetDriverList(c *gin.Context) {
+	var dto entity.FindDriverListDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	drivers, err := entity.GetDriverList(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, drivers)
+}
+
+// GetDriverTotalCount 获取司机总数
+func GetDriverTotalCount(c *gin.Context) {
+	var dto entity.FindDriverListDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	totalCount, err := entity.GetDriverTotalCount(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, totalCount)
+}
+
+// UpdateDriver 更新司机信息
+func UpdateDriver(c *gin.Context) {
+	driverId := c.PostForm("id")
+	name := c.PostForm("name")
+	phone := c.PostForm("phone")
+	licenseNo := c.PostForm("licenseNo")
+	licenseClass := c.PostForm("licenseClass")
+	licenseClassInt, err := strconv.Atoi(licenseClass)
+	status := c.PostForm("status")
+	statusInt, err2 := strconv.Atoi(status)
+	shiftsStr := c.PostForm("shifts")
+	remark := c.PostForm("remark")
+	password := c.PostForm("password")
+	if driverId == "" || name == "" || phone == "" || licenseNo == "" || licenseClass == "" || status == "" ||
+		err != nil || err2 != nil || !entity.LicenseClass(licenseClassInt).IsValid() ||
+		!entity.DriverStatus(statusInt).IsValid() {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	shifts, err := parseShifts(shiftsStr)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	// 传入密码时需满足密码策略，校验通过后再修改司机信息
+	if password != "" {
+		if err = util.CheckPasswordPolicy(password, phone); err != nil {
+			common.ErrorResponse(c, common.ValidateError(err.Error()))
+			return
+		}
+	}
+
+	existing, err := entity.GetDriverById(driverId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	// 降级驾照时需要确认仍能驾驶当前搭档的车辆
+	if existing.PlateNumber != "" {
+		vehicle, err := entity.GetVehicleById(existing.PlateNumber)
+		if err == nil && !entity.LicenseClass(licenseClassInt).CanDrive(vehicle.Type) {
+			common.ErrorResponse(c, common.ServerError(fmt.Sprintf("驾照等级不允许驾驶当前车辆%s", vehicle.Type)))
+			return
+		}
+	}
+
+	driver := &entity.Driver{
+		Name:         name,
+		Phone:        phone,
+		LicenseNo:    licenseNo,
+		LicenseClass: entity.LicenseClass(licenseClassInt),
+		Status:       entity.DriverStatus(statusInt),
+		Shifts:       shifts,
+		Remark:       remark,
+	}
+	err = entity.UpdateDriver(driverId, driver)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	// 离职时解除与车辆的搭档关系
+	if driver.Status == entity.DriverStatusResigned && existing.PlateNumber != "" {
+		if err = entity.AssignDriver(existing, ""); err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+	}
+	// 传入密码时重置司机端登录密码
+	if password != "" {
+		hash, err := util.HashPassword(password)
+		if err == nil {
+			err = entity.UpdateDriverPassword(driverId, hash, "")
+		}
+		if err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+	}
+	// 离职或重置密码后司机端需要重新登录
+	if password != "" || driver.Status == entity.DriverStatusResigned {
+		if err = revokeDriverSessions(driverId); err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+	}
+	common.SuccessResponse(c)
+}
+
+// DeleteDriver 删除司机
+func DeleteDriver(c *gin.Context) {
+	driverId := c.Query("driverId")
+	if driverId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	err := entity.DeleteDriver(driverId)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	if err = revokeDriverSessions(driverId); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// AssignDriver 为司机分配搭档车辆，plateNumber 为空时解除搭档
+func AssignDriver(c *gin.Context) {
+	driverId := c.PostForm("driverId")
+	plateNumber := c.PostForm("plateNumber")
+	if driverId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	driver, err := entity.GetDriverById(driverId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	if plateNumber != "" {
+		if driver.Status == entity.DriverStatusResigned {
+			common.ErrorResponse(c, common.ServerError("司机已离职，无法分配车辆"))
+			return
+		}
+		vehicle, err := entity.GetVehicleById(plateNumber)
+		if err != nil {
+			common.ErrorResponse(c, common.RecordNotFound)
+			return
+		}
+		if !driver.LicenseClass.CanDrive(vehicle.Type) {
+			common.ErrorResponse(c, common.ServerError(fmt.Sprintf("%s驾照不允许驾驶%s", driver.LicenseClass, vehicle.Type)))
+			return
+		}
+	}
+	err = entity.AssignDriver(driver, plateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// GetDriverAssignmentList 获取司机与车辆的搭档历史
+func GetDriverAssignmentList(c *gin.Context) {
+	var dto entity.FindDriverAssignmentListDTO
+	if err := bindScopedQuery(c, &dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	assignments, err := entity.GetDriverAssignmentList(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, assignments)
+}
+
+// parseShifts 解析并校验排班 JSON，为空时返回空排班
+func parseShifts(shiftsStr string) ([]entity.DriverShift, error) {
+	shifts := make([]entity.DriverShift, 0)
+	if shiftsStr == "" {
+		return shifts, nil
+	}
+	if err := json.Unmarshal([]byte(shiftsStr), &shifts); err != nil {
+		return nil, err
+	}
+	if err := entity.ValidateShifts(shifts); err != nil {
+		return nil, err
+	}
+	return shifts, nil
+}
+
+// getOnShiftDriver 获取车辆当前在班且驾照匹配的司机，没有时返回 nil
+func getOnShiftDriver(vehicle *entity.Vehicle, now time.Time) (*entity.Driver, error) {
+	driver, err := entity.GetDriverByVehicle(vehicle.PlateNumber)
+	if err != nil || driver == nil {
+		return nil, err
+	}
+	if !driver.IsOnShift(now) || !driver.LicenseClass.CanDrive(vehicle.Type) {
+		return nil, nil
+	}
+	return driver, nil
+}
+
+// filterVehiclesWithOnShiftDriver 过滤出当前有在班司机的车辆
+func filterVehiclesWithOnShiftDriver(vehicles []*entity.Vehicle) ([]*entity.Vehicle, error) {
+	now := time.Now()
+	var result []*entity.Vehicle
+	for _, vehicle := range vehicles {
+		driver, err := getOnShiftDriver(vehicle, now)
+		if err != nil {
+			return nil, err
+		}
+		if driver != nil {
+			result = append(result, vehicle)
+		}
+	}
+	return result, nil
+}
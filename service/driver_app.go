@@ -0,0 +1,458 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"io"
+	"slices"
+)
+
+const (
+	MaxPhotoSize = 4 << 20
+)
+
+// DriverLogin 司机端登录，使用手机号和密码
+func DriverLogin(c *gin.Context) {
+	phone := c.PostForm("phone")
+	password := c.PostForm("password")
+	if phone == "" || password == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	reservation, ok := reserveLoginAttempt(c, LoginClientDriverApp, phone)
+	if !ok {
+		return
+	}
+	driver, err := entity.GetDriverByPhone(phone)
+	if err != nil {
+		recordLoginFailure(c, reservation, "司机不存在")
+		common.ErrorResponse(c, common.UserNameOrPasswordError)
+		return
+	}
+	if driver.Status == entity.DriverStatusResigned {
+		rejectLogin(c, reservation, "司机已离职")
+		common.ErrorResponse(c, common.UserBanned)
+		return
+	}
+	valid, needsRehash := util.VerifyPassword(password, driver.Salt, driver.Password)
+	if !valid {
+		recordLoginFailure(c, reservation, "密码错误")
+		common.ErrorResponse(c, common.UserNameOrPasswordError)
+		return
+	}
+	// 旧版 MD5 哈希在密码校验通过后重新计算，失败不影响本次登录
+	if needsRehash {
+		hash, err := util.HashPassword(password)
+		if err == nil {
+			err = entity.RehashDriverPassword(driver.ID, driver.Password, hash)
+		}
+		if err != nil {
+			config.Log.Warn("重新计算司机密码哈希失败", zap.String("driverId", driver.ID.Hex()), zap.Error(err))
+		}
+	}
+	refreshToken, sessionId, err := createSession(c, driver.ID.Hex(), util.RoleDriver)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	token, err := util.GenerateDriverToken(driver.ID.Hex(), sessionId)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	recordLoginSuccess(c, reservation)
+	respondTokens(c, token, refreshToken)
+	common.SuccessResponseWithData(c, driver)
+}
+
+// GetDriverProfile 获取当前司机信息
+func GetDriverProfile(c *gin.Context) {
+	driver, ok := getCurrentDriver(c)
+	if !ok {
+		return
+	}
+	common.SuccessResponseWithData(c, driver)
+}
+
+// GetDriverManifest 获取当前司机车辆上运输中的订单
+func GetDriverManifest(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	driver, ok := getCurrentDriverWithVehicle(c)
+	if !ok {
+		return
+	}
+	orders, err := entity.GetProcessingOrdersByVehicle(driver.PlateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	if orders == nil {
+		orders = make([]*entity.Order, 0)
+	}
+	for _, order := range orders {
+		order.ConvertCRS(crs)
+	}
+	common.SuccessResponseWithData(c, orders)
+}
+
+// ConfirmPickup 司机确认取件
+func ConfirmPickup(c *gin.Context) {
+	orderId := c.PostForm("orderId")
+	if orderId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	position, err := reportedLocation(c)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	driver, ok := getCurrentDriverWithVehicle(c)
+	if !ok {
+		return
+	}
+	err = entity.ConfirmOrderPickup(orderId, driver.PlateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	// 首次取件视为趟次发车
+	_ = entity.MarkTripDeparture(driver.PlateNumber)
+	_ = entity.InsertDriverEvent(&entity.DriverEvent{
+		Type:        entity.DriverEventPickup,
+		DriverID:    driver.ID.Hex(),
+		PlateNumber: driver.PlateNumber,
+		OrderID:     orderId,
+		LngLat:      position,
+	})
+	common.SuccessResponse(c)
+}
+
+// ReportArrival 司机上报到达网点，同时更新车辆位置
+func ReportArrival(c *gin.Context) {
+	outletId := c.PostForm("outletId")
+	if outletId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	driver, ok := getCurrentDriverWithVehicle(c)
+	if !ok {
+		return
+	}
+	outlet, err := entity.GetOutletById(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	vehicle, err := entity.GetVehicleById(driver.PlateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+
+	vehicleMu := util.GetVehicleLock(vehicle.PlateNumber)
+	vehicleMu.Lock()
+	vehicle.LngLat = outlet.LngLat
+	err = entity.UpdateVehicle(vehicle)
+	vehicleMu.Unlock()
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+
+	err = entity.InsertDriverEvent(&entity.DriverEvent{
+		Type:        entity.DriverEventArrival,
+		DriverID:    driver.ID.Hex(),
+		PlateNumber: driver.PlateNumber,
+		OutletID:    outletId,
+		LngLat:      outlet.LngLat,
+		Description: outlet.Name,
+	})
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// ConfirmDelivery 司机确认单个订单送达，同时释放车辆载重
+func ConfirmDelivery(c *gin.Context) {
+	orderId := c.PostForm("orderId")
+	if orderId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	position, err := reportedLocation(c)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	driver, ok := getCurrentDriverWithVehicle(c)
+	if !ok {
+		return
+	}
+
+	orderMu := util.GetOrderLock(orderId)
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	// 在订单锁内读取订单，避免基于并发修改前的中转信息与重量处理
+	order, err := entity.GetOrderById(orderId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	// 送往中转枢纽的订单交接后等待下一段调度
+	if order.IsTransferLeg() {
+		err = entity.ArriveOrderAtTransfer(order, driver.PlateNumber)
+	} else {
+		err = entity.ConfirmOrderDelivery(orderId, driver.PlateNumber)
+	}
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+
+	vehicleMu := util.GetVehicleLock(driver.PlateNumber)
+	vehicleMu.Lock()
+	defer vehicleMu.Unlock()
+	vehicle, err := entity.GetVehicleById(driver.PlateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	const precisionFactor = 10000
+	vehicle.CurrentLoad = roundToPrecision(vehicle.CurrentLoad-order.Weight, precisionFactor)
+	if vehicle.CurrentLoad < 0 {
+		vehicle.CurrentLoad = 0
+	}
+	err = entity.UpdateVehicle(vehicle)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	// 车上最后一单确认送达后结束趟次，派送计划的趟次在返回网点时结束
+	remaining, err := entity.GetProcessingOrdersByVehicle(vehicle.PlateNumber)
+	if err == nil && len(remaining) == 0 {
+		err = closeLineHaulTrip(vehicle)
+	}
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+
+	_ = entity.InsertDriverEvent(&entity.DriverEvent{
+		Type:        entity.DriverEventDelivery,
+		DriverID:    driver.ID.Hex(),
+		PlateNumber: driver.PlateNumber,
+		OrderID:     orderId,
+		LngLat:      position,
+	})
+	if order.IsTransferLeg() {
+		recordOrderEvent(&entity.OrderEvent{
+			OrderID:     orderId,
+			Type:        entity.OrderEventTransfer,
+			OutletID:    order.LegOutletId,
+			PlateNumber: driver.PlateNumber,
+		})
+		_ = taskPool.Submit(func() {
+			completeDataOrder(orderId)
+		})
+	} else {
+		recordOrderEvent(&entity.OrderEvent{
+			OrderID:     orderId,
+			Type:        entity.OrderEventCompleted,
+			OutletID:    order.EndOutletId,
+			PlateNumber: driver.PlateNumber,
+		})
+	}
+	common.SuccessResponse(c)
+}
+
+// ReportIncident 司机上报运输异常
+func ReportIncident(c *gin.Context) {
+	description := c.PostForm("description")
+	if description == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	position, err := reportedLocation(c)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	driver, ok := getCurrentDriver(c)
+	if !ok {
+		return
+	}
+	orderId := c.PostForm("orderId")
+	if !checkDriverOrder(c, driver, orderId) {
+		return
+	}
+	err = entity.InsertDriverEvent(&entity.DriverEvent{
+		Type:        entity.DriverEventIncident,
+		DriverID:    driver.ID.Hex(),
+		PlateNumber: driver.PlateNumber,
+		OrderID:     orderId,
+		LngLat:      position,
+		Description: description,
+	})
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// UploadDriverPhoto 司机上传照片（签收、货损等），可关联订单
+func UploadDriverPhoto(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	defer file.Close()
+	if header.Size > MaxPhotoSize {
+		common.ErrorResponse(c, common.ServerError("仅支持4MB以下的照片！"))
+		return
+	}
+	position, err := reportedLocation(c)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	driver, ok := getCurrentDriver(c)
+	if !ok {
+		return
+	}
+	orderId := c.PostForm("orderId")
+	if !checkDriverOrder(c, driver, orderId) {
+		return
+	}
+	fileData, err := io.ReadAll(file)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError("文件读取错误！"))
+		return
+	}
+	photo := &entity.File{
+		FileName:    header.Filename,
+		FileType:    entity.DriverPhoto,
+		FileSize:    header.Size,
+		ContentType: header.Header.Get("Content-Type"),
+		FileData:    fileData,
+	}
+	err = entity.InsertFile(c.Request.Context(), photo)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError("文件上传失败！"))
+		return
+	}
+	err = entity.InsertDriverEvent(&entity.DriverEvent{
+		Type:        entity.DriverEventPhoto,
+		DriverID:    driver.ID.Hex(),
+		PlateNumber: driver.PlateNumber,
+		OrderID:     orderId,
+		FileID:      photo.ID.Hex(),
+		LngLat:      position,
+		Description: c.PostForm("description"),
+	})
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, photo.ID.Hex())
+}
+
+// GetDriverEventList 管理端查询司机上报的事件
+func GetDriverEventList(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	var dto entity.FindDriverEventListDTO
+	if err := bindScopedQuery(c, &dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	events, err := entity.GetDriverEventList(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	for _, event := range events {
+		event.ConvertCRS(crs)
+	}
+	common.SuccessResponseWithData(c, events)
+}
+
+// checkDriverOrder 校验上报关联的订单在司机当前车辆上或由车辆本趟次运送，orderId 为空时不校验，失败时直接写入错误响应
+func checkDriverOrder(c *gin.Context, driver *entity.Driver, orderId string) bool {
+	if orderId == "" {
+		return true
+	}
+	order, err := entity.GetOrderById(orderId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return false
+	}
+	if driver.PlateNumber != "" {
+		if order.TransPortVehicle == driver.PlateNumber {
+			return true
+		}
+		// 已在中转网点交接的订单不再关联车辆，按趟次记录判断
+		trip, err := entity.GetOpenTripByVehicle(driver.PlateNumber)
+		if err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return false
+		}
+		if trip != nil && slices.Contains(trip.OrderIDs, orderId) {
+			return true
+		}
+	}
+	common.ErrorResponse(c, common.PermissionDenied)
+	return false
+}
+
+// reportedLocation 读取司机上报的位置并转换为存储坐标系，未上报位置时返回零值
+func reportedLocation(c *gin.Context) (common.LngLat, error) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		return common.LngLat{}, err
+	}
+	return parseStorageLngLat(c.PostForm("lng"), c.PostForm("lat"), crs)
+}
+
+// getCurrentDriver 获取 Token 对应的司机，失败时直接写入错误响应
+func getCurrentDriver(c *gin.Context) (*entity.Driver, bool) {
+	driverId := c.GetString("driverId")
+	if driverId == "" {
+		common.ErrorResponse(c, common.NotLogin)
+		return nil, false
+	}
+	driver, err := entity.GetDriverById(driverId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return nil, false
+	}
+	return driver, true
+}
+
+// getCurrentDriverWithVehicle 获取当前司机，并要求其已搭档车辆
+func getCurrentDriverWithVehicle(c *gin.Context) (*entity.Driver, bool) {
+	driver, ok := getCurrentDriver(c)
+	if !ok {
+		return nil, false
+	}
+	if driver.PlateNumber == "" {
+		common.ErrorResponse(c, common.ServerError("当前司机未分配车辆"))
+		return nil, false
+	}
+	return driver, true
+}
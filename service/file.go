@@ -93,7 +93,7 @@ func DeleteFile(c *gin.Context) {
 
 func GetFileList(c *gin.Context) {
 	var dto entity.FindFileListDTO
-	if err := c.ShouldBindJSON(&dto); err != nil {
+	if err := bindScopedQuery(c, &dto); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"io"
+	"strings"
+	"sync"
+)
+
+// MaxPOIFileSize 离线地址文件的大小上限
+const MaxPOIFileSize = 32 << 20
+
+// poiColumns 离线地址 CSV 文件的表头
+var poiColumns = []string{"name", "address", "province", "city", "district", "lng", "lat"}
+
+var (
+	geocoder     util.Geocoder
+	geocoderOnce sync.Once
+)
+
+// getGeocoder 按配置选择地址解析服务：GEOCODER_PROVIDER 为 baidu 或 offline，
+// 未配置时有百度地图 AK 则使用百度，否则使用离线地址库
+func getGeocoder() util.Geocoder {
+	geocoderOnce.Do(func() {
+		provider := strings.ToLower(config.GeocoderProvider)
+		if provider == "" && config.BaiduMapAk != "" {
+			provider = "baidu"
+		}
+		switch provider {
+		case "baidu":
+			geocoder = util.NewBaiduGeocoder(config.BaiduMapAk)
+		default:
+			geocoder = &entity.OfflineGeocoder{}
+		}
+	})
+	return geocoder
+}
+
+// resolveAddress 解析地址坐标，返回存储坐标系下的经纬度
+func resolveAddress(ctx context.Context, address string) (common.LngLat, error) {
+	result, err := getGeocoder().Geocode(ctx, address, "")
+	if err != nil {
+		return common.LngLat{}, err
+	}
+	result.ConvertCRS(util.StorageCRS)
+	if err = result.Validate(); err != nil {
+		return common.LngLat{}, err
+	}
+	return result.LngLat, nil
+}
+
+// Geocode 地址解析
+func Geocode(c *gin.Context) {
+	address := c.Query("address")
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if address == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	result, err := getGeocoder().Geocode(c.Request.Context(), address, c.Query("city"))
+	if err != nil {
+		respondGeocodeError(c, err)
+		return
+	}
+	result.ConvertCRS(crs)
+	common.SuccessResponseWithData(c, result)
+}
+
+// ReverseGeocode 逆地址解析
+func ReverseGeocode(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	position, err := common.ParseLngLat(c.Query("lng"), c.Query("lat"))
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	result, err := getGeocoder().ReverseGeocode(c.Request.Context(), util.ConvertLngLat(position, crs, util.StorageCRS))
+	if err != nil {
+		respondGeocodeError(c, err)
+		return
+	}
+	result.ConvertCRS(crs)
+	common.SuccessResponseWithData(c, result)
+}
+
+// ImportGeoPOI 导入离线地址库，CSV 表头为 name,address,province,city,district,lng,lat，
+// 坐标默认为 WGS-84，可通过 crs 参数指定
+func ImportGeoPOI(c *gin.Context) {
+	crs, err := requestCRS(c, util.StorageCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	defer file.Close()
+	if header.Size > MaxPOIFileSize {
+		common.ErrorResponse(c, common.ServerError("仅支持32MB以下的地址文件！"))
+		return
+	}
+	pois, err := parsePOICSV(file)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	for _, poi := range pois {
+		poi.LngLat = util.ConvertLngLat(poi.LngLat, crs, util.StorageCRS)
+	}
+	inserted, updated, err := entity.UpsertGeoPOIs(pois)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, gin.H{
+		"total":    len(pois),
+		"inserted": inserted,
+		"updated":  updated,
+	})
+}
+
+// parsePOICSV 解析离线地址 CSV 文件
+func parsePOICSV(reader io.Reader) ([]*entity.GeoPOI, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	headers, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+	index := make(map[string]int, len(headers))
+	for i, name := range headers {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, column := range poiColumns {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("缺少列: %s", column)
+		}
+	}
+	var pois []*entity.GeoPOI
+	for line := 2; ; line++ {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第%d行格式错误: %w", line, err)
+		}
+		position, err := common.ParseLngLat(record[index["lng"]], record[index["lat"]])
+		if err != nil {
+			return nil, fmt.Errorf("第%d行坐标错误: %w", line, err)
+		}
+		poi := &entity.GeoPOI{
+			Name:     record[index["name"]],
+			Address:  record[index["address"]],
+			Province: record[index["province"]],
+			City:     record[index["city"]],
+			District: record[index["district"]],
+			LngLat:   position,
+		}
+		if poi.Name == "" && poi.Address == "" {
+			return nil, fmt.Errorf("第%d行名称与地址不能同时为空", line)
+		}
+		pois = append(pois, poi)
+	}
+	return pois, nil
+}
+
+// respondGeocodeError 地址无法解析时返回校验错误，其余返回服务器错误
+func respondGeocodeError(c *gin.Context, err error) {
+	if errors.Is(err, util.ErrAddressNotFound) {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	common.ErrorResponse(c, common.ServerError(err.Error()))
+}
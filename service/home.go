@@ -4,10 +4,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"go_logistics/common"
 	"go_logistics/model/entity"
+	"go_logistics/model/vo"
+	"go_logistics/util"
+	"sort"
 	"time"
 )
 
 func GetOutletView(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
 	outlets, err := entity.GetOutletList(entity.FindOutletListDTO{
 		Page: common.Page{
 			Skip:  1,
@@ -16,8 +24,114 @@ func GetOutletView(c *gin.Context) {
 	})
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	convertOutletsCRS(outlets, crs)
+	// 附带上下级关系，前端据此绘制枢纽与下级网点的连线
+	common.SuccessResponseWithData(c, vo.ToOutletMapVOList(outlets))
+}
+
+// HubStat 区域枢纽维度的汇总数据，订单统计口径与订单视图相同（近七天）
+type HubStat struct {
+	HubID       string `json:"hubId"` // 为空表示未归属任何枢纽的网点
+	HubName     string `json:"hubName"`
+	OutletCount int    `json:"outletCount"` // 枢纽自身及下属网点数
+	Outbound    int    `json:"outbound"`    // 起点网点属于该枢纽的订单数
+	Inbound     int    `json:"inbound"`     // 终点网点属于该枢纽的订单数
+	Transfer    int    `json:"transfer"`    // 在该枢纽中转的订单数
+	Pending     int    `json:"pending"`
+	Processing  int    `json:"processing"`
+	Completed   int    `json:"completed"`
+	Canceled    int    `json:"canceled"`
+}
+
+// GetHubView 按区域枢纽汇总网点与近七天的订单，订单在数据库中按网点与状态聚合后再归属到枢纽
+func GetHubView(c *gin.Context) {
+	outlets, err := entity.GetOutletHierarchy()
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	flows, err := entity.GetOutletFlowStats(yesterday.AddDate(0, 0, -6), yesterday, outletScopeOf(c))
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, GroupByHub(outlets, flows))
+}
+
+// GroupByHub 将网点与按网点聚合的订单数归属到区域枢纽，枢纽按出港订单数降序排列，未归属枢纽的排在最后
+func GroupByHub(outlets []*entity.Outlet, flows []*entity.OutletFlowStat) []*HubStat {
+	outletMap := make(map[string]*entity.Outlet, len(outlets))
+	for _, outlet := range outlets {
+		outletMap[outlet.ID.Hex()] = outlet
+	}
+	stats := make(map[string]*HubStat)
+	statOf := func(hubId string) *HubStat {
+		stat, ok := stats[hubId]
+		if !ok {
+			stat = &HubStat{HubID: hubId, HubName: "未归属枢纽"}
+			if hub, ok := outletMap[hubId]; ok {
+				stat.HubName = hub.Name
+			}
+			stats[hubId] = stat
+		}
+		return stat
+	}
+	for _, outlet := range outlets {
+		statOf(entity.ResolveHubId(outlet.ID.Hex(), outletMap)).OutletCount++
 	}
-	common.SuccessResponseWithData(c, outlets)
+
+	for _, flow := range flows {
+		startHub := entity.ResolveHubId(flow.StartOutletId, outletMap)
+		endHub := entity.ResolveHubId(flow.EndOutletId, outletMap)
+		statOf(startHub).Outbound += flow.Count
+		statOf(endHub).Inbound += flow.Count
+		// 中转枢纽：送往或停留在非终点所属的枢纽
+		for _, outletId := range []string{flow.LegOutletId, flow.CurrentOutletId} {
+			if outletId == "" {
+				continue
+			}
+			if hubId := entity.ResolveHubId(outletId, outletMap); hubId != "" && hubId != endHub && hubId != startHub {
+				statOf(hubId).Transfer += flow.Count
+				break
+			}
+		}
+		touched := []string{startHub}
+		if endHub != startHub {
+			touched = append(touched, endHub)
+		}
+		for _, hubId := range touched {
+			stat := statOf(hubId)
+			switch flow.Status {
+			case entity.Pending:
+				stat.Pending += flow.Count
+			case entity.Processing:
+				stat.Processing += flow.Count
+			case entity.Completed:
+				stat.Completed += flow.Count
+			case entity.Cancelled:
+				stat.Canceled += flow.Count
+			}
+		}
+	}
+
+	result := make([]*HubStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if (result[i].HubID == "") != (result[j].HubID == "") {
+			return result[j].HubID == ""
+		}
+		if result[i].Outbound != result[j].Outbound {
+			return result[i].Outbound > result[j].Outbound
+		}
+		return result[i].HubName < result[j].HubName
+	})
+	return result
 }
 
 func GetOrderView(c *gin.Context) {
@@ -32,6 +146,7 @@ func GetOrderView(c *gin.Context) {
 			Skip:  1,
 			Limit: 10000,
 		},
+		Scope: outletScopeOf(c),
 	}
 
 	orders, err := entity.GetOrderList(dto)
@@ -94,6 +209,7 @@ func GetVehicleView(c *gin.Context) {
 			Skip:  1,
 			Limit: 10000,
 		},
+		Scope: outletScopeOf(c),
 	}
 
 	vehicles, err := entity.GetVehicleList(dto)
@@ -135,6 +251,7 @@ func GetRouteView(c *gin.Context) {
 			Skip:  1,
 			Limit: 10000,
 		},
+		Scope: outletScopeOf(c),
 	})
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
@@ -167,3 +284,22 @@ func GroupRoutesByType(routes []*entity.Route) map[string]float64 {
 
 	return result
 }
+
+// GetFleetView 车队利用率与成本分析，按车型或线路分组
+func GetFleetView(c *gin.Context) {
+	var dto entity.FleetStatDTO
+	if err := bindScopedQuery(c, &dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	if !dto.StartTime.IsZero() && !dto.EndTime.IsZero() && dto.EndTime.Before(dto.StartTime) {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	result, err := entity.GetFleetStats(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, result)
+}
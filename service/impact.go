@@ -0,0 +1,176 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/model/vo"
+	"go_logistics/util"
+)
+
+// GetOutletImpact 查询关闭网点影响的在途订单与车辆
+func GetOutletImpact(c *gin.Context) {
+	outletId := c.Query("outletId")
+	if outletId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	outlet, err := entity.GetOutletById(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	impact, err := buildOutletImpact(outlet)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, impact)
+}
+
+// GetRouteImpact 查询停用线路影响的在途订单与车辆
+func GetRouteImpact(c *gin.Context) {
+	routeId := c.Query("routeId")
+	if routeId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	route, err := entity.GetRouteById(routeId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	impact, err := buildRouteImpact(route)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, impact)
+}
+
+// RedispatchOrder 撤销订单当前的调度结果并重新调度，已取件的订单不能重新调度
+func RedispatchOrder(c *gin.Context) {
+	orderId := c.PostForm("orderId")
+	if orderId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	if err := redispatchOrder(orderId); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// buildOutletImpact 统计起终点为该网点的在途订单，以及途经该网点线路上的车辆
+func buildOutletImpact(outlet *entity.Outlet) (*vo.StatusImpactVO, error) {
+	impact := &vo.StatusImpactVO{
+		TargetType: "outlet",
+		TargetID:   outlet.ID.Hex(),
+		TargetName: outlet.Name,
+		Orders:     make([]vo.ImpactedOrder, 0),
+		Vehicles:   make([]vo.ImpactedVehicle, 0),
+	}
+	orders, err := entity.GetInFlightOrdersByOutlet(outlet.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		impact.Orders = append(impact.Orders, vo.ToImpactedOrder(order))
+	}
+	routes, err := entity.GetRoutesByOutletId(outlet.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		vehicles, err := entity.GetVehicleList(entity.FindVehicleListDTO{RouteID: route.RouteID})
+		if err != nil {
+			return nil, err
+		}
+		for _, vehicle := range vehicles {
+			impact.Vehicles = append(impact.Vehicles, vo.ToImpactedVehicle(vehicle))
+		}
+	}
+	return impact, nil
+}
+
+// buildRouteImpact 统计走该线路的在途订单与线路上的车辆
+func buildRouteImpact(route *entity.Route) (*vo.StatusImpactVO, error) {
+	impact := &vo.StatusImpactVO{
+		TargetType: "route",
+		TargetID:   route.RouteID,
+		TargetName: route.Name,
+		Orders:     make([]vo.ImpactedOrder, 0),
+		Vehicles:   make([]vo.ImpactedVehicle, 0),
+	}
+	vehicles, err := entity.GetVehicleList(entity.FindVehicleListDTO{RouteID: route.RouteID})
+	if err != nil {
+		return nil, err
+	}
+	plateNumbers := make([]string, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		plateNumbers = append(plateNumbers, vehicle.PlateNumber)
+		impact.Vehicles = append(impact.Vehicles, vo.ToImpactedVehicle(vehicle))
+	}
+	orders, err := entity.GetInFlightOrdersByRoute(route.RouteID, plateNumbers)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		impact.Orders = append(impact.Orders, vo.ToImpactedOrder(order))
+	}
+	return impact, nil
+}
+
+// redispatchOrder 释放订单占用的车辆载重并移出趟次，订单回到待处理后重新提交调度
+func redispatchOrder(orderId string) error {
+	orderMu := util.GetOrderLock(orderId)
+	orderMu.Lock()
+	order, err := entity.GetOrderById(orderId)
+	if err != nil {
+		orderMu.Unlock()
+		return err
+	}
+	if err = entity.ResetOrderDispatch(orderId, "重新调度中"); err != nil {
+		orderMu.Unlock()
+		return err
+	}
+	// 尚在起点的订单重新调度时重新选择收件网点，先释放原来占用的收件名额
+	if order.CurrentOutletId == "" {
+		releaseOrderIntake(orderId)
+	}
+	if order.Status == entity.Processing && order.TransPortVehicle != "" {
+		if err = releaseVehicleLoad(order.TransPortVehicle, order.Weight); err != nil {
+			config.Log.Warn("释放车辆载重失败！", zap.String("orderId", orderId), zap.Error(err))
+		}
+		if order.TripID != "" {
+			if err = entity.RemoveOrderFromTrip(order.TripID, orderId, order.Weight); err != nil {
+				config.Log.Warn("移出车辆趟次失败！", zap.String("orderId", orderId), zap.Error(err))
+			}
+		}
+	}
+	orderMu.Unlock()
+
+	return taskPool.Submit(func() {
+		completeDataOrder(orderId)
+	})
+}
+
+// releaseVehicleLoad 从车辆当前载重中扣除订单重量
+func releaseVehicleLoad(plateNumber string, weight float64) error {
+	vehicleMu := util.GetVehicleLock(plateNumber)
+	vehicleMu.Lock()
+	defer vehicleMu.Unlock()
+	vehicle, err := entity.GetVehicleById(plateNumber)
+	if err != nil {
+		return err
+	}
+	const precisionFactor = 10000
+	vehicle.CurrentLoad = roundToPrecision(vehicle.CurrentLoad-weight, precisionFactor)
+	if vehicle.CurrentLoad < 0 {
+		vehicle.CurrentLoad = 0
+	}
+	return entity.UpdateVehicle(vehicle)
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"math"
+	"strconv"
+	"time"
+)
+
+// 登录入口，用于区分账号的失败统计与审计
+const (
+	LoginClientAdmin     = "admin"     // 后台用户，账号为用户名
+	LoginClientDriverApp = "driverApp" // 司机端，账号为手机号
+)
+
+// loginThrottlePolicy 登录失败的限制策略
+type loginThrottlePolicy struct {
+	maxFailures  int           // 统计窗口内失败达到该次数后锁定
+	lockDuration time.Duration // 锁定时长
+	delayAfter   int           // 失败超过该次数后，每次失败后需等待的时长按 2 的幂递增
+}
+
+const (
+	// loginFailureWindow 距上次失败超过该时长后重新计数
+	loginFailureWindow = 15 * time.Minute
+	// loginBaseDelay 渐进等待的起始时长，loginMaxDelay 为上限
+	loginBaseDelay = time.Second
+	loginMaxDelay  = 30 * time.Second
+	// loginReserveRetries 预占登录尝试时与并发请求冲突的最大重试次数
+	loginReserveRetries = 3
+)
+
+var (
+	// accountThrottlePolicy 同一账号的限制，防止针对单个账号猜测密码
+	accountThrottlePolicy = loginThrottlePolicy{maxFailures: 5, lockDuration: 15 * time.Minute, delayAfter: 2}
+	// ipThrottlePolicy 同一 IP 的限制，防止同一来源轮换账号猜测密码
+	ipThrottlePolicy = loginThrottlePolicy{maxFailures: 20, lockDuration: 15 * time.Minute, delayAfter: 10}
+)
+
+// retryAfter 根据失败统计计算还需等待的时长，为 0 表示可以登录
+func (p loginThrottlePolicy) retryAfter(throttle *entity.LoginThrottle, now time.Time) time.Duration {
+	if throttle == nil {
+		return 0
+	}
+	if throttle.IsLocked(now) {
+		return throttle.LockedUntil.Time().Sub(now)
+	}
+	lastFailure := throttle.LastFailure.Time()
+	if throttle.Failures <= p.delayAfter || now.Sub(lastFailure) > loginFailureWindow {
+		return 0
+	}
+	delay := time.Duration(float64(loginBaseDelay) * math.Pow(2, float64(throttle.Failures-p.delayAfter-1)))
+	return max(min(delay, loginMaxDelay)-now.Sub(lastFailure), 0)
+}
+
+func accountThrottleKey(client string, name string) string {
+	return client + ":" + name
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginReservation 校验密码前预占的登录尝试
+type loginReservation struct {
+	client     string
+	name       string
+	accountKey string
+	ipKey      string
+	account    *entity.LoginThrottle // 预占后的账号统计
+}
+
+// reserveThrottle 预占一个键的登录尝试，处于锁定或渐进等待中时返回需等待的时长。
+// 并发请求修改了统计时重新读取后再判断，多次冲突时按起始等待时长拒绝
+func reserveThrottle(key string, policy loginThrottlePolicy, now time.Time) (*entity.LoginThrottle, time.Duration, error) {
+	for range loginReserveRetries {
+		throttles, err := entity.GetLoginThrottles([]string{key})
+		if err != nil {
+			return nil, 0, err
+		}
+		if wait := policy.retryAfter(throttles[key], now); wait > 0 {
+			return nil, wait, nil
+		}
+		throttle, reserved, err := entity.ReserveLoginAttempt(key, throttles[key], now, loginFailureWindow,
+			policy.maxFailures, policy.lockDuration)
+		if err != nil {
+			return nil, 0, err
+		}
+		if reserved {
+			return throttle, 0, nil
+		}
+	}
+	return nil, loginBaseDelay, nil
+}
+
+// reserveLoginAttempt 校验密码前原子地为 IP 与账号各预占一次尝试，预占按失败计数，登录成功后撤销；
+// 账号或 IP 处于锁定或渐进等待中时记录审计并返回错误
+func reserveLoginAttempt(c *gin.Context, client string, name string) (*loginReservation, bool) {
+	reservation := &loginReservation{
+		client:     client,
+		name:       name,
+		accountKey: accountThrottleKey(client, name),
+		ipKey:      ipThrottleKey(c.ClientIP()),
+	}
+	now := time.Now()
+	_, wait, err := reserveThrottle(reservation.ipKey, ipThrottlePolicy, now)
+	if err == nil && wait <= 0 {
+		reservation.account, wait, err = reserveThrottle(reservation.accountKey, accountThrottlePolicy, now)
+		if err != nil || wait > 0 {
+			releaseThrottle(reservation.ipKey, ipThrottlePolicy)
+		}
+	}
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return nil, false
+	}
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		auditLogin(c, client, name, false, "登录受限")
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		common.ErrorResponse(c, common.LoginThrottled(seconds))
+		return nil, false
+	}
+	return reservation, true
+}
+
+// releaseThrottle 撤销一个键预占的登录尝试，失败只记录日志
+func releaseThrottle(key string, policy loginThrottlePolicy) {
+	if err := entity.ReleaseLoginAttempt(key, policy.maxFailures); err != nil {
+		config.Log.Warn("撤销登录尝试失败", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// recordLoginFailure 登录失败，预占的尝试保留为失败次数并记录审计
+func recordLoginFailure(c *gin.Context, reservation *loginReservation, reason string) {
+	auditLogin(c, reservation.client, reservation.name, false, reason)
+	if reservation.account != nil && reservation.account.Failures == accountThrottlePolicy.maxFailures {
+		config.Log.Warn("账号登录失败次数过多，已临时锁定", zap.String("client", reservation.client),
+			zap.String("name", reservation.name), zap.String("ip", c.ClientIP()))
+	}
+}
+
+// rejectLogin 因账号状态拒绝登录，未校验密码，撤销预占的尝试并记录审计
+func rejectLogin(c *gin.Context, reservation *loginReservation, reason string) {
+	releaseThrottle(reservation.accountKey, accountThrottlePolicy)
+	releaseThrottle(reservation.ipKey, ipThrottlePolicy)
+	auditLogin(c, reservation.client, reservation.name, false, reason)
+}
+
+// recordLoginSuccess 登录成功后清除账号的失败次数、撤销 IP 预占的尝试并记录审计，IP 此前的失败次数不清除
+func recordLoginSuccess(c *gin.Context, reservation *loginReservation) {
+	if _, err := entity.ClearLoginThrottle(reservation.accountKey); err != nil {
+		config.Log.Warn("清除登录失败次数失败", zap.String("name", reservation.name), zap.Error(err))
+	}
+	releaseThrottle(reservation.ipKey, ipThrottlePolicy)
+	auditLogin(c, reservation.client, reservation.name, true, "")
+}
+
+// auditLogin 记录登录审计，写入失败不影响登录
+func auditLogin(c *gin.Context, client string, name string, success bool, reason string) {
+	audit := &entity.LoginAudit{
+		Client:    client,
+		Name:      name,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	}
+	if err := entity.InsertLoginAudit(audit); err != nil {
+		config.Log.Warn("记录登录审计失败", zap.String("name", name), zap.Error(err))
+	}
+}
+
+// UnlockLogin 管理员解除账号或 IP 的登录锁定，client 为空时按后台用户处理
+func UnlockLogin(c *gin.Context) {
+	name := c.PostForm("name")
+	ip := c.PostForm("ip")
+	client := c.DefaultPostForm("client", LoginClientAdmin)
+	if (name == "" && ip == "") || (client != LoginClientAdmin && client != LoginClientDriverApp) {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	unlocked := false
+	if name != "" {
+		cleared, err := entity.ClearLoginThrottle(accountThrottleKey(client, name))
+		if err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		unlocked = unlocked || cleared
+	}
+	if ip != "" {
+		cleared, err := entity.ClearLoginThrottle(ipThrottleKey(ip))
+		if err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		unlocked = unlocked || cleared
+	}
+	if !unlocked {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	config.Log.Info("管理员解除登录锁定", zap.String("operator", c.GetString("name")),
+		zap.String("client", client), zap.String("name", name), zap.String("ip", ip))
+	common.SuccessResponse(c)
+}
+
+// GetLoginAuditList 获取登录审计列表
+func GetLoginAuditList(c *gin.Context) {
+	var dto entity.FindLoginAuditListDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	audits, err := entity.GetLoginAuditList(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, audits)
+}
+
+// GetLoginAuditTotalCount 获取登录审计总数
+func GetLoginAuditTotalCount(c *gin.Context) {
+	var dto entity.FindLoginAuditListDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	count, err := entity.GetLoginAuditTotalCount(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, count)
+}
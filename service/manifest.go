@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+	"go_logistics/model/vo"
+	"go_logistics/util"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// GetVehicleManifest 获取车辆当前趟次的装载清单
+func GetVehicleManifest(c *gin.Context) {
+	plateNumber := c.Query("plateNumber")
+	if plateNumber == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	manifest, err := buildVehicleManifest(plateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, manifest)
+}
+
+// DownloadVehicleManifest 下载可打印的 PDF 装载清单
+func DownloadVehicleManifest(c *gin.Context) {
+	plateNumber := c.Query("plateNumber")
+	if plateNumber == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	manifest, err := buildVehicleManifest(plateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	data := renderManifestPDF(manifest)
+	fileName := fmt.Sprintf("manifest_%s_%s.pdf", plateNumber, time.Now().Format("20060102150405"))
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(fileName)))
+	c.Header("Content-Length", strconv.Itoa(len(data)))
+	c.Data(200, "application/pdf", data)
+}
+
+// manifestStop 计算卸货顺序时使用的站点信息
+type manifestStop struct {
+	order      *entity.Order
+	outletName string
+	pointIndex int     // 最近的线路点序号
+	offset     float64 // 与最近线路点（或车辆位置）的距离，单位公里
+}
+
+// buildVehicleManifest 组装车辆装载清单：汇总运输中订单的重量体积，并按“后卸先装”给出装车顺序
+func buildVehicleManifest(plateNumber string) (*vo.ManifestVO, error) {
+	vehicle, err := entity.GetVehicleById(plateNumber)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := entity.GetProcessingOrdersByVehicle(plateNumber)
+	if err != nil {
+		return nil, err
+	}
+	var route *entity.Route
+	if vehicle.RouteID != "" {
+		route, _ = entity.GetRouteById(vehicle.RouteID)
+	}
+
+	manifest := &vo.ManifestVO{
+		PlateNumber:       vehicle.PlateNumber,
+		VehicleType:       vehicle.Type,
+		RouteID:           vehicle.RouteID,
+		RouteName:         vehicle.RouteName,
+		LoadCapacity:      vehicle.LoadCapacity,
+		CurrentLoad:       vehicle.CurrentLoad,
+		RemainingCapacity: roundToPrecision(math.Max(vehicle.LoadCapacity-vehicle.CurrentLoad, 0), 10000),
+		OrderCount:        len(orders),
+		Items:             make([]vo.ManifestItem, 0, len(orders)),
+	}
+	if driver, err := entity.GetDriverByVehicle(plateNumber); err == nil && driver != nil {
+		manifest.DriverName = driver.Name
+		manifest.DriverPhone = driver.Phone
+	}
+
+	outletCache := make(map[string]*entity.Outlet)
+	stops := make([]*manifestStop, 0, len(orders))
+	for _, order := range orders {
+		manifest.TotalWeight += order.Weight
+		manifest.TotalVolume += order.Volume
+
+		position := order.End
+		stop := &manifestStop{order: order}
+		if order.EndOutletId != "" {
+			outlet, ok := outletCache[order.EndOutletId]
+			if !ok {
+				outlet, _ = entity.GetOutletById(order.EndOutletId)
+				outletCache[order.EndOutletId] = outlet
+			}
+			if outlet != nil {
+				stop.outletName = outlet.Name
+				position = outlet.LngLat
+			}
+		}
+		stop.pointIndex, stop.offset = locateStop(position, route, vehicle)
+		stops = append(stops, stop)
+	}
+	manifest.TotalWeight = roundToPrecision(manifest.TotalWeight, 10000)
+	manifest.TotalVolume = roundToPrecision(manifest.TotalVolume, 10000)
+
+	// 按沿线位置排序得到卸货顺序
+	sort.SliceStable(stops, func(i, j int) bool {
+		if stops[i].pointIndex != stops[j].pointIndex {
+			return stops[i].pointIndex < stops[j].pointIndex
+		}
+		return stops[i].offset < stops[j].offset
+	})
+	total := len(stops)
+	// 最后卸货的订单最先装车
+	for i := total - 1; i >= 0; i-- {
+		order := stops[i].order
+		manifest.Items = append(manifest.Items, vo.ManifestItem{
+			Sequence:      total - i,
+			StopIndex:     i + 1,
+			OrderID:       order.OrderID,
+			CustomerName:  order.CustomerName,
+			Phone:         order.Phone,
+			EndAddress:    order.EndAddress,
+			EndOutletId:   order.EndOutletId,
+			EndOutletName: stops[i].outletName,
+			Weight:        order.Weight,
+			Volume:        order.Volume,
+			Status:        order.Status,
+			PickedUp:      order.PickupTime != 0,
+		})
+	}
+	return manifest, nil
+}
+
+// locateStop 计算卸货点在线路上的位置；没有线路时以距车辆当前位置的距离排序
+func locateStop(position common.LngLat, route *entity.Route, vehicle *entity.Vehicle) (int, float64) {
+	if position.IsZero() {
+		return math.MaxInt32, math.MaxFloat64
+	}
+	if route == nil || len(route.Points) == 0 {
+		if vehicle.LngLat.IsZero() {
+			return math.MaxInt32, math.MaxFloat64
+		}
+		return 0, util.GetLngLatDistance(position, vehicle.LngLat)
+	}
+	nearestIndex := 0
+	minDistance := math.MaxFloat64
+	for i, point := range route.Points {
+		if len(point.Coordinates) < 2 {
+			continue
+		}
+		distance := util.GetLngLatDistance(position, point.LngLat())
+		if distance < minDistance {
+			minDistance = distance
+			nearestIndex = i
+		}
+	}
+	return nearestIndex, minDistance
+}
+
+// renderManifestPDF 生成装载清单 PDF
+func renderManifestPDF(manifest *vo.ManifestVO) []byte {
+	pdf := util.NewSimplePDF()
+	pdf.AddLine("车辆装载清单", 18)
+	pdf.AddSpace(6)
+	pdf.AddLine(fmt.Sprintf("车牌号：%s    车型：%s    线路：%s", manifest.PlateNumber, manifest.VehicleType, manifest.RouteName), 11)
+	pdf.AddLine(fmt.Sprintf("司机：%s    电话：%s", manifest.DriverName, manifest.DriverPhone), 11)
+	pdf.AddLine(fmt.Sprintf("核定载重：%.2f 吨    当前载重：%.2f 吨    剩余载重：%.2f 吨",
+		manifest.LoadCapacity, manifest.CurrentLoad, manifest.RemainingCapacity), 11)
+	pdf.AddLine(fmt.Sprintf("订单数：%d    总重量：%.2f 吨    总体积：%.2f 立方米",
+		manifest.OrderCount, manifest.TotalWeight, manifest.TotalVolume), 11)
+	pdf.AddLine(fmt.Sprintf("打印时间：%s", time.Now().Format("2006-01-02 15:04:05")), 11)
+	pdf.AddSpace(10)
+	pdf.AddLine("装车顺序（后卸先装）", 13)
+	for _, item := range manifest.Items {
+		pdf.AddLine(fmt.Sprintf("%d. 订单号 %s  卸货站序 %d  重量 %.2f 吨  体积 %.2f 立方米",
+			item.Sequence, item.OrderID, item.StopIndex, item.Weight, item.Volume), 10)
+		pdf.AddLine(fmt.Sprintf("    收货人：%s %s  目的网点：%s", item.CustomerName, item.Phone, item.EndOutletName), 10)
+		pdf.AddLine(fmt.Sprintf("    地址：%s", item.EndAddress), 10)
+	}
+	pdf.AddSpace(20)
+	pdf.AddLine("司机签字：________________    装车员签字：________________", 11)
+	return pdf.Bytes()
+}
@@ -1,17 +1,22 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/panjf2000/ants/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"go_logistics/common"
 	"go_logistics/config"
 	"go_logistics/model/entity"
 	"go_logistics/model/vo"
 	"go_logistics/util"
-	"math"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var taskPool, _ = ants.NewPool(16)
@@ -29,11 +34,50 @@ func CreateOrder(c *gin.Context) {
 	remark := c.PostForm("remark")
 	weightStr := c.PostForm("weight")
 	weight, err := strconv.ParseFloat(weightStr, 64)
-	if customerName == "" || phone == "" || startAddress == "" || endAddress == "" ||
-		startLng == "" || startLat == "" || endLng == "" || endLat == "" || weightStr == "" || err != nil {
+	if customerName == "" || phone == "" || startAddress == "" || endAddress == "" || weightStr == "" || err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	// 传入的坐标统一转换为存储坐标系
+	start, err := parseStorageLngLat(startLng, startLat, crs)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError("起点"+err.Error()))
+		return
+	}
+	end, err := parseStorageLngLat(endLng, endLat, crs)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError("终点"+err.Error()))
+		return
+	}
+	// 未传坐标时由服务端解析地址
+	if start.IsZero() {
+		start, err = resolveAddress(c.Request.Context(), startAddress)
+		if err != nil {
+			respondGeocodeError(c, fmt.Errorf("起点地址解析失败: %w", err))
+			return
+		}
+	}
+	if end.IsZero() {
+		end, err = resolveAddress(c.Request.Context(), endAddress)
+		if err != nil {
+			respondGeocodeError(c, fmt.Errorf("终点地址解析失败: %w", err))
+			return
+		}
+	}
+	// 体积为可选参数
+	var volume float64
+	if volumeStr := c.PostForm("volume"); volumeStr != "" {
+		volume, err = strconv.ParseFloat(volumeStr, 64)
+		if err != nil || volume < 0 {
+			common.ErrorResponse(c, common.ParamError)
+			return
+		}
+	}
 	orderID, err := util.GenerateOrderID()
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
@@ -45,12 +89,11 @@ func CreateOrder(c *gin.Context) {
 		Phone:        phone,
 		Status:       entity.Pending,
 		StartAddress: startAddress,
-		StartLng:     startLng,
-		StartLat:     startLat,
+		Start:        start,
 		EndAddress:   endAddress,
-		EndLng:       endLng,
-		EndLat:       endLat,
+		End:          end,
 		Weight:       weight,
+		Volume:       volume,
 		Remark:       remark,
 	}
 	err = entity.InsertOrder(order)
@@ -58,6 +101,12 @@ func CreateOrder(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	recordOrderEvent(&entity.OrderEvent{
+		OrderID:     orderID,
+		Type:        entity.OrderEventCreated,
+		Operator:    c.GetString("name"),
+		Description: startAddress + " → " + endAddress,
+	})
 
 	// 异步提交后台任务
 	_ = taskPool.Submit(func() {
@@ -69,8 +118,13 @@ func CreateOrder(c *gin.Context) {
 
 // GetOrderList 获取订单列表
 func GetOrderList(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
 	var dto entity.FindOrderListDTO
-	if err := c.ShouldBindJSON(&dto); err != nil {
+	if err := bindScopedQuery(c, &dto); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -79,6 +133,9 @@ func GetOrderList(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	for _, order := range orders {
+		order.ConvertCRS(crs)
+	}
 	common.SuccessResponseWithData(c, orders)
 }
 
@@ -106,6 +163,10 @@ func UpdateOrder(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	// 已取消的订单不再占用收件名额
+	if order.Status == entity.Cancelled {
+		releaseOrderIntake(orderId)
+	}
 	common.SuccessResponse(c)
 }
 
@@ -121,13 +182,21 @@ func DeleteOrder(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	releaseOrderIntake(orderId)
 	common.SuccessResponse(c)
 }
 
+// releaseOrderIntake 释放订单占用的收件名额，失败只记录日志
+func releaseOrderIntake(orderId string) {
+	if err := entity.ReleaseOutletIntake(orderId); err != nil {
+		config.Log.Warn("释放收件名额失败", zap.String("orderId", orderId), zap.Error(err))
+	}
+}
+
 // GetOrderTotalCount 获取订单总数
 func GetOrderTotalCount(c *gin.Context) {
 	var dto entity.FindOrderListDTO
-	if err := c.ShouldBindJSON(&dto); err != nil {
+	if err := bindScopedQuery(c, &dto); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -141,7 +210,8 @@ func GetOrderTotalCount(c *gin.Context) {
 
 func GetOrderVO(c *gin.Context) {
 	orderId := c.Query("orderId")
-	if orderId == "" {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if orderId == "" || err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -155,6 +225,7 @@ func GetOrderVO(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	orderVO.ConvertCRS(crs)
 	common.SuccessResponseWithData(c, orderVO)
 }
 
@@ -172,8 +243,36 @@ func completeDataOrder(orderId string) {
 		return
 	}
 
-	// 查找起点网点
-	startOutlet, err := findNearOutlet(order.StartLng, order.StartLat)
+	// 查找起点网点：就近网点当日收件已满时改由下一个覆盖起点的网点收件；中转中的订单沿用原起点网点
+	markDispatched := func() {}
+	var startOutlet *entity.Outlet
+	if order.CurrentOutletId != "" && order.StartOutletId != "" {
+		startOutlet, err = entity.GetOutletById(order.StartOutletId)
+	} else {
+		var skipped []string
+		now := time.Now()
+		startOutlet, skipped, err = findIntakeOutlet(order, now)
+		if errors.Is(err, errIntakeFull) {
+			requeueIntakeOrder(order, err, now)
+			return
+		}
+		// 调度成功前占用的收件名额在调度失败时释放，订单再次调度时重新占用
+		dispatched := false
+		defer func() {
+			if !dispatched {
+				releaseOrderIntake(orderId)
+			}
+		}()
+		markDispatched = func() { dispatched = true }
+		if err == nil && len(skipped) > 0 {
+			recordOrderEvent(&entity.OrderEvent{
+				OrderID:     orderId,
+				Type:        entity.OrderEventRerouted,
+				OutletID:    startOutlet.ID.Hex(),
+				Description: fmt.Sprintf("%s，改由%s收件", strings.Join(skipped, "；"), startOutlet.Name),
+			})
+		}
+	}
 	if err != nil {
 		msg := "查询起点网点失败！"
 		config.Log.Warn(msg, zap.String("orderId", orderId), zap.Error(err))
@@ -182,16 +281,15 @@ func completeDataOrder(orderId string) {
 	}
 
 	// 判断是否在范围内...
-	isInScope, err := util.IsPointInGeoPointSlice(order.StartLng, order.StartLat, startOutlet.Scope)
-	if err != nil || !isInScope {
+	if !util.IsLngLatInScope(order.Start, startOutlet.Scope) {
 		msg := "起点不在网点营业范围内！"
-		config.Log.Warn(msg, zap.String("orderId", orderId), zap.Error(err))
-		_ = updateOrderRemark(order, msg+" 错误原因: "+err.Error())
+		config.Log.Warn(msg, zap.String("orderId", orderId))
+		_ = updateOrderRemark(order, msg)
 		return
 	}
 
 	// 查找终点网点...
-	endOutlet, err := findNearOutlet(order.EndLng, order.EndLat)
+	endOutlet, err := findNearOutlet(order.End)
 	if err != nil {
 		msg := "查询终点网点失败！"
 		config.Log.Warn(msg, zap.String("orderId", orderId), zap.Error(err))
@@ -200,11 +298,10 @@ func completeDataOrder(orderId string) {
 	}
 
 	// 判断是否在范围内...
-	isInScope, err = util.IsPointInGeoPointSlice(order.EndLng, order.EndLat, endOutlet.Scope)
-	if err != nil || !isInScope {
+	if !util.IsLngLatInScope(order.End, endOutlet.Scope) {
 		msg := "终点不在网点营业范围内！"
-		config.Log.Warn(msg, zap.String("orderId", orderId), zap.Error(err))
-		_ = updateOrderRemark(order, msg+" 错误原因: "+err.Error())
+		config.Log.Warn(msg, zap.String("orderId", orderId))
+		_ = updateOrderRemark(order, msg)
 		return
 	}
 	if startOutlet.ID.Hex() == endOutlet.ID.Hex() {
@@ -221,33 +318,25 @@ func completeDataOrder(orderId string) {
 			_ = updateOrderRemark(order, msg+" 错误原因: "+err.Error())
 			return
 		}
+		markDispatched()
 		return
 	}
 
-	// 查询线路与车辆...
-	routes, err := entity.GetRouteByOutlets(startOutlet.ID.Hex(), endOutlet.ID.Hex())
-	if err != nil {
-		msg := "获取线路失败！"
-		config.Log.Warn(msg, zap.String("orderId", orderId), zap.Error(err))
-		_ = updateOrderRemark(order, msg+" 错误原因: "+err.Error())
-		return
-	}
-
-	var vehicles []*entity.Vehicle
-	for _, route := range routes {
-		tempVehicles, err := entity.GetVehicleByRouteId(route.RouteID)
+	// 经枢纽中转的订单从当前所在网点继续调度
+	origin := startOutlet
+	if order.CurrentOutletId != "" {
+		origin, err = entity.GetOutletById(order.CurrentOutletId)
 		if err != nil {
-			msg := "获取车辆失败！"
+			msg := "查询中转网点失败！"
 			config.Log.Warn(msg, zap.String("orderId", orderId), zap.Error(err))
 			_ = updateOrderRemark(order, msg+" 错误原因: "+err.Error())
 			return
 		}
-		if len(tempVehicles) > 0 {
-			vehicles = append(vehicles, tempVehicles...)
-		}
 	}
 
-	vehicle, err := FindMaxRemainingCapacityVehicle(vehicles)
+	// 查询线路与车辆：没有直达线路时经上级枢纽中转，按班次选择车辆，优先截止装货时间未过的最近一班
+	now := time.Now()
+	legOutlet, vehicle, departure, err := dispatchLeg(orderId, origin, endOutlet, order.Weight, now)
 	if err != nil {
 		msg := "获取最优车辆失败！"
 		config.Log.Warn(msg, zap.String("orderId", orderId), zap.Error(err))
@@ -290,8 +379,34 @@ func completeDataOrder(orderId string) {
 	// 更新订单状态
 	order.StartOutletId = startOutlet.ID.Hex()
 	order.EndOutletId = endOutlet.ID.Hex()
+	order.LegOutletId = legOutlet.ID.Hex()
 	order.Remark = ""
+	if order.IsTransferLeg() {
+		order.Remark = fmt.Sprintf("无直达线路，经%s中转", legOutlet.Name)
+	}
 	order.TransPortVehicle = vehicle.PlateNumber
+	order.RouteID = vehicle.RouteID
+	if departure != nil {
+		// 预计到达时间取自时刻表
+		order.ScheduledTime = primitive.NewDateTimeFromTime(departure.DepartureTime)
+		order.EstimatedArrival = primitive.NewDateTimeFromTime(departure.ArrivalTime)
+	} else if !origin.LngLat.IsZero() && !legOutlet.LngLat.IsZero() {
+		// 随时发车的线路按两网点之间的行驶时长推算
+		cost := routeCost(context.Background(), origin.LngLat, legOutlet.LngLat)
+		order.EstimatedArrival = primitive.NewDateTimeFromTime(now.Add(cost.Time()))
+	}
+	driver, _ := getOnShiftDriver(vehicle, now)
+	if driver != nil {
+		order.DriverID = driver.ID.Hex()
+	}
+
+	// 记录到车辆当前趟次，没有进行中的趟次时新建
+	trip, err := attachOrderToTrip(vehicle, driver, order)
+	if err != nil {
+		config.Log.Warn("记录车辆趟次失败！", zap.String("orderId", orderId), zap.Error(err))
+	} else {
+		order.TripID = trip.ID.Hex()
+	}
 	err = entity.CompleteDataOrder(order)
 	if err != nil {
 		msg := "更新订单状态失败！"
@@ -299,6 +414,135 @@ func completeDataOrder(orderId string) {
 		_ = updateOrderRemark(order, msg+" 错误原因: "+err.Error())
 		return
 	}
+	markDispatched()
+	recordOrderEvent(&entity.OrderEvent{
+		OrderID:     orderId,
+		Type:        entity.OrderEventDispatched,
+		OutletID:    origin.ID.Hex(),
+		PlateNumber: vehicle.PlateNumber,
+		Description: fmt.Sprintf("由%s发往%s", origin.Name, legOutlet.Name),
+	})
+}
+
+// dispatchCandidate 调度时的候选线路及其最近一班
+type dispatchCandidate struct {
+	route     *entity.Route
+	departure *entity.ScheduledDeparture // 没有时刻表的线路为 nil，表示随时发车
+}
+
+// calendarHorizonDays 调度时加载网点营业日历的天数
+const calendarHorizonDays = 14
+
+// selectDispatchVehicle 按班次先后选择车辆：有时刻表的线路按最近一班（截止装货时间未过、起点网点营业）的发车时间排序，
+// 没有时刻表的线路在起点网点营业时随时发车，排在最后；起点网点不营业时在下一次营业时发车，按该时间参与排序。
+// 依次在各线路有在班司机的车辆中选择剩余载重最大且能装下订单的车辆。都装不下时返回剩余载重最大的车辆，由调用方按超载处理。
+// 预计到达时终点网点不营业时，到达时间顺延到网点下一次营业。
+func selectDispatchVehicle(routes []*entity.Route, weight float64, now time.Time, origin *entity.Outlet,
+	startCalendar *entity.OutletCalendar, target *entity.Outlet) (*entity.Vehicle, *entity.ScheduledDeparture, error) {
+	candidates := make([]dispatchCandidate, 0, len(routes))
+	for _, route := range routes {
+		if len(route.Timetable) == 0 {
+			departure, ok := anytimeDeparture(route, now, origin, startCalendar, target)
+			if ok {
+				candidates = append(candidates, dispatchCandidate{route: route, departure: departure})
+			}
+			continue
+		}
+		var departure *entity.ScheduledDeparture
+		for _, upcoming := range route.UpcomingDepartures(now, 0) {
+			if startCalendar == nil || startCalendar.IsOpenAt(upcoming.DepartureTime) {
+				departure = upcoming
+				break
+			}
+		}
+		if departure == nil {
+			continue
+		}
+		departure.ArrivalTime = targetOpenTime(target, departure.ArrivalTime)
+		candidates = append(candidates, dispatchCandidate{route: route, departure: departure})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].departure, candidates[j].departure
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.DepartureTime.Before(b.DepartureTime)
+	})
+
+	var (
+		fallback          *entity.Vehicle
+		fallbackDeparture *entity.ScheduledDeparture
+	)
+	for _, candidate := range candidates {
+		vehicles, err := entity.GetVehicleByRouteId(candidate.route.RouteID)
+		if err != nil {
+			return nil, nil, err
+		}
+		// 只调度有在班司机的车辆
+		vehicles, err = filterVehiclesWithOnShiftDriver(vehicles)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(vehicles) == 0 {
+			continue
+		}
+		vehicle, err := FindMaxRemainingCapacityVehicle(vehicles)
+		if err != nil {
+			continue
+		}
+		if vehicle.CurrentLoad+weight <= vehicle.LoadCapacity {
+			return vehicle, candidate.departure, nil
+		}
+		if fallback == nil || vehicle.LoadCapacity-vehicle.CurrentLoad > fallback.LoadCapacity-fallback.CurrentLoad {
+			fallback, fallbackDeparture = vehicle, candidate.departure
+		}
+	}
+	if fallback == nil {
+		return nil, nil, fmt.Errorf("no vehicles available")
+	}
+	return fallback, fallbackDeparture, nil
+}
+
+// anytimeDeparture 没有时刻表的线路的发车安排：起点网点营业且预计到达时终点网点营业时返回 nil，表示随时发车；
+// 否则在起点网点下一次营业时发车，到达时间顺延到终点网点下一次营业。起点网点在日历范围内都不营业时返回 false
+func anytimeDeparture(route *entity.Route, now time.Time, origin *entity.Outlet, startCalendar *entity.OutletCalendar,
+	target *entity.Outlet) (*entity.ScheduledDeparture, bool) {
+	departAt := now
+	if startCalendar != nil {
+		openAt, ok := startCalendar.NextOpenTime(now, calendarHorizonDays)
+		if !ok {
+			return nil, false
+		}
+		departAt = openAt
+	}
+	arriveAt := departAt
+	if !origin.LngLat.IsZero() && !target.LngLat.IsZero() {
+		arriveAt = departAt.Add(routeCost(context.Background(), origin.LngLat, target.LngLat).Time())
+	}
+	openAt := targetOpenTime(target, arriveAt)
+	if departAt.Equal(now) && openAt.Equal(arriveAt) {
+		return nil, true
+	}
+	return &entity.ScheduledDeparture{
+		RouteID:       route.RouteID,
+		RouteName:     route.Name,
+		DepartureTime: departAt,
+		CutOffTime:    departAt,
+		ArrivalTime:   openAt,
+	}, true
+}
+
+// targetOpenTime 到达时终点网点不营业时顺延到网点下一次营业，营业日历从到达时间开始加载
+func targetOpenTime(target *entity.Outlet, arrival time.Time) time.Time {
+	calendar, err := entity.LoadOutletCalendar(target, arrival, calendarHorizonDays)
+	if err != nil {
+		config.Log.Warn("加载终点网点营业日历失败！", zap.String("outletId", target.ID.Hex()), zap.Error(err))
+		return arrival
+	}
+	if openAt, ok := calendar.NextOpenTime(arrival, calendarHorizonDays); ok {
+		return openAt
+	}
+	return arrival
 }
 
 // 四舍五入到指定精度
@@ -312,41 +556,140 @@ func updateOrderRemark(order *entity.Order, remark string) error {
 	return entity.UpdateOrder(order)
 }
 
+// nearOutletCandidates 没有网点覆盖时按直线距离预选的网点数，再按行驶距离选出最近的
+const nearOutletCandidates = 5
+
 // 查找最近的网点
-func findNearOutlet(lng string, lat string) (*entity.Outlet, error) {
-	outlets, err := entity.GetOutletList(entity.FindOutletListDTO{
-		Page: common.Page{
-			Skip:  1,
-			Limit: 1000,
-		},
-	})
+func findNearOutlet(position common.LngLat) (*entity.Outlet, error) {
+	if position.IsZero() {
+		return nil, fmt.Errorf("订单坐标为空")
+	}
+	if err := position.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 优先使用营业范围包含该点的网点，有多个时取最近的（已关闭的网点不参与调度）
+	outlets, err := findScopeOutlets(position)
+	if err != nil {
+		return nil, err
+	}
+	if len(outlets) > 0 {
+		return outlets[0], nil
+	}
+
+	// 没有网点覆盖该点时返回行驶距离最近的网点，由调用方判断是否在营业范围内
+	outlets, err = entity.FindNearestOutlets(position, 0, nearOutletCandidates)
 	if err != nil {
 		return nil, err
 	}
 	if len(outlets) == 0 {
-		return nil, nil
+		return nil, fmt.Errorf("no valid outlet found")
+	}
+	sortOutletsByRouteDistance(position, outlets)
+	return outlets[0], nil
+}
+
+// errIntakeFull 覆盖订单起点的网点当日收件均已满
+var errIntakeFull = errors.New("覆盖起点的网点今日收件均已满")
+
+// findIntakeOutlet 为订单起点选择收件网点：在营业范围包含起点的网点中由近到远原子地占用当日收件名额，
+// 同时返回因收件已满而跳过的网点说明。覆盖起点的网点都已满时返回 errIntakeFull；没有网点覆盖起点时按 findNearOutlet 处理。
+func findIntakeOutlet(order *entity.Order, now time.Time) (*entity.Outlet, []string, error) {
+	outlets, err := findScopeOutlets(order.Start)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(outlets) == 0 {
+		outlet, err := findNearOutlet(order.Start)
+		return outlet, nil, err
+	}
+	var skipped []string
+	for _, outlet := range outlets {
+		if outlet.DailyIntake > 0 {
+			reserved, err := entity.ReserveOutletIntake(outlet.ID.Hex(), now, order.OrderID, outlet.DailyIntake)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !reserved {
+				skipped = append(skipped, fmt.Sprintf("%s今日收件已满（%d 件）", outlet.Name, outlet.DailyIntake))
+				continue
+			}
+		}
+		return outlet, skipped, nil
+	}
+	return nil, skipped, fmt.Errorf("%w：%s", errIntakeFull, strings.Join(skipped, "；"))
+}
+
+// requeueIntakeOrder 覆盖起点的网点当日收件均已满时记录到订单时间线，并在订单上记录次日零点（北京时间）
+// 名额重置后的重新调度时间，由 StartOrderRetrySweeper 到期后重新调度，服务重启不会丢失
+func requeueIntakeOrder(order *entity.Order, reason error, now time.Time) {
+	config.Log.Warn("网点收件已满，次日重新调度", zap.String("orderId", order.OrderID), zap.Error(reason))
+	_ = updateOrderRemark(order, reason.Error()+"，次日自动重新调度")
+	recordOrderEvent(&entity.OrderEvent{
+		OrderID:     order.OrderID,
+		Type:        entity.OrderEventIntakeFull,
+		Description: reason.Error(),
+	})
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	if loc == nil {
+		loc = time.Local
+	}
+	local := now.In(loc)
+	nextDay := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+	if err := entity.ScheduleOrderRetry(order.OrderID, nextDay); err != nil {
+		config.Log.Warn("记录重新调度时间失败", zap.String("orderId", order.OrderID), zap.Error(err))
 	}
+}
 
-	var nearest *entity.Outlet
-	minDist := math.MaxFloat64
+// orderRetryInterval 检查到期重新调度订单的间隔
+const orderRetryInterval = time.Minute
 
-	for i := range outlets {
-		outlet := outlets[i]
-		distanceMeters, err := util.GetDistanceFromString(lng, lat, outlet.Lng, outlet.Lat)
+// StartOrderRetrySweeper 启动时及之后定时重新调度到期的订单，包括服务停止期间到期的订单
+func StartOrderRetrySweeper() {
+	sweep := func() {
+		orderIds, err := entity.ClaimDueOrderRetries(time.Now())
 		if err != nil {
-			return nil, err
+			config.Log.Warn("领取待重新调度的订单失败！", zap.Error(err))
 		}
-		if distanceMeters < minDist {
-			minDist = distanceMeters
-			nearest = outlet
+		for _, orderId := range orderIds {
+			_ = taskPool.Submit(func() {
+				completeDataOrder(orderId)
+			})
 		}
 	}
+	sweep()
+	go func() {
+		ticker := time.NewTicker(orderRetryInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sweep()
+		}
+	}()
+}
 
-	if nearest == nil {
-		return nil, fmt.Errorf("no valid outlet found")
+// findScopeOutlets 查询营业范围包含该点的营业中网点，按行驶距离由近到远排序
+func findScopeOutlets(position common.LngLat) ([]*entity.Outlet, error) {
+	outlets, err := entity.FindOutletsContainingPoint(position)
+	if err != nil {
+		return nil, err
 	}
+	sortOutletsByRouteDistance(position, outlets)
+	return outlets, nil
+}
 
-	return nearest, nil
+// sortOutletsByRouteDistance 按该点到网点的行驶距离由近到远排序
+func sortOutletsByRouteDistance(position common.LngLat, outlets []*entity.Outlet) {
+	if len(outlets) < 2 {
+		return
+	}
+	costs := outletRouteDistances(context.Background(), position, outlets)
+	distances := make(map[*entity.Outlet]float64, len(outlets))
+	for i, outlet := range outlets {
+		distances[outlet] = costs[i]
+	}
+	sort.SliceStable(outlets, func(i, j int) bool {
+		return distances[outlets[i]] < distances[outlets[j]]
+	})
 }
 
 // DispatchOrder 手动调度订单
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+)
+
+// GetOrderTimeline 获取订单时间线
+func GetOrderTimeline(c *gin.Context) {
+	orderId := c.Query("orderId")
+	if orderId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	events, err := entity.GetOrderEvents(orderId)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, events)
+}
+
+// recordOrderEvent 记录订单时间线，失败只记录日志，不影响主流程
+func recordOrderEvent(event *entity.OrderEvent) {
+	if err := entity.InsertOrderEvent(event); err != nil {
+		config.Log.Warn("记录订单时间线失败！", zap.String("orderId", event.OrderID), zap.Error(err))
+	}
+}
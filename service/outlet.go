@@ -2,9 +2,11 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"go_logistics/common"
 	"go_logistics/model/entity"
+	"go_logistics/util"
 	"strconv"
 )
 
@@ -28,18 +30,41 @@ func CreateOutlet(c *gin.Context) {
 		return
 	}
 
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	position, err := common.ParseLngLat(lng, lat)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
 	var scope []common.GeoPoint
 	if err := json.Unmarshal([]byte(scopeStr), &scope); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
-	if len(scope) <= 2 {
+	if err := util.ValidateScopePolygon(scope); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+
+	outletType, err := parseOutletType(c.PostForm("type"), entity.OutletTypeStation)
+	if err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
+	parentId := c.PostForm("parentId")
+	if err := entity.ValidateOutletHierarchy("", outletType, parentId); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
 
 	outlet := &entity.Outlet{
 		Name:          name,
+		Type:          outletType,
+		ParentID:      parentId,
 		Phone:         phone,
 		Province:      province,
 		City:          city,
@@ -47,10 +72,12 @@ func CreateOutlet(c *gin.Context) {
 		BusinessHours: businessHours,
 		Status:        entity.OutletStatus(statusInt),
 		Remark:        remark,
-		Lng:           lng,
-		Lat:           lat,
+		LngLat:        position,
 		Scope:         scope,
+		CRS:           crs,
 	}
+	// 入库前统一转换为存储坐标系
+	outlet.ConvertCRS(util.StorageCRS)
 	err = entity.InsertOutlet(outlet)
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
@@ -59,8 +86,25 @@ func CreateOutlet(c *gin.Context) {
 	common.SuccessResponse(c)
 }
 
+// parseOutletType 解析网点类型参数，为空时返回默认值
+func parseOutletType(value string, defaultType entity.OutletType) (entity.OutletType, error) {
+	if value == "" {
+		return defaultType, nil
+	}
+	typeInt, err := strconv.Atoi(value)
+	if err != nil || !entity.OutletType(typeInt).IsValid() {
+		return 0, fmt.Errorf("invalid outlet type: %s", value)
+	}
+	return entity.OutletType(typeInt), nil
+}
+
 // GetOutletList 获取网点列表
 func GetOutletList(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
 	var dto entity.FindOutletListDTO
 	if err := c.ShouldBindJSON(&dto); err != nil {
 		common.ErrorResponse(c, common.ParamError)
@@ -71,6 +115,7 @@ func GetOutletList(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	convertOutletsCRS(outlets, crs)
 	common.SuccessResponseWithData(c, outlets)
 }
 
@@ -94,13 +139,23 @@ func UpdateOutlet(c *gin.Context) {
 		return
 	}
 
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	position, err := common.ParseLngLat(lng, lat)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
 	var scope []common.GeoPoint
 	if err := json.Unmarshal([]byte(scopeStr), &scope); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
-	if len(scope) <= 2 {
-		common.ErrorResponse(c, common.ParamError)
+	if err := util.ValidateScopePolygon(scope); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
 		return
 	}
 
@@ -112,15 +167,44 @@ func UpdateOutlet(c *gin.Context) {
 		BusinessHours: businessHours,
 		Status:        entity.OutletStatus(statusInt),
 		Remark:        remark,
-		Lng:           lng,
-		Lat:           lat,
+		LngLat:        position,
 		Scope:         scope,
+		CRS:           crs,
+	}
+	// 入库前统一转换为存储坐标系
+	outlet.ConvertCRS(util.StorageCRS)
+	previous, err := entity.GetOutletById(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	// 未传类型与上级时保持原值
+	outlet.Type, err = parseOutletType(c.PostForm("type"), previous.EffectiveType())
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	outlet.ParentID = c.DefaultPostForm("parentId", previous.ParentID)
+	if err := entity.ValidateOutletHierarchy(outletId, outlet.Type, outlet.ParentID); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
 	}
 	err = entity.UpdateOutlet(outletId, outlet)
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	// 关闭网点时返回受影响的在途订单与车辆
+	if previous.Status != entity.OutletStatusClosed && outlet.Status == entity.OutletStatusClosed {
+		outlet.ID = previous.ID
+		impact, err := buildOutletImpact(outlet)
+		if err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		common.SuccessResponseWithData(c, impact)
+		return
+	}
 	common.SuccessResponse(c)
 }
 
@@ -166,7 +250,8 @@ func GetAllProvincesAndCities(c *gin.Context) {
 
 func GetOutletById(c *gin.Context) {
 	outletId := c.Query("outletId")
-	if outletId == "" {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if outletId == "" || err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -175,5 +260,6 @@ func GetOutletById(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	outlet.ConvertCRS(crs)
 	common.SuccessResponseWithData(c, outlet)
 }
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/project"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// overlapResolution 估算重叠面积时每个方向的采样数
+	overlapResolution = 100
+	// DefaultCoverageGridSize 覆盖分析默认的网格数（每个方向）
+	DefaultCoverageGridSize = 50
+	// MaxCoverageGridSize 覆盖分析允许的最大网格数（每个方向）
+	MaxCoverageGridSize = 200
+)
+
+var errBoundingBox = fmt.Errorf("bbox 格式应为 minLng,minLat,maxLng,maxLat")
+
+// OutletOverlap 两个网点营业范围的重叠情况
+type OutletOverlap struct {
+	OutletAID   string  `json:"outletAId"`
+	OutletAName string  `json:"outletAName"`
+	OutletBID   string  `json:"outletBId"`
+	OutletBName string  `json:"outletBName"`
+	OverlapArea float64 `json:"overlapArea"` // 重叠面积，单位平方米
+	RatioA      float64 `json:"ratioA"`      // 重叠面积占网点 A 营业范围的比例
+	RatioB      float64 `json:"ratioB"`      // 重叠面积占网点 B 营业范围的比例
+}
+
+// CoverageReport 城市网点覆盖报告
+type CoverageReport struct {
+	Province      string                     `json:"province"`
+	City          string                     `json:"city"`
+	OutletCount   int                        `json:"outletCount"`
+	BoundingBox   []float64                  `json:"boundingBox"` // [minLng, minLat, maxLng, maxLat]
+	CRS           util.CRS                   `json:"crs"`
+	GridSize      int                        `json:"gridSize"`
+	TotalArea     float64                    `json:"totalArea"`   // 分析区域面积，单位平方米
+	CoveredArea   float64                    `json:"coveredArea"` // 被覆盖的面积，单位平方米
+	CoverageRatio float64                    `json:"coverageRatio"`
+	Uncovered     *geojson.FeatureCollection `json:"uncovered"` // 未覆盖区域
+}
+
+// ServableOutlet 营业范围包含某点的网点
+type ServableOutlet struct {
+	OutletID string  `json:"outletId"`
+	Name     string  `json:"name"`
+	Phone    string  `json:"phone"`
+	Address  string  `json:"address"`
+	Distance float64 `json:"distance"` // 与网点坐标的距离，单位公里
+}
+
+// scopedOutlet 网点及其营业范围多边形
+type scopedOutlet struct {
+	outlet  *entity.Outlet
+	polygon orb.Polygon
+	area    float64
+}
+
+// loadScopedOutlets 按省市加载营业中的网点，并转换营业范围
+func loadScopedOutlets(province string, city string) ([]*scopedOutlet, error) {
+	outlets, err := entity.GetOutletList(entity.FindOutletListDTO{
+		Province: province,
+		City:     city,
+		Status:   entity.OutletStatusOpen,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*scopedOutlet, 0, len(outlets))
+	for _, outlet := range outlets {
+		if len(outlet.Scope) < 3 {
+			continue
+		}
+		polygon := util.ScopeToOrbPolygon(outlet.Scope)
+		result = append(result, &scopedOutlet{
+			outlet:  outlet,
+			polygon: polygon,
+			area:    geo.Area(polygon),
+		})
+	}
+	return result, nil
+}
+
+// GetOutletOverlaps 检测网点营业范围之间的重叠，可按省市过滤，指定 outletId 时只检查该网点
+func GetOutletOverlaps(c *gin.Context) {
+	outletId := c.Query("outletId")
+	outlets, err := loadScopedOutlets(c.Query("province"), c.Query("city"))
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	overlaps := make([]*OutletOverlap, 0)
+	for i := 0; i < len(outlets); i++ {
+		for j := i + 1; j < len(outlets); j++ {
+			a, b := outlets[i], outlets[j]
+			if outletId != "" && a.outlet.ID.Hex() != outletId && b.outlet.ID.Hex() != outletId {
+				continue
+			}
+			area := util.EstimateOverlapArea(a.polygon, b.polygon, overlapResolution)
+			if area <= 0 {
+				continue
+			}
+			overlap := &OutletOverlap{
+				OutletAID:   a.outlet.ID.Hex(),
+				OutletAName: a.outlet.Name,
+				OutletBID:   b.outlet.ID.Hex(),
+				OutletBName: b.outlet.Name,
+				OverlapArea: roundToPrecision(area, 1),
+			}
+			if a.area > 0 {
+				overlap.RatioA = roundToPrecision(area/a.area, 10000)
+			}
+			if b.area > 0 {
+				overlap.RatioB = roundToPrecision(area/b.area, 10000)
+			}
+			overlaps = append(overlaps, overlap)
+		}
+	}
+	sort.Slice(overlaps, func(i, j int) bool {
+		return overlaps[i].OverlapArea > overlaps[j].OverlapArea
+	})
+	common.SuccessResponseWithData(c, overlaps)
+}
+
+// GetCoverageReport 城市覆盖报告：以该城市网点营业范围的外包矩形（或 bbox 参数指定的区域）为分析区域，
+// 按网格统计覆盖率，并以 GeoJSON 返回未被任何网点覆盖的区域，bbox 与返回的坐标均为 crs 参数指定的坐标系
+func GetCoverageReport(c *gin.Context) {
+	province := c.Query("province")
+	city := c.Query("city")
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if city == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	gridSize, err := strconv.Atoi(c.DefaultQuery("gridSize", strconv.Itoa(DefaultCoverageGridSize)))
+	if err != nil || gridSize <= 0 || gridSize > MaxCoverageGridSize {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	outlets, err := loadScopedOutlets(province, city)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+
+	var area orb.Bound
+	if bbox := c.Query("bbox"); bbox != "" {
+		area, err = parseBoundingBox(bbox)
+		if err != nil {
+			common.ErrorResponse(c, common.ValidateError(err.Error()))
+			return
+		}
+		toStorage := util.CRSProjection(crs, util.StorageCRS)
+		area = orb.Bound{Min: toStorage(area.Min), Max: toStorage(area.Max)}
+	} else {
+		if len(outlets) == 0 {
+			common.ErrorResponse(c, common.ValidateError("该城市没有可分析的网点，请指定 bbox"))
+			return
+		}
+		area = outlets[0].polygon.Bound()
+		for _, outlet := range outlets[1:] {
+			area = area.Union(outlet.polygon.Bound())
+		}
+	}
+
+	polygons := make([]orb.Polygon, 0, len(outlets))
+	for _, outlet := range outlets {
+		polygons = append(polygons, outlet.polygon)
+	}
+	grid := util.AnalyzeCoverage(area, polygons, gridSize)
+
+	toClient := util.CRSProjection(util.StorageCRS, crs)
+	uncovered := geojson.NewFeatureCollection()
+	if len(grid.Uncovered) > 0 {
+		feature := geojson.NewFeature(project.Geometry(grid.Uncovered, toClient))
+		feature.Properties["area"] = roundToPrecision(grid.TotalArea-grid.CoveredArea, 1)
+		uncovered.Append(feature)
+	}
+	report := &CoverageReport{
+		Province:    province,
+		City:        city,
+		OutletCount: len(outlets),
+		BoundingBox: boundingBoxOf(orb.Bound{Min: toClient(area.Min), Max: toClient(area.Max)}),
+		CRS:         crs,
+		GridSize:    gridSize,
+		TotalArea:   roundToPrecision(grid.TotalArea, 1),
+		CoveredArea: roundToPrecision(grid.CoveredArea, 1),
+		Uncovered:   uncovered,
+	}
+	if grid.TotalArea > 0 {
+		report.CoverageRatio = roundToPrecision(grid.CoveredArea/grid.TotalArea, 10000)
+	}
+	common.SuccessResponseWithData(c, report)
+}
+
+// GetServableOutlets 查询营业范围包含该点的网点，按距离由近到远排序
+func GetServableOutlets(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	position, err := common.ParseLngLat(c.Query("lng"), c.Query("lat"))
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	position = util.ConvertLngLat(position, crs, util.StorageCRS)
+	outlets, err := entity.FindOutletsContainingPoint(position)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	distances := outletRouteDistances(c.Request.Context(), position, outlets)
+	result := make([]*ServableOutlet, 0, len(outlets))
+	for i, outlet := range outlets {
+		result = append(result, &ServableOutlet{
+			OutletID: outlet.ID.Hex(),
+			Name:     outlet.Name,
+			Phone:    outlet.Phone,
+			Address:  outlet.Province + outlet.City + outlet.DetailAddress,
+			Distance: roundToPrecision(distances[i], 100),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Distance < result[j].Distance
+	})
+	common.SuccessResponseWithData(c, result)
+}
+
+// boundingBoxOf 以 [minLng, minLat, maxLng, maxLat] 表示矩形区域
+func boundingBoxOf(bound orb.Bound) []float64 {
+	return []float64{bound.Min[0], bound.Min[1], bound.Max[0], bound.Max[1]}
+}
+
+// parseBoundingBox 解析 "minLng,minLat,maxLng,maxLat" 格式的矩形区域
+func parseBoundingBox(value string) (orb.Bound, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return orb.Bound{}, errBoundingBox
+	}
+	numbers := make([]float64, 4)
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return orb.Bound{}, errBoundingBox
+		}
+		numbers[i] = number
+	}
+	bound := orb.Bound{Min: orb.Point{numbers[0], numbers[1]}, Max: orb.Point{numbers[2], numbers[3]}}
+	if bound.Min[0] >= bound.Max[0] || bound.Min[1] >= bound.Max[1] ||
+		bound.Min[0] < -180 || bound.Max[0] > 180 || bound.Min[1] < -90 || bound.Max[1] > 90 {
+		return orb.Bound{}, errBoundingBox
+	}
+	return bound, nil
+}
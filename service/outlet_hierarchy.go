@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"time"
+)
+
+// GetChildOutlets 获取网点的直属下级网点
+func GetChildOutlets(c *gin.Context) {
+	outletId := c.Query("outletId")
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if outletId == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	outlets, err := entity.GetChildOutlets(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	convertOutletsCRS(outlets, crs)
+	common.SuccessResponseWithData(c, outlets)
+}
+
+// routingLegTargets 按默认路由策略列出本段运输可选的目的网点：
+// 优先直达终点，其次送往终点的上级枢纽，最后送往当前网点的上级枢纽
+func routingLegTargets(origin *entity.Outlet, end *entity.Outlet) []string {
+	targets := []string{end.ID.Hex()}
+	for _, parentId := range []string{end.ParentID, origin.ParentID} {
+		if parentId == "" || parentId == origin.ID.Hex() {
+			continue
+		}
+		duplicated := false
+		for _, target := range targets {
+			if target == parentId {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated {
+			targets = append(targets, parentId)
+		}
+	}
+	return targets
+}
+
+// dispatchLeg 按默认路由策略为订单选择本段运输：依次尝试各目的网点的线路，返回第一段能调度到车辆的目的网点
+func dispatchLeg(orderId string, origin *entity.Outlet, end *entity.Outlet, weight float64,
+	now time.Time) (*entity.Outlet, *entity.Vehicle, *entity.ScheduledDeparture, error) {
+	originCalendar, err := entity.LoadOutletCalendar(origin, now, calendarHorizonDays)
+	if err != nil {
+		config.Log.Warn("加载起点网点营业日历失败！", zap.String("orderId", orderId), zap.Error(err))
+	}
+	lastErr := fmt.Errorf("没有从 %s 出发的可用线路", origin.Name)
+	for _, targetId := range routingLegTargets(origin, end) {
+		target := end
+		if targetId != end.ID.Hex() {
+			target, err = entity.GetOutletById(targetId)
+			if err != nil || target.Status != entity.OutletStatusOpen {
+				continue
+			}
+		}
+		routes, err := entity.GetRouteByOutlets(origin.ID.Hex(), targetId)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if len(routes) == 0 {
+			continue
+		}
+		vehicle, departure, err := selectDispatchVehicle(routes, weight, now, origin, originCalendar, target)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return target, vehicle, departure, nil
+	}
+	return nil, nil, nil, lastErr
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+	"time"
+)
+
+// UpdateOutletSchedule 设置网点的每周营业时间，传空数组表示全天营业
+func UpdateOutletSchedule(c *gin.Context) {
+	outletId := c.PostForm("outletId")
+	scheduleStr := c.PostForm("schedule")
+	if outletId == "" || scheduleStr == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	var schedule []entity.BusinessDay
+	if err := json.Unmarshal([]byte(scheduleStr), &schedule); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	if err := entity.ValidateSchedule(schedule); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	if schedule == nil {
+		schedule = make([]entity.BusinessDay, 0)
+	}
+	if err := entity.UpdateOutletSchedule(outletId, schedule); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// CreateOutletHoliday 新建节假日或例外营业日
+func CreateOutletHoliday(c *gin.Context) {
+	var holiday entity.OutletHoliday
+	if err := c.ShouldBindJSON(&holiday); err != nil || holiday.Name == "" || holiday.Date == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	if !holiday.Closed && len(holiday.Intervals) == 0 {
+		common.ErrorResponse(c, common.ValidateError("非休息日需要设置营业时段"))
+		return
+	}
+	if err := entity.InsertOutletHoliday(&holiday); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// GetOutletHolidayList 获取节假日列表
+func GetOutletHolidayList(c *gin.Context) {
+	var dto entity.FindOutletHolidayListDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	holidays, err := entity.GetOutletHolidayList(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	if holidays == nil {
+		holidays = make([]*entity.OutletHoliday, 0)
+	}
+	common.SuccessResponseWithData(c, holidays)
+}
+
+// DeleteOutletHoliday 删除节假日
+func DeleteOutletHoliday(c *gin.Context) {
+	holidayId := c.Query("holidayId")
+	if holidayId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	if err := entity.DeleteOutletHoliday(holidayId); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// OutletOpenStatus 网点在某一时刻的营业状态
+type OutletOpenStatus struct {
+	OutletID     string     `json:"outletId"`
+	Time         time.Time  `json:"time"`
+	Open         bool       `json:"open"`
+	NextOpenTime *time.Time `json:"nextOpenTime"` // 不营业时的下一次营业时间，两周内都不营业时为空
+}
+
+// IsOutletOpen 查询网点在指定时间（RFC3339，默认当前时间）是否营业
+func IsOutletOpen(c *gin.Context) {
+	outletId := c.Query("outletId")
+	if outletId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	at := time.Now()
+	if value := c.Query("time"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			common.ErrorResponse(c, common.ParamError)
+			return
+		}
+		at = parsed
+	}
+	outlet, err := entity.GetOutletById(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	calendar, err := entity.LoadOutletCalendar(outlet, at, calendarHorizonDays)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	status := &OutletOpenStatus{
+		OutletID: outletId,
+		Time:     at,
+		Open:     outlet.Status == entity.OutletStatusOpen && calendar.IsOpenAt(at),
+	}
+	if !status.Open && outlet.Status == entity.OutletStatusOpen {
+		if next, ok := calendar.NextOpenTime(at, calendarHorizonDays); ok {
+			status.NextOpenTime = &next
+		}
+	}
+	common.SuccessResponseWithData(c, status)
+}
@@ -0,0 +1,393 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// inventoryCheckInterval 滞留告警的检查间隔
+const inventoryCheckInterval = 5 * time.Minute
+
+// InventoryParcel 网点在库包裹
+type InventoryParcel struct {
+	OrderID        string             `json:"orderId"`
+	Weight         float64            `json:"weight"`
+	Volume         float64            `json:"volume"`
+	InboundTime    primitive.DateTime `json:"inboundTime"`
+	DwellHours     float64            `json:"dwellHours"`
+	NextOutletID   string             `json:"nextOutletId"` // 下一站网点，为空表示由本网点派送
+	NextOutletName string             `json:"nextOutletName"`
+	Overdue        bool               `json:"overdue"` // 滞留时长超过告警阈值
+}
+
+// OutletInventoryVO 网点实时库存
+type OutletInventoryVO struct {
+	OutletID        string             `json:"outletId"`
+	OutletName      string             `json:"outletName"`
+	DwellAlertHours int                `json:"dwellAlertHours"`
+	Utilization     *OutletUtilization `json:"utilization"`
+	Parcels         []*InventoryParcel `json:"parcels"`
+}
+
+// OutletUtilization 网点库容利用率，未配置上限的维度利用率为 0
+type OutletUtilization struct {
+	OutletID          string  `json:"outletId"`
+	OutletName        string  `json:"outletName"`
+	ParcelCount       int     `json:"parcelCount"`
+	TotalWeight       float64 `json:"totalWeight"`
+	TotalVolume       float64 `json:"totalVolume"`
+	MaxParcels        int     `json:"maxParcels"`
+	MaxWeight         float64 `json:"maxWeight"`
+	ParcelUtilization float64 `json:"parcelUtilization"`
+	WeightUtilization float64 `json:"weightUtilization"`
+	OverCapacity      bool    `json:"overCapacity"`
+}
+
+// InboundScan 入库扫描：包裹到达网点后登记到网点库存
+func InboundScan(c *gin.Context) {
+	outletId := c.PostForm("outletId")
+	orderId := c.PostForm("orderId")
+	if outletId == "" || orderId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	outlet, err := entity.GetOutletById(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	orderMu := util.GetOrderLock(orderId)
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	order, err := entity.GetOrderById(orderId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	if order.Status == entity.Completed || order.Status == entity.Cancelled {
+		common.ErrorResponse(c, common.ValidateError("订单已"+order.Status.String()+"，不能入库"))
+		return
+	}
+	err = entity.InsertInventoryItem(&entity.InventoryItem{
+		OrderID:  orderId,
+		OutletID: outlet.ID.Hex(),
+		Weight:   order.Weight,
+		Volume:   order.Volume,
+	})
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	_ = entity.InsertOutletScan(&entity.OutletScan{
+		Type:     entity.ScanInbound,
+		OrderID:  orderId,
+		OutletID: outlet.ID.Hex(),
+		Operator: c.GetString("name"),
+	})
+	recordOrderEvent(&entity.OrderEvent{
+		OrderID:  orderId,
+		Type:     entity.OrderEventInbound,
+		OutletID: outlet.ID.Hex(),
+		Operator: c.GetString("name"),
+	})
+	common.SuccessResponse(c)
+}
+
+// OutboundScan 出库扫描：包裹装车或派送离开网点，可记录装载车辆
+func OutboundScan(c *gin.Context) {
+	outletId := c.PostForm("outletId")
+	orderId := c.PostForm("orderId")
+	if outletId == "" || orderId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	orderMu := util.GetOrderLock(orderId)
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	err := entity.DeleteInventoryItem(orderId, outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	_ = entity.InsertOutletScan(&entity.OutletScan{
+		Type:        entity.ScanOutbound,
+		OrderID:     orderId,
+		OutletID:    outletId,
+		PlateNumber: c.PostForm("plateNumber"),
+		Operator:    c.GetString("name"),
+	})
+	recordOrderEvent(&entity.OrderEvent{
+		OrderID:     orderId,
+		Type:        entity.OrderEventOutbound,
+		OutletID:    outletId,
+		PlateNumber: c.PostForm("plateNumber"),
+		Operator:    c.GetString("name"),
+	})
+	common.SuccessResponse(c)
+}
+
+// GetOrderScans 获取订单的网点扫描记录
+func GetOrderScans(c *gin.Context) {
+	orderId := c.Query("orderId")
+	if orderId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	scans, err := entity.GetOutletScansByOrder(orderId)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, scans)
+}
+
+// GetOutletInventory 网点实时库存：在库包裹、滞留时长、下一站与库容利用率
+func GetOutletInventory(c *gin.Context) {
+	outletId := c.Query("outletId")
+	if outletId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	outlet, err := entity.GetOutletById(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	items, err := entity.GetOutletInventory(outletId)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+
+	now := time.Now()
+	threshold := time.Duration(outlet.EffectiveDwellAlertHours()) * time.Hour
+	usage := &entity.InventoryUsage{OutletID: outletId}
+	outletNames := map[string]string{outletId: outlet.Name}
+	parcels := make([]*InventoryParcel, 0, len(items))
+	for _, item := range items {
+		dwell := item.DwellDuration(now)
+		parcel := &InventoryParcel{
+			OrderID:     item.OrderID,
+			Weight:      item.Weight,
+			Volume:      item.Volume,
+			InboundTime: item.InboundTime,
+			DwellHours:  roundToPrecision(dwell.Hours(), 100),
+			Overdue:     dwell >= threshold,
+		}
+		if order, err := entity.GetOrderById(item.OrderID); err == nil {
+			parcel.NextOutletID = nextOutletOf(order, outletId)
+			parcel.NextOutletName = outletNameOf(parcel.NextOutletID, outletNames)
+		}
+		parcels = append(parcels, parcel)
+		usage.ParcelCount++
+		usage.TotalWeight += item.Weight
+		usage.TotalVolume += item.Volume
+	}
+	common.SuccessResponseWithData(c, &OutletInventoryVO{
+		OutletID:        outletId,
+		OutletName:      outlet.Name,
+		DwellAlertHours: outlet.EffectiveDwellAlertHours(),
+		Utilization:     buildUtilization(outlet, usage),
+		Parcels:         parcels,
+	})
+}
+
+// GetInventoryUtilization 各网点库容利用率，可按省市过滤，按件数利用率降序排列
+func GetInventoryUtilization(c *gin.Context) {
+	outlets, err := entity.GetOutletList(entity.FindOutletListDTO{
+		Province: c.Query("province"),
+		City:     c.Query("city"),
+		Page: common.Page{
+			Skip:  1,
+			Limit: 10000,
+		},
+	})
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	// 受限用户只统计范围内的网点
+	scope := outletScopeOf(c)
+	outlets = slices.DeleteFunc(outlets, func(outlet *entity.Outlet) bool {
+		return !scope.HasOutlet(outlet.ID.Hex())
+	})
+	outletIds := make([]string, 0, len(outlets))
+	for _, outlet := range outlets {
+		outletIds = append(outletIds, outlet.ID.Hex())
+	}
+	usages, err := entity.GetInventoryUsage(outletIds)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	usageMap := make(map[string]*entity.InventoryUsage, len(usages))
+	for _, usage := range usages {
+		usageMap[usage.OutletID] = usage
+	}
+	result := make([]*OutletUtilization, 0, len(outlets))
+	for _, outlet := range outlets {
+		usage, ok := usageMap[outlet.ID.Hex()]
+		if !ok {
+			usage = &entity.InventoryUsage{OutletID: outlet.ID.Hex()}
+		}
+		result = append(result, buildUtilization(outlet, usage))
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ParcelUtilization > result[j].ParcelUtilization
+	})
+	common.SuccessResponseWithData(c, result)
+}
+
+// UpdateOutletCapacity 修改网点库容（最大件数、最大重量）、滞留告警阈值与每日收件上限，为 0 表示不限或使用默认值
+func UpdateOutletCapacity(c *gin.Context) {
+	outletId := c.PostForm("id")
+	maxParcels, err1 := strconv.Atoi(c.DefaultPostForm("maxParcels", "0"))
+	maxWeight, err2 := strconv.ParseFloat(c.DefaultPostForm("maxWeight", "0"), 64)
+	dwellAlertHours, err3 := strconv.Atoi(c.DefaultPostForm("dwellAlertHours", "0"))
+	dailyIntake, err4 := strconv.Atoi(c.DefaultPostForm("dailyIntake", "0"))
+	if outletId == "" || err1 != nil || err2 != nil || err3 != nil || err4 != nil ||
+		maxParcels < 0 || maxWeight < 0 || dwellAlertHours < 0 || dailyIntake < 0 {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	err := entity.UpdateOutletCapacity(outletId, maxParcels, maxWeight, dwellAlertHours, dailyIntake)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// GetInventoryAlertList 查询滞留告警
+func GetInventoryAlertList(c *gin.Context) {
+	var dto entity.FindInventoryAlertListDTO
+	if err := bindScopedQuery(c, &dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	alerts, err := entity.GetInventoryAlertList(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, alerts)
+}
+
+// AcknowledgeInventoryAlert 确认滞留告警
+func AcknowledgeInventoryAlert(c *gin.Context) {
+	alertId := c.PostForm("id")
+	if alertId == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	err := entity.AcknowledgeInventoryAlert(alertId, c.GetString("name"))
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// StartInventoryMonitor 启动滞留告警的定时检查
+func StartInventoryMonitor() {
+	go func() {
+		ticker := time.NewTicker(inventoryCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := checkDwellAlerts(time.Now()); err != nil {
+				config.Log.Warn("检查网点滞留包裹失败！", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// checkDwellAlerts 为滞留时长超过所在网点阈值且尚未告警的包裹生成告警
+func checkDwellAlerts(now time.Time) error {
+	items, err := entity.GetUnalertedInventory()
+	if err != nil {
+		return err
+	}
+	thresholds := make(map[string]int)
+	for _, item := range items {
+		threshold, ok := thresholds[item.OutletID]
+		if !ok {
+			threshold = entity.DefaultDwellAlertHours
+			if outlet, err := entity.GetOutletById(item.OutletID); err == nil {
+				threshold = outlet.EffectiveDwellAlertHours()
+			}
+			thresholds[item.OutletID] = threshold
+		}
+		if item.DwellDuration(now) < time.Duration(threshold)*time.Hour {
+			continue
+		}
+		alert, err := entity.RaiseInventoryAlert(item, threshold)
+		if err != nil {
+			return err
+		}
+		if alert != nil {
+			config.Log.Warn("包裹在网点滞留超时",
+				zap.String("outletId", item.OutletID),
+				zap.String("orderId", item.OrderID),
+				zap.Int("thresholdHours", threshold))
+		}
+	}
+	return nil
+}
+
+// nextOutletOf 包裹在网点的下一站：终点网点由本网点派送，其余按本段运输目的网点，未调度时取终点网点
+func nextOutletOf(order *entity.Order, outletId string) string {
+	if order.EndOutletId == outletId {
+		return ""
+	}
+	if order.LegOutletId != "" && order.LegOutletId != outletId {
+		return order.LegOutletId
+	}
+	return order.EndOutletId
+}
+
+// outletNameOf 查询网点名称并缓存
+func outletNameOf(outletId string, names map[string]string) string {
+	if outletId == "" {
+		return ""
+	}
+	if name, ok := names[outletId]; ok {
+		return name
+	}
+	name := ""
+	if outlet, err := entity.GetOutletById(outletId); err == nil {
+		name = outlet.Name
+	}
+	names[outletId] = name
+	return name
+}
+
+// buildUtilization 计算网点库容利用率
+func buildUtilization(outlet *entity.Outlet, usage *entity.InventoryUsage) *OutletUtilization {
+	utilization := &OutletUtilization{
+		OutletID:    outlet.ID.Hex(),
+		OutletName:  outlet.Name,
+		ParcelCount: usage.ParcelCount,
+		TotalWeight: roundToPrecision(usage.TotalWeight, 100),
+		TotalVolume: roundToPrecision(usage.TotalVolume, 100),
+		MaxParcels:  outlet.MaxParcels,
+		MaxWeight:   outlet.MaxWeight,
+	}
+	if outlet.MaxParcels > 0 {
+		utilization.ParcelUtilization = roundToPrecision(float64(usage.ParcelCount)/float64(outlet.MaxParcels), 10000)
+		utilization.OverCapacity = usage.ParcelCount > outlet.MaxParcels
+	}
+	if outlet.MaxWeight > 0 {
+		utilization.WeightUtilization = roundToPrecision(usage.TotalWeight/outlet.MaxWeight, 10000)
+		utilization.OverCapacity = utilization.OverCapacity || usage.TotalWeight > outlet.MaxWeight
+	}
+	return utilization
+}
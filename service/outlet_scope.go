@@ -0,0 +1,22 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_logistics/model/entity"
+)
+
+// outletScopeOf 当前用户的网点范围，由 Token 校验中间件写入，nil 表示不限
+func outletScopeOf(c *gin.Context) *entity.OutletScope {
+	value, _ := c.Get("outletScope")
+	scope, _ := value.(*entity.OutletScope)
+	return scope
+}
+
+// bindScopedQuery 绑定列表查询参数并附加当前用户的网点范围
+func bindScopedQuery(c *gin.Context, query entity.ScopedQuery) error {
+	if err := c.ShouldBindJSON(query); err != nil {
+		return err
+	}
+	query.SetOutletScope(outletScopeOf(c))
+	return nil
+}
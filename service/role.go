@@ -0,0 +1,55 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+	"go_logistics/model/vo"
+	"go_logistics/util"
+)
+
+// GetRoleList 获取全部角色与权限矩阵
+func GetRoleList(c *gin.Context) {
+	common.SuccessResponseWithData(c, vo.ToRoleVOList())
+}
+
+// AssignUserRole 修改用户角色，至少保留一名可用的管理员
+func AssignUserRole(c *gin.Context) {
+	name := c.PostForm("name")
+	roleName := c.PostForm("role")
+	if name == "" || roleName == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	role, err := util.ParseUserRole(roleName)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	user, err := entity.GetUserByName(name)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	if user.Role == util.RoleAdmin && role != util.RoleAdmin && user.Status == entity.Active {
+		count, err := entity.CountActiveUsersByRole(util.RoleAdmin)
+		if err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		if count <= 1 {
+			common.ErrorResponse(c, common.ValidateError("至少需要保留一名管理员"))
+			return
+		}
+	}
+	if err = entity.UpdateUserRole(name, role); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	// 访问 Token 中携带角色，修改后需重新登录
+	if err = revokeUserSessions(name, ""); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
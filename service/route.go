@@ -8,7 +8,10 @@ import (
 	"go_logistics/common"
 	"go_logistics/model/entity"
 	"go_logistics/model/vo"
+	"go_logistics/util"
+	"math"
 	"strconv"
+	"time"
 )
 
 // CreateRoute 创建线路
@@ -21,12 +24,15 @@ func CreateRoute(c *gin.Context) {
 	description := c.PostForm("description")
 	pointsStr := c.PostForm("points")
 	distance := c.PostForm("distance")
-	distanceFloat, err := strconv.ParseFloat(distance, 64)
 	startOutlet := c.PostForm("startOutlet")
 	endOutlet := c.PostForm("endOutlet")
 	if name == "" || routeType == "" || status == "" || pointsStr == "" ||
-		distance == "" || startOutlet == "" || endOutlet == "" || err != nil {
-		fmt.Println(name, routeType, status, description, pointsStr, distance, err)
+		startOutlet == "" || endOutlet == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -35,8 +41,12 @@ func CreateRoute(c *gin.Context) {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
-	if len(points) < 2 {
-		common.ErrorResponse(c, common.ParamError)
+	// 点位先转换为存储坐标系，再与网点坐标比对
+	points = util.ConvertGeoPoints(points, crs, util.StorageCRS)
+	// 校验线路几何并由服务端计算里程
+	points, distanceFloat, err := validateRouteGeometry(points, startOutlet, endOutlet, distance)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
 		return
 	}
 	// 使用雪花算法生成16位的线路ID
@@ -77,22 +87,30 @@ func UpdateRoute(c *gin.Context) {
 	description := c.PostForm("description")
 	pointsStr := c.PostForm("points")
 	distance := c.PostForm("distance")
-	distanceFloat, err := strconv.ParseFloat(distance, 64)
 	startOutlet := c.PostForm("startOutlet")
 	endOutlet := c.PostForm("endOutlet")
 	if routeId == "" || name == "" || routeType == "" || typeInt == 0 ||
-		statusInt == 0 || pointsStr == "" || distance == "" ||
+		statusInt == 0 || pointsStr == "" ||
 		startOutlet == "" || endOutlet == "" || err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
 	var points []common.GeoPoint
 	if err := json.Unmarshal([]byte(pointsStr), &points); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
-	if len(points) < 2 {
-		common.ErrorResponse(c, common.ParamError)
+	// 点位先转换为存储坐标系，再与网点坐标比对
+	points = util.ConvertGeoPoints(points, crs, util.StorageCRS)
+	// 校验线路几何并由服务端计算里程
+	points, distanceFloat, err := validateRouteGeometry(points, startOutlet, endOutlet, distance)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
 		return
 	}
 	route := &entity.Route{
@@ -106,18 +124,38 @@ func UpdateRoute(c *gin.Context) {
 		StartOutlet: startOutlet,
 		EndOutlet:   endOutlet,
 	}
+	previous, err := entity.GetRouteById(routeId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
 	err = entity.UpdateRoute(route)
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	// 停用线路时返回受影响的在途订单与车辆
+	if previous.Status != entity.RouteStatusInactive && route.Status == entity.RouteStatusInactive {
+		impact, err := buildRouteImpact(route)
+		if err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		common.SuccessResponseWithData(c, impact)
+		return
+	}
 	common.SuccessResponse(c)
 }
 
 // GetRouteList 获取线路列表
 func GetRouteList(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
 	var dto entity.FindRouteListDTO
-	if err := c.ShouldBindJSON(&dto); err != nil {
+	if err := bindScopedQuery(c, &dto); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -131,6 +169,7 @@ func GetRouteList(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	vo.ConvertRouteVOListCRS(routeVOs, crs)
 	common.SuccessResponseWithData(c, routeVOs)
 }
 
@@ -152,7 +191,7 @@ func DeleteRoute(c *gin.Context) {
 // GetRouteTotalCount 获取线路总数
 func GetRouteTotalCount(c *gin.Context) {
 	var dto entity.FindRouteListDTO
-	if err := c.ShouldBindJSON(&dto); err != nil {
+	if err := bindScopedQuery(c, &dto); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -163,3 +202,184 @@ func GetRouteTotalCount(c *gin.Context) {
 	}
 	common.SuccessResponseWithData(c, totalCount)
 }
+
+// UpdateRouteTimetable 设置线路班次时刻表，传空数组表示取消时刻表（随时发车）
+func UpdateRouteTimetable(c *gin.Context) {
+	routeId := c.PostForm("routeId")
+	timetableStr := c.PostForm("timetable")
+	if routeId == "" || timetableStr == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	var timetable []entity.RouteDeparture
+	if err := json.Unmarshal([]byte(timetableStr), &timetable); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	if err := entity.ValidateTimetable(timetable); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	if timetable == nil {
+		timetable = make([]entity.RouteDeparture, 0)
+	}
+	if err := entity.UpdateRouteTimetable(routeId, timetable); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// GetRouteDepartures 查询线路接下来的班次
+func GetRouteDepartures(c *gin.Context) {
+	routeId := c.Query("routeId")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if routeId == "" || err != nil || limit <= 0 {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	route, err := entity.GetRouteById(routeId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	departures := route.UpcomingDepartures(time.Now(), limit)
+	if departures == nil {
+		departures = make([]*entity.ScheduledDeparture, 0)
+	}
+	common.SuccessResponseWithData(c, departures)
+}
+
+const (
+	// RouteDistanceTolerance 客户端里程与计算里程允许的相对误差
+	RouteDistanceTolerance = 0.1
+	// RouteDistanceMinDiff 客户端里程与计算里程允许的绝对误差，单位公里
+	RouteDistanceMinDiff = 1.0
+	// RouteMaxSegmentLength 相邻两个线路点之间的最大距离，单位公里
+	RouteMaxSegmentLength = 500.0
+)
+
+// validateRouteGeometry 校验线路点位与起止网点，返回去重后的点位与服务端计算的里程。
+// clientDistance 为空时直接使用计算值，不为空时与计算值相差过大则报错。
+func validateRouteGeometry(points []common.GeoPoint, startOutletId string, endOutletId string,
+	clientDistance string) ([]common.GeoPoint, float64, error) {
+	if err := util.ValidateGeoPoints(points); err != nil {
+		return nil, 0, err
+	}
+	// 去除连续重复的点，并统一类型
+	cleaned := make([]common.GeoPoint, 0, len(points))
+	for _, p := range points {
+		if n := len(cleaned); n > 0 &&
+			cleaned[n-1].Coordinates[0] == p.Coordinates[0] && cleaned[n-1].Coordinates[1] == p.Coordinates[1] {
+			continue
+		}
+		p.Type = "Point"
+		cleaned = append(cleaned, p)
+	}
+	if len(cleaned) < 2 {
+		return nil, 0, fmt.Errorf("线路至少需要两个不同的点")
+	}
+	for i := 1; i < len(cleaned); i++ {
+		prev, cur := cleaned[i-1], cleaned[i]
+		segment := util.GetDistance(prev.Coordinates[1], prev.Coordinates[0], cur.Coordinates[1], cur.Coordinates[0])
+		if segment > RouteMaxSegmentLength {
+			return nil, 0, fmt.Errorf("第%d个点与上一个点相距%.1f公里，超过%.0f公里", i+1, segment, RouteMaxSegmentLength)
+		}
+	}
+
+	if startOutletId == endOutletId {
+		return nil, 0, fmt.Errorf("起点网点与终点网点不能相同")
+	}
+	startOutlet, err := entity.GetOutletById(startOutletId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("起点网点不存在")
+	}
+	endOutlet, err := entity.GetOutletById(endOutletId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("终点网点不存在")
+	}
+	if err = checkRouteEndpoint(cleaned[0], startOutlet); err != nil {
+		return nil, 0, fmt.Errorf("线路起点%s", err.Error())
+	}
+	if err = checkRouteEndpoint(cleaned[len(cleaned)-1], endOutlet); err != nil {
+		return nil, 0, fmt.Errorf("线路终点%s", err.Error())
+	}
+
+	distance := roundToPrecision(util.GetPolylineDistance(cleaned), 100)
+	if clientDistance != "" {
+		clientValue, err := strconv.ParseFloat(clientDistance, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("线路里程格式错误")
+		}
+		diff := math.Abs(clientValue - distance)
+		if diff > RouteDistanceMinDiff && diff > distance*RouteDistanceTolerance {
+			return nil, 0, fmt.Errorf("线路里程%.2f公里与按点位计算的%.2f公里相差过大", clientValue, distance)
+		}
+	}
+	return cleaned, distance, nil
+}
+
+// checkRouteEndpoint 校验线路端点是否在网点营业范围内。网点必须配置营业范围（新建与修改网点时已校验），
+// 没有营业范围的历史网点需先补全范围
+func checkRouteEndpoint(point common.GeoPoint, outlet *entity.Outlet) error {
+	if len(outlet.Scope) < 3 {
+		return fmt.Errorf("所属网点【%s】未配置营业范围", outlet.Name)
+	}
+	if !util.IsLngLatInScope(point.LngLat(), outlet.Scope) {
+		return fmt.Errorf("不在网点【%s】的营业范围内", outlet.Name)
+	}
+	return nil
+}
+
+// RouteBackfillIssue 回填任务中校验未通过的线路
+type RouteBackfillIssue struct {
+	RouteID string `json:"routeId"`
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+}
+
+// RouteBackfillResult 线路里程回填结果
+type RouteBackfillResult struct {
+	Total   int                  `json:"total"`
+	Updated int                  `json:"updated"`
+	Issues  []RouteBackfillIssue `json:"issues"`
+}
+
+// BackfillRouteDistance 为已有线路重新计算里程并校验几何，返回需要人工处理的线路
+func BackfillRouteDistance(c *gin.Context) {
+	result, err := backfillRouteDistance()
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, result)
+}
+
+func backfillRouteDistance() (*RouteBackfillResult, error) {
+	result := &RouteBackfillResult{Issues: make([]RouteBackfillIssue, 0)}
+	err := entity.EachRoute(func(route *entity.Route) error {
+		result.Total++
+		// 几何校验失败的线路仅记录，不修改数据
+		_, distance, err := validateRouteGeometry(route.Points, route.StartOutlet, route.EndOutlet, "")
+		if err != nil {
+			result.Issues = append(result.Issues, RouteBackfillIssue{
+				RouteID: route.RouteID,
+				Name:    route.Name,
+				Reason:  err.Error(),
+			})
+			return nil
+		}
+		if distance == route.Distance {
+			return nil
+		}
+		if err = entity.UpdateRouteDistance(route.RouteID, distance); err != nil {
+			return err
+		}
+		result.Updated++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
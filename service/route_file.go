@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+const (
+	MaxRouteFileSize = 8 << 20
+	// OutletSuggestMaxDistance 端点不在任何网点范围内时，推荐网点的最大距离，单位公里
+	OutletSuggestMaxDistance = 50.0
+)
+
+// RouteImportResult 线路文件解析结果，前端确认后再调用创建线路接口
+type RouteImportResult struct {
+	Name               string            `json:"name"`
+	Format             util.GeoFormat    `json:"format"`
+	Points             []common.GeoPoint `json:"points"`
+	CRS                util.CRS          `json:"crs"`
+	OriginalPointCount int               `json:"originalPointCount"`
+	PointCount         int               `json:"pointCount"`
+	Distance           float64           `json:"distance"`
+	StartOutlet        *OutletSuggestion `json:"startOutlet"` // 建议的起点网点，找不到时为空
+	EndOutlet          *OutletSuggestion `json:"endOutlet"`   // 建议的终点网点，找不到时为空
+}
+
+// OutletSuggestion 根据线路端点推荐的网点
+type OutletSuggestion struct {
+	OutletID string  `json:"outletId"`
+	Name     string  `json:"name"`
+	InScope  bool    `json:"inScope"`  // 端点是否在网点营业范围内
+	Distance float64 `json:"distance"` // 端点与网点坐标的距离，单位公里
+}
+
+// ImportRoute 解析 GPX/KML/GeoJSON 文件为线路点位，可选按容差（米）或点数上限简化，并推荐起止网点。
+// 文件坐标默认为 WGS-84，可通过 fileCrs 指定；返回的点位按 crs 参数转换，便于直接提交创建线路
+func ImportRoute(c *gin.Context) {
+	fileCRS, err := util.ParseCRS(c.PostForm("fileCrs"), util.StorageCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	defer file.Close()
+	if header.Size > MaxRouteFileSize {
+		common.ErrorResponse(c, common.ServerError("仅支持8MB以下的线路文件！"))
+		return
+	}
+	formatName := c.PostForm("format")
+	if formatName == "" {
+		formatName = filepath.Ext(header.Filename)
+	}
+	format, err := util.ParseGeoFormat(formatName)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	var tolerance float64
+	if value := c.PostForm("tolerance"); value != "" {
+		tolerance, err = strconv.ParseFloat(value, 64)
+		if err != nil || tolerance < 0 {
+			common.ErrorResponse(c, common.ParamError)
+			return
+		}
+	}
+	var maxPoints int
+	if value := c.PostForm("maxPoints"); value != "" {
+		maxPoints, err = strconv.Atoi(value)
+		if err != nil || maxPoints < 0 || maxPoints == 1 {
+			common.ErrorResponse(c, common.ParamError)
+			return
+		}
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError("文件读取错误！"))
+		return
+	}
+
+	line, err := util.ParseLineString(format, data)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	points := util.LineStringToGeoPoints(util.SimplifyLineString(line, tolerance, maxPoints))
+	if err = util.ValidateGeoPoints(points); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	points = util.ConvertGeoPoints(points, fileCRS, util.StorageCRS)
+
+	name := header.Filename[:len(header.Filename)-len(filepath.Ext(header.Filename))]
+	result := &RouteImportResult{
+		Name:               name,
+		Format:             format,
+		Points:             util.ConvertGeoPoints(points, util.StorageCRS, crs),
+		CRS:                crs,
+		OriginalPointCount: len(line),
+		PointCount:         len(points),
+		Distance:           roundToPrecision(util.GetPolylineDistance(points), 100),
+	}
+	result.StartOutlet, err = suggestOutlet(c.Request.Context(), points[0], "")
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	excludeId := ""
+	if result.StartOutlet != nil {
+		excludeId = result.StartOutlet.OutletID
+	}
+	result.EndOutlet, err = suggestOutlet(c.Request.Context(), points[len(points)-1], excludeId)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, result)
+}
+
+// ExportRoute 将线路导出为 GPX/KML/GeoJSON 文件，坐标默认为 WGS-84，可通过 crs 参数指定
+func ExportRoute(c *gin.Context) {
+	routeId := c.Query("routeId")
+	crs, err := requestCRS(c, util.StorageCRS)
+	if routeId == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	formatName := c.DefaultQuery("format", string(util.GeoFormatGeoJSON))
+	format, err := util.ParseGeoFormat(formatName)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	route, err := entity.GetRouteById(routeId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	route.ConvertCRS(crs)
+	data, err := util.EncodeLineString(format, route.Name, route.Description, util.GeoPointsToLineString(route.Points))
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	fileName := fmt.Sprintf("route_%s.%s", route.RouteID, format)
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(fileName)))
+	c.Header("Content-Length", strconv.Itoa(len(data)))
+	c.Data(200, format.ContentType(), data)
+}
+
+// suggestOutlet 为线路端点推荐网点：优先选择营业范围包含端点的网点，其次选择一定距离内行驶距离最近的网点
+func suggestOutlet(ctx context.Context, point common.GeoPoint, excludeId string) (*OutletSuggestion, error) {
+	position := point.LngLat()
+	outlets, err := entity.FindOutletsContainingPoint(position)
+	if err != nil {
+		return nil, err
+	}
+	inScope := true
+	if len(outlets) == 0 {
+		// 多取一个，避免唯一的结果被排除
+		outlets, err = entity.FindNearestOutlets(position, OutletSuggestMaxDistance*1000, 2)
+		if err != nil {
+			return nil, err
+		}
+		inScope = false
+	}
+	outlets = slices.DeleteFunc(outlets, func(outlet *entity.Outlet) bool {
+		return outlet.ID.Hex() == excludeId
+	})
+	distances := outletRouteDistances(ctx, position, outlets)
+	var best *OutletSuggestion
+	for i, outlet := range outlets {
+		candidate := &OutletSuggestion{
+			OutletID: outlet.ID.Hex(),
+			Name:     outlet.Name,
+			InScope:  inScope,
+			Distance: roundToPrecision(distances[i], 100),
+		}
+		if best == nil || candidate.Distance < best.Distance {
+			best = candidate
+		}
+	}
+	return best, nil
+}
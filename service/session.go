@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"go_logistics/common"
+	"go_logistics/config"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"time"
+)
+
+const (
+	// AccessTokenHeader 访问 Token 的请求头与响应头
+	AccessTokenHeader = "logistics_token"
+	// RefreshTokenHeader 登录与刷新时返回刷新 Token 的响应头
+	RefreshTokenHeader = "logistics_refresh_token"
+	// revocationSyncInterval 从数据库同步吊销列表的间隔，多实例部署时其他实例的吊销最迟在该间隔后生效
+	revocationSyncInterval = 30 * time.Second
+)
+
+// createSession 登录成功后新建会话，返回刷新 Token 与会话ID
+func createSession(c *gin.Context, subject string, role util.Role) (string, string, error) {
+	refreshToken, hash, err := util.MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+	session := &entity.Session{
+		SessionID: primitive.NewObjectID().Hex(),
+		Subject:   subject,
+		Role:      role,
+		TokenHash: hash,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if err = entity.InsertSession(session); err != nil {
+		return "", "", err
+	}
+	return refreshToken, session.SessionID, nil
+}
+
+// rotateSession 校验并轮换刷新 Token，expectDriver 表示期望的是司机会话
+func rotateSession(c *gin.Context, expectDriver bool) (*entity.Session, string, bool) {
+	refreshToken := c.PostForm("refreshToken")
+	if refreshToken == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return nil, "", false
+	}
+	newToken, newHash, err := util.MakeRefreshToken()
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return nil, "", false
+	}
+	session, err := entity.RotateSession(util.HashRefreshToken(refreshToken), newHash)
+	if errors.Is(err, entity.ErrRefreshTokenReused) {
+		config.Log.Warn("刷新 Token 被重复使用，已吊销会话", zap.String("ip", c.ClientIP()))
+	}
+	if err != nil {
+		common.ErrorResponse(c, common.NotLogin)
+		return nil, "", false
+	}
+	if (session.Role == util.RoleDriver) != expectDriver {
+		common.ErrorResponse(c, common.PermissionDenied)
+		return nil, "", false
+	}
+	return session, newToken, true
+}
+
+// revokeSession 吊销会话并立即在本实例生效
+func revokeSession(sessionId string) error {
+	revokeTime, err := entity.RevokeSession(sessionId)
+	if err != nil {
+		return err
+	}
+	util.RevokeSession(sessionId, revokeTime)
+	return nil
+}
+
+// revokeUserSessions 吊销后台用户的全部会话，exceptSessionId 为需要保留的当前会话
+func revokeUserSessions(name string, exceptSessionId string) error {
+	return revokeSubjectSessions(name, false, exceptSessionId)
+}
+
+// revokeDriverSessions 吊销司机的全部会话
+func revokeDriverSessions(driverId string) error {
+	return revokeSubjectSessions(driverId, true, "")
+}
+
+// revokeSubjectSessions 吊销账号的全部会话并立即在本实例生效
+func revokeSubjectSessions(subject string, driver bool, exceptSessionId string) error {
+	sessionIds, err := entity.RevokeSubjectSessions(subject, driver, exceptSessionId)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, sessionId := range sessionIds {
+		util.RevokeSession(sessionId, now)
+	}
+	return nil
+}
+
+// respondTokens 通过响应头返回访问 Token 与刷新 Token
+func respondTokens(c *gin.Context, accessToken string, refreshToken string) {
+	c.Header(AccessTokenHeader, accessToken)
+	c.Header(RefreshTokenHeader, refreshToken)
+}
+
+// RefreshToken 使用刷新 Token 换取新的访问 Token 与刷新 Token，并按最新的用户信息签发
+func RefreshToken(c *gin.Context) {
+	session, refreshToken, ok := rotateSession(c, false)
+	if !ok {
+		return
+	}
+	user, err := entity.GetUserByName(session.Subject)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	if user.Status != entity.Active {
+		if err = revokeSession(session.SessionID); err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		if user.Status == entity.Banned {
+			common.ErrorResponse(c, common.UserBanned)
+		} else {
+			common.ErrorResponse(c, common.UserDeleted)
+		}
+		return
+	}
+	token, err := util.GenerateToken(user.Name, user.Role, user.OutletIDs, session.SessionID)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	respondTokens(c, token, refreshToken)
+	common.SuccessResponse(c)
+}
+
+// Logout 退出登录，吊销当前会话
+func Logout(c *gin.Context) {
+	if err := revokeSession(c.GetString("sessionId")); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// LogoutAll 退出全部设备，吊销当前用户的全部会话
+func LogoutAll(c *gin.Context) {
+	name := c.GetString("name")
+	if name == "" {
+		common.ErrorResponse(c, common.NotLogin)
+		return
+	}
+	if err := revokeUserSessions(name, ""); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// RefreshDriverToken 司机端使用刷新 Token 换取新的访问 Token 与刷新 Token
+func RefreshDriverToken(c *gin.Context) {
+	session, refreshToken, ok := rotateSession(c, true)
+	if !ok {
+		return
+	}
+	driver, err := entity.GetDriverById(session.Subject)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	if driver.Status == entity.DriverStatusResigned {
+		if err = revokeSession(session.SessionID); err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		common.ErrorResponse(c, common.UserBanned)
+		return
+	}
+	token, err := util.GenerateDriverToken(session.Subject, session.SessionID)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	respondTokens(c, token, refreshToken)
+	common.SuccessResponse(c)
+}
+
+// StartSessionRevocationSync 定时从数据库同步最近吊销的会话，使其他实例上的退出登录也能生效
+func StartSessionRevocationSync() {
+	sync := func() {
+		now := time.Now()
+		sessions, err := entity.GetRevokedSessionsSince(now.Add(-util.AccessTokenTTL))
+		if err != nil {
+			config.Log.Warn("同步会话吊销列表失败！", zap.Error(err))
+			return
+		}
+		util.MergeRevokedSessions(sessions, now)
+	}
+	sync()
+	go func() {
+		ticker := time.NewTicker(revocationSyncInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sync()
+		}
+	}()
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go_logistics/common"
+	"go_logistics/model/entity"
+	"go_logistics/util"
+	"time"
+)
+
+// GetTripList 获取趟次列表
+func GetTripList(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	var dto entity.FindTripListDTO
+	if err := bindScopedQuery(c, &dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	trips, err := entity.GetTripList(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	for _, trip := range trips {
+		trip.ConvertCRS(crs)
+	}
+	common.SuccessResponseWithData(c, trips)
+}
+
+// GetTripTotalCount 获取趟次总数
+func GetTripTotalCount(c *gin.Context) {
+	var dto entity.FindTripListDTO
+	if err := bindScopedQuery(c, &dto); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	totalCount, err := entity.GetTripTotalCount(dto)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponseWithData(c, totalCount)
+}
+
+// GetTripDetail 获取趟次详情
+func GetTripDetail(c *gin.Context) {
+	tripId := c.Query("tripId")
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if tripId == "" || err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	trip, err := entity.GetTripById(tripId)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	trip.ConvertCRS(crs)
+	common.SuccessResponseWithData(c, trip)
+}
+
+// attachOrderToTrip 将订单记录到车辆进行中的趟次，车辆首次分配订单时新建趟次。
+// 调用方需持有车辆锁，vehicle.CurrentLoad 为加入订单后的载重。
+func attachOrderToTrip(vehicle *entity.Vehicle, driver *entity.Driver, order *entity.Order) (*entity.Trip, error) {
+	trip, err := entity.GetOpenTripByVehicle(vehicle.PlateNumber)
+	if err != nil {
+		return nil, err
+	}
+	if trip == nil {
+		trip = &entity.Trip{
+			PlateNumber:  vehicle.PlateNumber,
+			VehicleType:  vehicle.Type,
+			RouteID:      vehicle.RouteID,
+			RouteName:    vehicle.RouteName,
+			LoadCapacity: vehicle.LoadCapacity,
+			Start:        vehicle.LngLat,
+		}
+		if driver != nil {
+			trip.DriverID = driver.ID.Hex()
+			trip.DriverName = driver.Name
+		}
+		// 根据上一趟计算空驶里程与空闲时长
+		lastTrip, err := entity.GetLastCompletedTripByVehicle(vehicle.PlateNumber)
+		if err != nil {
+			return nil, err
+		}
+		if lastTrip != nil {
+			if !lastTrip.End.IsZero() && !vehicle.LngLat.IsZero() {
+				// 空驶里程计入趟次成本，按行驶距离计算
+				cost := routeCost(context.Background(), lastTrip.End, vehicle.LngLat)
+				trip.EmptyDistance = roundToPrecision(cost.Distance, 10000)
+			}
+			idle := time.Since(lastTrip.ArrivalTime.Time()).Hours()
+			if idle > 0 {
+				trip.IdleHours = roundToPrecision(idle, 100)
+			}
+		}
+		if err = entity.InsertTrip(trip); err != nil {
+			return nil, err
+		}
+	}
+	if err = entity.AddOrderToTrip(trip, order.OrderID, order.Weight, vehicle.CurrentLoad); err != nil {
+		return nil, err
+	}
+	return trip, nil
+}
+
+// closeLineHaulTrip 结束车辆进行中的线路趟次，派送计划的趟次由 CompleteDelivery 结束
+func closeLineHaulTrip(vehicle *entity.Vehicle) error {
+	trip, err := entity.GetOpenTripByVehicle(vehicle.PlateNumber)
+	if err != nil || trip == nil || trip.DeliveryPlanID != "" {
+		return err
+	}
+	return closeVehicleTrip(vehicle)
+}
+
+// closeVehicleTrip 车辆完成运输时结束其进行中的趟次，终点取线路最后一个点。计费里程由配置的行驶距离服务
+// 计算线路起止点间的道路里程，与空驶里程口径一致；线路没有几何时取线路里程
+func closeVehicleTrip(vehicle *entity.Vehicle) error {
+	trip, err := entity.GetOpenTripByVehicle(vehicle.PlateNumber)
+	if err != nil || trip == nil {
+		return err
+	}
+	end := vehicle.LngLat
+	var distance float64
+	if trip.RouteID != "" {
+		route, err := entity.GetRouteById(trip.RouteID)
+		if err != nil {
+			return err
+		}
+		distance = route.Distance
+		if len(route.Points) > 0 {
+			firstPoint, lastPoint := route.Points[0], route.Points[len(route.Points)-1]
+			end = lastPoint.LngLat()
+			cost := routeCost(context.Background(), firstPoint.LngLat(), end)
+			distance = roundToPrecision(cost.Distance, 10000)
+		}
+	}
+	return entity.CloseTrip(trip, distance, end)
+}
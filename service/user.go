@@ -1,11 +1,16 @@
 package service
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"go_logistics/common"
+	"go_logistics/config"
 	"go_logistics/model/entity"
 	"go_logistics/model/vo"
 	"go_logistics/util"
+	"slices"
 	"strconv"
 )
 
@@ -31,20 +36,42 @@ func CreateUser(c *gin.Context) {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
+	// 未指定角色时使用默认的只读角色
+	role := util.DefaultUserRole
+	if roleName := c.PostForm("role"); roleName != "" {
+		parsed, err := util.ParseUserRole(roleName)
+		if err != nil {
+			common.ErrorResponse(c, common.ValidateError(err.Error()))
+			return
+		}
+		role = parsed
+	}
 	user, _ := entity.GetUserByName(name)
 	if user.Status != 0 {
 		common.ErrorResponse(c, common.RecordExist)
 		return
 	}
+	if password != rePassword {
+		common.ErrorResponse(c, common.ValidateError("两次输入的密码不一致"))
+		return
+	}
+	if err := util.CheckPasswordPolicy(password, name); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	hash, err := util.HashPassword(password)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
 	user = &entity.User{}
 	user.Name = name
 	user.Phone = phone
 	user.Email = email
-	user.Salt = util.MakeSalt()
-	password = util.MakePassword(password, user.Salt)
-	user.Password = password
+	user.Password = hash
 	user.Status = entity.Active
-	err := entity.InsertUser(user)
+	user.Role = role
+	err = entity.InsertUser(user)
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
@@ -94,6 +121,13 @@ func UpdateUser(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	// 封禁或删除后立即吊销该用户的全部会话
+	if user.Status != entity.Active {
+		if err = revokeUserSessions(name, ""); err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+	}
 	common.SuccessResponse(c)
 }
 
@@ -109,6 +143,42 @@ func DeleteUser(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	if err = revokeUserSessions(name, ""); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
+// AssignUserOutlets 分配用户可见的网点，outletIds 为网点ID的 JSON 数组，传空数组表示取消分配
+func AssignUserOutlets(c *gin.Context) {
+	name := c.PostForm("name")
+	outletIdsStr := c.PostForm("outletIds")
+	if name == "" || outletIdsStr == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	var outletIds []string
+	if err := json.Unmarshal([]byte(outletIdsStr), &outletIds); err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	outletIds = slices.Compact(slices.Sorted(slices.Values(outletIds)))
+	for _, outletId := range outletIds {
+		if _, err := entity.GetOutletById(outletId); err != nil {
+			common.ErrorResponse(c, common.ValidateError(fmt.Sprintf("网点 %s 不存在", outletId)))
+			return
+		}
+	}
+	if err := entity.UpdateUserOutlets(name, outletIds); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	// 访问 Token 中携带分配的网点，修改后需重新登录
+	if err := revokeUserSessions(name, ""); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
 	common.SuccessResponse(c)
 }
 
@@ -120,32 +190,113 @@ func LoginUser(c *gin.Context) {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
+	// 校验密码前原子地预占一次尝试，账号或 IP 失败次数过多时拒绝登录，并发请求无法绕过限制
+	reservation, ok := reserveLoginAttempt(c, LoginClientAdmin, name)
+	if !ok {
+		return
+	}
 	user, err := entity.GetUserByName(name)
 	if err != nil {
-		common.ErrorResponse(c, common.RecordNotFound)
+		// 与密码错误返回相同的错误，避免通过登录接口探测用户名是否存在
+		recordLoginFailure(c, reservation, "用户不存在")
+		common.ErrorResponse(c, common.UserNameOrPasswordError)
 		return
 	}
 	if user.Status == entity.Banned {
+		rejectLogin(c, reservation, "用户被封禁")
 		common.ErrorResponse(c, common.UserBanned)
 		return
 	}
 	if user.Status == entity.Deleted {
+		rejectLogin(c, reservation, "用户已删除")
 		common.ErrorResponse(c, common.UserDeleted)
 		return
 	}
-	if !util.ValidPassword(password, user.Salt, user.Password) {
+	valid, needsRehash := util.VerifyPassword(password, user.Salt, user.Password)
+	if !valid {
+		recordLoginFailure(c, reservation, "密码错误")
 		common.ErrorResponse(c, common.UserNameOrPasswordError)
+		return
 	}
-	token, err := util.GenerateToken(user.Name)
+	// 旧版 MD5 或参数过时的哈希在密码校验通过后重新计算，失败不影响本次登录
+	if needsRehash {
+		rehashPassword(user, password)
+	}
+	refreshToken, sessionId, err := createSession(c, user.Name, user.Role)
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
-	c.Header("logistics_token", token)
+	token, err := util.GenerateToken(user.Name, user.Role, user.OutletIDs, sessionId)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	recordLoginSuccess(c, reservation)
+	respondTokens(c, token, refreshToken)
 	common.SuccessResponseWithData(c, vo.ToUserVO(user))
 	return
 }
 
+// rehashPassword 使用当前算法重新计算用户密码哈希，只能在 password 已通过校验后调用
+func rehashPassword(user *entity.User, password string) {
+	hash, err := util.HashPassword(password)
+	if err == nil {
+		err = entity.RehashUserPassword(user.Name, user.Password, hash)
+	}
+	if err != nil {
+		config.Log.Warn("重新计算密码哈希失败", zap.String("name", user.Name), zap.Error(err))
+	}
+}
+
+// ChangePassword 修改当前用户密码，需校验原密码
+func ChangePassword(c *gin.Context) {
+	name := c.GetString("name")
+	oldPassword := c.PostForm("oldPassword")
+	newPassword := c.PostForm("newPassword")
+	rePassword := c.PostForm("rePassword")
+	if name == "" || oldPassword == "" || newPassword == "" || rePassword == "" {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	if newPassword != rePassword {
+		common.ErrorResponse(c, common.ValidateError("两次输入的密码不一致"))
+		return
+	}
+	if newPassword == oldPassword {
+		common.ErrorResponse(c, common.ValidateError("新密码不能与原密码相同"))
+		return
+	}
+	user, err := entity.GetUserByName(name)
+	if err != nil {
+		common.ErrorResponse(c, common.RecordNotFound)
+		return
+	}
+	if valid, _ := util.VerifyPassword(oldPassword, user.Salt, user.Password); !valid {
+		common.ErrorResponse(c, common.UserNameOrPasswordError)
+		return
+	}
+	if err = util.CheckPasswordPolicy(newPassword, name); err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
+	hash, err := util.HashPassword(newPassword)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	if err = entity.UpdateUserPassword(name, hash); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	// 修改密码后其他设备需要重新登录
+	if err = revokeUserSessions(name, c.GetString("sessionId")); err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	common.SuccessResponse(c)
+}
+
 // GetUserLoginStatus 获取用户登录状态
 func GetUserLoginStatus(c *gin.Context) {
 	name := c.GetString("name")
@@ -153,7 +304,7 @@ func GetUserLoginStatus(c *gin.Context) {
 		common.ErrorResponse(c, common.NotLogin)
 		return
 	}
-	common.SuccessResponseWithData(c, &entity.User{Name: name})
+	common.SuccessResponseWithData(c, &entity.User{Name: name, Role: util.Role(c.GetString("role"))})
 }
 
 func GetTotalCount(c *gin.Context) {
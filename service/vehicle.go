@@ -27,6 +27,17 @@ func CreateVehicle(c *gin.Context) {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	// 位置为可选参数
+	position, err := parseStorageLngLat(lng, lat, crs)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
 
 	vehicle := &entity.Vehicle{
 		PlateNumber:  plateNumber,
@@ -35,8 +46,7 @@ func CreateVehicle(c *gin.Context) {
 		Status:       entity.VehicleStatus(statusInt),
 		RouteID:      routeId,
 		Remarks:      remarks,
-		Lng:          lng,
-		Lat:          lat,
+		LngLat:       position,
 	}
 
 	err = entity.InsertVehicle(vehicle)
@@ -49,8 +59,13 @@ func CreateVehicle(c *gin.Context) {
 
 // GetVehicleList 获取车辆列表
 func GetVehicleList(c *gin.Context) {
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
 	var dto entity.FindVehicleListDTO
-	if err := c.ShouldBindJSON(&dto); err != nil {
+	if err := bindScopedQuery(c, &dto); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -64,6 +79,7 @@ func GetVehicleList(c *gin.Context) {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	vo.ConvertVehicleVOListCRS(vehicleVOs, crs)
 	common.SuccessResponseWithData(c, vehicleVOs)
 }
 
@@ -87,6 +103,17 @@ func UpdateVehicle(c *gin.Context) {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
+	crs, err := requestCRS(c, util.DefaultClientCRS)
+	if err != nil {
+		common.ErrorResponse(c, common.ParamError)
+		return
+	}
+	// 位置为可选参数
+	position, err := parseStorageLngLat(lng, lat, crs)
+	if err != nil {
+		common.ErrorResponse(c, common.ValidateError(err.Error()))
+		return
+	}
 
 	vehicle := &entity.Vehicle{
 		PlateNumber:  plateNumber,
@@ -96,8 +123,7 @@ func UpdateVehicle(c *gin.Context) {
 		Status:       entity.VehicleStatus(statusInt),
 		RouteID:      routeId,
 		Remarks:      remarks,
-		Lng:          lng,
-		Lat:          lat,
+		LngLat:       position,
 	}
 
 	err = entity.UpdateVehicle(vehicle)
@@ -126,7 +152,7 @@ func DeleteVehicle(c *gin.Context) {
 // GetVehicleTotalCount 获取车辆总数
 func GetVehicleTotalCount(c *gin.Context) {
 	var dto entity.FindVehicleListDTO
-	if err := c.ShouldBindJSON(&dto); err != nil {
+	if err := bindScopedQuery(c, &dto); err != nil {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
@@ -182,16 +208,65 @@ func CompleteTransport(c *gin.Context) {
 		common.ErrorResponse(c, common.ParamError)
 		return
 	}
+	// 车辆在途说明货物已装车，司机未在司机端确认取件的订单以完成运输时间作为取件时间
+	err = entity.MarkVehicleOrdersPickedUp(vehicle.PlateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	err = closeVehicleTrip(vehicle)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
 	err = resetVehicle(vehicle)
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	// 送往中转枢纽的订单在枢纽等待下一段调度，其余订单完成
+	transferOrders, err := entity.GetTransferOrdersByVehicle(vehicle.PlateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
+	for _, order := range transferOrders {
+		err = entity.ArriveOrderAtTransfer(order, vehicle.PlateNumber)
+		if err != nil {
+			common.ErrorResponse(c, common.ServerError(err.Error()))
+			return
+		}
+		recordOrderEvent(&entity.OrderEvent{
+			OrderID:     order.OrderID,
+			Type:        entity.OrderEventTransfer,
+			OutletID:    order.LegOutletId,
+			PlateNumber: vehicle.PlateNumber,
+		})
+	}
+	completedOrders, err := entity.GetProcessingOrdersByVehicle(vehicle.PlateNumber)
+	if err != nil {
+		common.ErrorResponse(c, common.ServerError(err.Error()))
+		return
+	}
 	err = entity.CompleteOrderByVehicle(vehicle)
 	if err != nil {
 		common.ErrorResponse(c, common.ServerError(err.Error()))
 		return
 	}
+	for _, order := range completedOrders {
+		recordOrderEvent(&entity.OrderEvent{
+			OrderID:     order.OrderID,
+			Type:        entity.OrderEventCompleted,
+			OutletID:    order.EndOutletId,
+			PlateNumber: vehicle.PlateNumber,
+		})
+	}
+	for _, order := range transferOrders {
+		orderId := order.OrderID
+		_ = taskPool.Submit(func() {
+			completeDataOrder(orderId)
+		})
+	}
 	common.SuccessResponse(c)
 }
 
@@ -204,8 +279,7 @@ func resetVehicle(vehicle *entity.Vehicle) error {
 	}
 	points := route.Points
 	lastPoint := points[len(points)-1]
-	vehicle.Lng = fmt.Sprintf("%v", lastPoint.Coordinates[0])
-	vehicle.Lat = fmt.Sprintf("%v", lastPoint.Coordinates[1])
+	vehicle.LngLat = lastPoint.LngLat()
 	vehicle.RouteID = ""
 	vehicle.RouteName = ""
 	vehicleMu := util.GetVehicleLock(vehicle.PlateNumber)
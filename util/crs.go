@@ -0,0 +1,189 @@
+package util
+
+import (
+	"fmt"
+	"github.com/paulmach/orb"
+	"go_logistics/common"
+	"math"
+	"strings"
+)
+
+// CRS 坐标系
+type CRS string
+
+const (
+	CRSWGS84 CRS = "wgs84" // GPS 与 GeoJSON 使用的坐标系
+	CRSGCJ02 CRS = "gcj02" // 国测局坐标系，高德、腾讯地图使用
+	CRSBD09  CRS = "bd09"  // 百度地图坐标系
+
+	// StorageCRS 数据库中统一存储的坐标系，距离计算与 2dsphere 索引都基于该坐标系
+	StorageCRS = CRSWGS84
+	// DefaultClientCRS 接口未指定 crs 参数时输入输出使用的坐标系，前端使用百度地图
+	DefaultClientCRS = CRSBD09
+	// LegacyCRS 未标注坐标系的历史数据所使用的坐标系
+	LegacyCRS = CRSBD09
+)
+
+const (
+	krasovskyA  = 6378245.0              // 克拉索夫斯基椭球长半轴
+	krasovskyEE = 0.00669342162296594323 // 克拉索夫斯基椭球第一偏心率平方
+	bdXPi       = math.Pi * 3000.0 / 180.0
+	// gcjInverseTolerance GCJ-02 反算 WGS-84 迭代的收敛精度，单位度
+	gcjInverseTolerance = 1e-9
+	gcjInverseMaxRounds = 30
+)
+
+// ParseCRS 解析坐标系名称，为空时返回默认值
+func ParseCRS(name string, defaultCRS CRS) (CRS, error) {
+	switch strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", "")) {
+	case "":
+		return defaultCRS, nil
+	case "wgs84":
+		return CRSWGS84, nil
+	case "gcj02":
+		return CRSGCJ02, nil
+	case "bd09", "bd09ll":
+		return CRSBD09, nil
+	}
+	return "", fmt.Errorf("不支持的坐标系: %s", name)
+}
+
+// OrLegacy 未标注坐标系时按历史数据的坐标系处理
+func (c CRS) OrLegacy() CRS {
+	if c == "" {
+		return LegacyCRS
+	}
+	return c
+}
+
+// outOfChina 国测局加密只作用于中国境内的坐标
+func outOfChina(lng, lat float64) bool {
+	return lng < 72.004 || lng > 137.8347 || lat < 0.8293 || lat > 55.8271
+}
+
+func transformLat(x, y float64) float64 {
+	result := -100.0 + 2.0*x + 3.0*y + 0.2*y*y + 0.1*x*y + 0.2*math.Sqrt(math.Abs(x))
+	result += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	result += (20.0*math.Sin(y*math.Pi) + 40.0*math.Sin(y/3.0*math.Pi)) * 2.0 / 3.0
+	result += (160.0*math.Sin(y/12.0*math.Pi) + 320*math.Sin(y*math.Pi/30.0)) * 2.0 / 3.0
+	return result
+}
+
+func transformLng(x, y float64) float64 {
+	result := 300.0 + x + 2.0*y + 0.1*x*x + 0.1*x*y + 0.1*math.Sqrt(math.Abs(x))
+	result += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	result += (20.0*math.Sin(x*math.Pi) + 40.0*math.Sin(x/3.0*math.Pi)) * 2.0 / 3.0
+	result += (150.0*math.Sin(x/12.0*math.Pi) + 300.0*math.Sin(x/30.0*math.Pi)) * 2.0 / 3.0
+	return result
+}
+
+// WGS84ToGCJ02 WGS-84 转 GCJ-02
+func WGS84ToGCJ02(lng, lat float64) (float64, float64) {
+	if outOfChina(lng, lat) {
+		return lng, lat
+	}
+	dLat := transformLat(lng-105.0, lat-35.0)
+	dLng := transformLng(lng-105.0, lat-35.0)
+	radLat := lat / 180.0 * math.Pi
+	magic := 1 - krasovskyEE*math.Sin(radLat)*math.Sin(radLat)
+	sqrtMagic := math.Sqrt(magic)
+	dLat = (dLat * 180.0) / ((krasovskyA * (1 - krasovskyEE)) / (magic * sqrtMagic) * math.Pi)
+	dLng = (dLng * 180.0) / (krasovskyA / sqrtMagic * math.Cos(radLat) * math.Pi)
+	return lng + dLng, lat + dLat
+}
+
+// GCJ02ToWGS84 GCJ-02 转 WGS-84，迭代反算，精度约 1e-9 度
+func GCJ02ToWGS84(lng, lat float64) (float64, float64) {
+	if outOfChina(lng, lat) {
+		return lng, lat
+	}
+	wgsLng, wgsLat := lng, lat
+	for i := 0; i < gcjInverseMaxRounds; i++ {
+		gcjLng, gcjLat := WGS84ToGCJ02(wgsLng, wgsLat)
+		dLng, dLat := gcjLng-lng, gcjLat-lat
+		wgsLng -= dLng
+		wgsLat -= dLat
+		if math.Abs(dLng) < gcjInverseTolerance && math.Abs(dLat) < gcjInverseTolerance {
+			break
+		}
+	}
+	return wgsLng, wgsLat
+}
+
+// GCJ02ToBD09 GCJ-02 转 BD-09
+func GCJ02ToBD09(lng, lat float64) (float64, float64) {
+	z := math.Sqrt(lng*lng+lat*lat) + 0.00002*math.Sin(lat*bdXPi)
+	theta := math.Atan2(lat, lng) + 0.000003*math.Cos(lng*bdXPi)
+	return z*math.Cos(theta) + 0.0065, z*math.Sin(theta) + 0.006
+}
+
+// BD09ToGCJ02 BD-09 转 GCJ-02
+func BD09ToGCJ02(lng, lat float64) (float64, float64) {
+	x, y := lng-0.0065, lat-0.006
+	z := math.Sqrt(x*x+y*y) - 0.00002*math.Sin(y*bdXPi)
+	theta := math.Atan2(y, x) - 0.000003*math.Cos(x*bdXPi)
+	return z * math.Cos(theta), z * math.Sin(theta)
+}
+
+// WGS84ToBD09 WGS-84 转 BD-09
+func WGS84ToBD09(lng, lat float64) (float64, float64) {
+	return GCJ02ToBD09(WGS84ToGCJ02(lng, lat))
+}
+
+// BD09ToWGS84 BD-09 转 WGS-84
+func BD09ToWGS84(lng, lat float64) (float64, float64) {
+	return GCJ02ToWGS84(BD09ToGCJ02(lng, lat))
+}
+
+// ConvertCoordinate 在任意两个坐标系之间转换，经 GCJ-02 中转
+func ConvertCoordinate(lng, lat float64, from CRS, to CRS) (float64, float64) {
+	if from == to {
+		return lng, lat
+	}
+	switch from {
+	case CRSWGS84:
+		lng, lat = WGS84ToGCJ02(lng, lat)
+	case CRSBD09:
+		lng, lat = BD09ToGCJ02(lng, lat)
+	}
+	switch to {
+	case CRSWGS84:
+		return GCJ02ToWGS84(lng, lat)
+	case CRSBD09:
+		return GCJ02ToBD09(lng, lat)
+	}
+	return lng, lat
+}
+
+// ConvertLngLat 转换坐标点，未设置的位置原样返回
+func ConvertLngLat(point common.LngLat, from CRS, to CRS) common.LngLat {
+	if point.IsZero() || from == to {
+		return point
+	}
+	point.Lng, point.Lat = ConvertCoordinate(point.Lng, point.Lat, from, to)
+	return point
+}
+
+// ConvertGeoPoints 转换点位坐标，返回新的切片
+func ConvertGeoPoints(points []common.GeoPoint, from CRS, to CRS) []common.GeoPoint {
+	if from == to || points == nil {
+		return points
+	}
+	result := make([]common.GeoPoint, len(points))
+	for i, point := range points {
+		result[i] = point
+		if len(point.Coordinates) >= 2 {
+			lng, lat := ConvertCoordinate(point.Coordinates[0], point.Coordinates[1], from, to)
+			result[i].Coordinates = []float64{lng, lat}
+		}
+	}
+	return result
+}
+
+// CRSProjection 返回坐标系转换的 orb 投影，配合 project.Geometry 转换 GeoJSON 几何
+func CRSProjection(from CRS, to CRS) orb.Projection {
+	return func(point orb.Point) orb.Point {
+		lng, lat := ConvertCoordinate(point[0], point[1], from, to)
+		return orb.Point{lng, lat}
+	}
+}
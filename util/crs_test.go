@@ -0,0 +1,133 @@
+package util
+
+import (
+	"go_logistics/common"
+	"math"
+	"testing"
+)
+
+// crsSamples 测试用的坐标点，均位于中国境内
+var crsSamples = []struct {
+	name     string
+	lng, lat float64
+}{
+	{"北京天安门", 116.397428, 39.90923},
+	{"上海陆家嘴", 121.499718, 31.239703},
+	{"广州塔", 113.324520, 23.106414},
+	{"乌鲁木齐", 87.617733, 43.792818},
+	{"三亚", 109.511909, 18.252847},
+}
+
+func TestParseCRS(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    CRS
+		wantErr bool
+	}{
+		{"", DefaultClientCRS, false},
+		{"wgs84", CRSWGS84, false},
+		{"WGS-84", CRSWGS84, false},
+		{" gcj02 ", CRSGCJ02, false},
+		{"GCJ-02", CRSGCJ02, false},
+		{"bd09", CRSBD09, false},
+		{"bd09ll", CRSBD09, false},
+		{"BD-09", CRSBD09, false},
+		{"epsg3857", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCRS(tt.input, DefaultClientCRS)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCRS(%q) = %q, %v，期望 %q，出错 %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestConvertCoordinateRoundTrip(t *testing.T) {
+	tests := []struct {
+		from, to  CRS
+		tolerance float64 // 往返误差上限，单位度
+	}{
+		{CRSWGS84, CRSGCJ02, 1e-8},
+		{CRSGCJ02, CRSWGS84, 1e-8},
+		{CRSGCJ02, CRSBD09, 1e-5},
+		{CRSBD09, CRSGCJ02, 1e-5},
+		{CRSWGS84, CRSBD09, 1e-5},
+		{CRSBD09, CRSWGS84, 1e-5},
+	}
+	for _, tt := range tests {
+		for _, sample := range crsSamples {
+			lng, lat := ConvertCoordinate(sample.lng, sample.lat, tt.from, tt.to)
+			if math.Abs(lng-sample.lng) < 1e-4 && math.Abs(lat-sample.lat) < 1e-4 {
+				t.Errorf("%s %s -> %s 坐标没有偏移: (%f, %f)", sample.name, tt.from, tt.to, lng, lat)
+			}
+			backLng, backLat := ConvertCoordinate(lng, lat, tt.to, tt.from)
+			if math.Abs(backLng-sample.lng) > tt.tolerance || math.Abs(backLat-sample.lat) > tt.tolerance {
+				t.Errorf("%s %s -> %s -> %s 往返误差过大: (%.9f, %.9f)", sample.name, tt.from, tt.to, tt.from,
+					backLng-sample.lng, backLat-sample.lat)
+			}
+		}
+	}
+}
+
+func TestConvertCoordinateOffset(t *testing.T) {
+	// GCJ-02 相对 WGS-84 的偏移在国内约为数百米
+	for _, sample := range crsSamples {
+		lng, lat := WGS84ToGCJ02(sample.lng, sample.lat)
+		offset := GetDistance(sample.lat, sample.lng, lat, lng)
+		if offset < 0.05 || offset > 1 {
+			t.Errorf("%s WGS-84 与 GCJ-02 相距 %.3f 公里，超出预期范围", sample.name, offset)
+		}
+	}
+}
+
+func TestConvertCoordinateOutOfChina(t *testing.T) {
+	// 境外坐标不做国测局加密，WGS-84 与 GCJ-02 之间原样返回
+	tests := []struct {
+		name     string
+		lng, lat float64
+	}{
+		{"伦敦", -0.127758, 51.507351},
+		{"纽约", -74.005941, 40.712784},
+		{"悉尼", 151.209296, -33.868820},
+	}
+	for _, tt := range tests {
+		for _, pair := range [][2]CRS{{CRSWGS84, CRSGCJ02}, {CRSGCJ02, CRSWGS84}} {
+			lng, lat := ConvertCoordinate(tt.lng, tt.lat, pair[0], pair[1])
+			if lng != tt.lng || lat != tt.lat {
+				t.Errorf("%s %s -> %s = (%f, %f)，期望原样返回", tt.name, pair[0], pair[1], lng, lat)
+			}
+		}
+	}
+}
+
+func TestConvertLngLat(t *testing.T) {
+	if got := ConvertLngLat(common.LngLat{}, CRSBD09, CRSWGS84); !got.IsZero() {
+		t.Errorf("未设置的坐标应原样返回，实际 %v", got)
+	}
+	point := common.LngLat{Lng: crsSamples[0].lng, Lat: crsSamples[0].lat}
+	if got := ConvertLngLat(point, CRSWGS84, CRSWGS84); got != point {
+		t.Errorf("相同坐标系应原样返回，实际 %v", got)
+	}
+	got := ConvertLngLat(ConvertLngLat(point, StorageCRS, DefaultClientCRS), DefaultClientCRS, StorageCRS)
+	if math.Abs(got.Lng-point.Lng) > 1e-5 || math.Abs(got.Lat-point.Lat) > 1e-5 {
+		t.Errorf("存储坐标系与前端坐标系往返后为 %v，期望 %v", got, point)
+	}
+}
+
+func TestConvertGeoPoints(t *testing.T) {
+	points := []common.GeoPoint{
+		{Type: "Point", Coordinates: []float64{crsSamples[1].lng, crsSamples[1].lat}},
+		{Type: "Point"},
+	}
+	converted := ConvertGeoPoints(points, CRSWGS84, CRSGCJ02)
+	if points[0].Coordinates[0] != crsSamples[1].lng {
+		t.Fatal("转换不应修改入参")
+	}
+	if len(converted[1].Coordinates) != 0 {
+		t.Errorf("没有坐标的点应原样返回，实际 %v", converted[1].Coordinates)
+	}
+	back := ConvertGeoPoints(converted, CRSGCJ02, CRSWGS84)
+	if math.Abs(back[0].Coordinates[0]-crsSamples[1].lng) > 1e-8 || math.Abs(back[0].Coordinates[1]-crsSamples[1].lat) > 1e-8 {
+		t.Errorf("往返后坐标为 %v", back[0].Coordinates)
+	}
+}
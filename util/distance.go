@@ -0,0 +1,366 @@
+package util
+
+import (
+	"container/heap"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_logistics/common"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BaiduRouteMatrixUrl = "https://api.map.baidu.com/routematrix/v2/driving"
+	// baiduRouteMatrixMaxElements 百度批量算路单次请求起点数 × 终点数的上限
+	baiduRouteMatrixMaxElements = 50
+	// DefaultAverageSpeed 按直线距离估算行驶时长时的平均车速，单位公里/小时
+	DefaultAverageSpeed = 40.0
+	// roadGraphAccessSpeed 坐标点到路网最近节点的接驳车速，单位公里/小时
+	roadGraphAccessSpeed = 20.0
+)
+
+// RouteCost 两点之间的行驶距离与时长
+type RouteCost struct {
+	Distance float64 `bson:"distance" json:"distance"` // 行驶距离，单位公里
+	Duration float64 `bson:"duration" json:"duration"` // 行驶时长，单位小时
+}
+
+// Time 行驶时长
+func (c RouteCost) Time() time.Duration {
+	return time.Duration(c.Duration * float64(time.Hour))
+}
+
+// DistanceProvider 行驶距离服务，入参坐标为存储坐标系。
+// Matrix 返回 origins × destinations 的距离矩阵，result[i][j] 为 origins[i] 到 destinations[j] 的行驶距离
+type DistanceProvider interface {
+	Name() string
+	Matrix(ctx context.Context, origins []common.LngLat, destinations []common.LngLat) ([][]RouteCost, error)
+}
+
+// NewRouteMatrix 创建 rows × columns 的空距离矩阵
+func NewRouteMatrix(rows int, columns int) [][]RouteCost {
+	matrix := make([][]RouteCost, rows)
+	for i := range matrix {
+		matrix[i] = make([]RouteCost, columns)
+	}
+	return matrix
+}
+
+// HaversineProvider 按球面直线距离与平均车速估算，作为未配置路网服务或路网服务不可用时的兜底
+type HaversineProvider struct {
+	Speed float64 // 平均车速，单位公里/小时，为 0 时使用 DefaultAverageSpeed
+}
+
+// Name 服务名称
+func (p *HaversineProvider) Name() string {
+	return "haversine"
+}
+
+// Matrix 计算直线距离矩阵
+func (p *HaversineProvider) Matrix(_ context.Context, origins []common.LngLat, destinations []common.LngLat) ([][]RouteCost, error) {
+	speed := p.Speed
+	if speed <= 0 {
+		speed = DefaultAverageSpeed
+	}
+	matrix := NewRouteMatrix(len(origins), len(destinations))
+	for i, origin := range origins {
+		for j, destination := range destinations {
+			distance := GetLngLatDistance(origin, destination)
+			matrix[i][j] = RouteCost{Distance: distance, Duration: distance / speed}
+		}
+	}
+	return matrix, nil
+}
+
+// BaiduDistanceProvider 百度地图批量算路（驾车）服务
+type BaiduDistanceProvider struct {
+	ak     string
+	client *http.Client
+}
+
+// NewBaiduDistanceProvider 使用百度地图服务端 AK 创建行驶距离服务
+func NewBaiduDistanceProvider(ak string) *BaiduDistanceProvider {
+	return &BaiduDistanceProvider{
+		ak:     ak,
+		client: &http.Client{Timeout: geocoderTimeout},
+	}
+}
+
+type baiduRouteMatrixResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Result  []struct {
+		Distance struct {
+			Value float64 `json:"value"` // 单位米
+		} `json:"distance"`
+		Duration struct {
+			Value float64 `json:"value"` // 单位秒
+		} `json:"duration"`
+	} `json:"result"`
+}
+
+// Name 服务名称
+func (p *BaiduDistanceProvider) Name() string {
+	return "baidu"
+}
+
+// Matrix 按百度接口的元素数上限拆分终点分批请求
+func (p *BaiduDistanceProvider) Matrix(ctx context.Context, origins []common.LngLat, destinations []common.LngLat) ([][]RouteCost, error) {
+	matrix := NewRouteMatrix(len(origins), len(destinations))
+	if len(destinations) == 0 {
+		return matrix, nil
+	}
+	columns := min(len(destinations), baiduRouteMatrixMaxElements)
+	rows := max(baiduRouteMatrixMaxElements/columns, 1)
+	for i := 0; i < len(origins); i += rows {
+		originBatch := origins[i:min(i+rows, len(origins))]
+		for j := 0; j < len(destinations); j += columns {
+			destinationBatch := destinations[j:min(j+columns, len(destinations))]
+			costs, err := p.request(ctx, originBatch, destinationBatch)
+			if err != nil {
+				return nil, err
+			}
+			for k, cost := range costs {
+				matrix[i+k/len(destinationBatch)][j+k%len(destinationBatch)] = cost
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// request 请求一批起终点，结果按起点优先的顺序排列
+func (p *BaiduDistanceProvider) request(ctx context.Context, origins []common.LngLat, destinations []common.LngLat) ([]RouteCost, error) {
+	params := url.Values{}
+	params.Set("origins", joinBaiduLocations(origins))
+	params.Set("destinations", joinBaiduLocations(destinations))
+	params.Set("coord_type", "wgs84")
+	params.Set("output", "json")
+	params.Set("ak", p.ak)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, BaiduRouteMatrixUrl+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("请求百度地图服务失败: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求百度地图服务失败: HTTP %d", response.StatusCode)
+	}
+	var result baiduRouteMatrixResponse
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		return nil, fmt.Errorf("百度批量算路失败: %s", result.Message)
+	}
+	if len(result.Result) != len(origins)*len(destinations) {
+		return nil, fmt.Errorf("百度批量算路结果数量不符: %d", len(result.Result))
+	}
+	costs := make([]RouteCost, len(result.Result))
+	for i, element := range result.Result {
+		costs[i] = RouteCost{Distance: element.Distance.Value / 1000, Duration: element.Duration.Value / 3600}
+	}
+	return costs, nil
+}
+
+// joinBaiduLocations 百度接口的坐标为“纬度,经度”，多个坐标以竖线分隔
+func joinBaiduLocations(points []common.LngLat) string {
+	locations := make([]string, len(points))
+	for i, point := range points {
+		locations[i] = strconv.FormatFloat(point.Lat, 'f', 6, 64) + "," + strconv.FormatFloat(point.Lng, 'f', 6, 64)
+	}
+	return strings.Join(locations, "|")
+}
+
+// roadEdge 路网中的有向边
+type roadEdge struct {
+	to   int
+	cost RouteCost
+}
+
+// RoadGraphProvider 基于本地路网文件的离线算路（OSRM 风格的最短路表），用于无法访问外网的部署。
+// 坐标点吸附到最近的路网节点，接驳段按直线距离计算；不连通的两点退化为直线距离
+type RoadGraphProvider struct {
+	nodes []common.LngLat
+	edges [][]roadEdge
+}
+
+// roadGraphColumns 路网 CSV 文件的表头，distance 单位米，duration 单位秒（为空时按平均车速估算），
+// oneway 为 1 表示单行道
+var roadGraphColumns = []string{"from_lng", "from_lat", "to_lng", "to_lat", "distance", "duration", "oneway"}
+
+// LoadRoadGraph 从 CSV 文件加载路网，坐标为存储坐标系，坐标相同的端点视为同一节点
+func LoadRoadGraph(path string) (*RoadGraphProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开路网文件失败: %w", err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取路网表头失败: %w", err)
+	}
+	index := make(map[string]int, len(headers))
+	for i, name := range headers {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, column := range roadGraphColumns {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("路网文件缺少列: %s", column)
+		}
+	}
+
+	graph := &RoadGraphProvider{}
+	nodeIndex := make(map[common.LngLat]int)
+	node := func(point common.LngLat) int {
+		if i, ok := nodeIndex[point]; ok {
+			return i
+		}
+		nodeIndex[point] = len(graph.nodes)
+		graph.nodes = append(graph.nodes, point)
+		graph.edges = append(graph.edges, nil)
+		return len(graph.nodes) - 1
+	}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("路网文件第%d行格式错误: %w", line, err)
+		}
+		from, err := common.ParseLngLat(record[index["from_lng"]], record[index["from_lat"]])
+		if err != nil {
+			return nil, fmt.Errorf("路网文件第%d行起点错误: %w", line, err)
+		}
+		to, err := common.ParseLngLat(record[index["to_lng"]], record[index["to_lat"]])
+		if err != nil {
+			return nil, fmt.Errorf("路网文件第%d行终点错误: %w", line, err)
+		}
+		distance, err := strconv.ParseFloat(record[index["distance"]], 64)
+		if err != nil || distance < 0 {
+			return nil, fmt.Errorf("路网文件第%d行距离错误", line)
+		}
+		cost := RouteCost{Distance: distance / 1000, Duration: distance / 1000 / DefaultAverageSpeed}
+		if value := strings.TrimSpace(record[index["duration"]]); value != "" {
+			duration, err := strconv.ParseFloat(value, 64)
+			if err != nil || duration < 0 {
+				return nil, fmt.Errorf("路网文件第%d行时长错误", line)
+			}
+			cost.Duration = duration / 3600
+		}
+		a, b := node(from), node(to)
+		graph.edges[a] = append(graph.edges[a], roadEdge{to: b, cost: cost})
+		if strings.TrimSpace(record[index["oneway"]]) != "1" {
+			graph.edges[b] = append(graph.edges[b], roadEdge{to: a, cost: cost})
+		}
+	}
+	if len(graph.nodes) == 0 {
+		return nil, fmt.Errorf("路网文件为空")
+	}
+	return graph, nil
+}
+
+// Name 服务名称
+func (g *RoadGraphProvider) Name() string {
+	return "osrm"
+}
+
+// Matrix 对每个起点执行一次 Dijkstra 最短路（按行驶距离）
+func (g *RoadGraphProvider) Matrix(ctx context.Context, origins []common.LngLat, destinations []common.LngLat) ([][]RouteCost, error) {
+	matrix := NewRouteMatrix(len(origins), len(destinations))
+	destinationNodes := make([]int, len(destinations))
+	destinationAccess := make([]RouteCost, len(destinations))
+	for j, destination := range destinations {
+		destinationNodes[j], destinationAccess[j] = g.snap(destination)
+	}
+	for i, origin := range origins {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		originNode, originAccess := g.snap(origin)
+		costs := g.shortestPaths(originNode)
+		for j, destination := range destinations {
+			cost, ok := costs[destinationNodes[j]]
+			if !ok {
+				distance := GetLngLatDistance(origin, destination)
+				matrix[i][j] = RouteCost{Distance: distance, Duration: distance / DefaultAverageSpeed}
+				continue
+			}
+			matrix[i][j] = RouteCost{
+				Distance: originAccess.Distance + cost.Distance + destinationAccess[j].Distance,
+				Duration: originAccess.Duration + cost.Duration + destinationAccess[j].Duration,
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// snap 返回最近的路网节点及接驳段的距离与时长
+func (g *RoadGraphProvider) snap(point common.LngLat) (int, RouteCost) {
+	nearest, nearestDistance := 0, math.MaxFloat64
+	for i, node := range g.nodes {
+		if distance := GetLngLatDistance(point, node); distance < nearestDistance {
+			nearest, nearestDistance = i, distance
+		}
+	}
+	return nearest, RouteCost{Distance: nearestDistance, Duration: nearestDistance / roadGraphAccessSpeed}
+}
+
+// shortestPaths 计算起点到各可达节点的最短行驶距离及对应时长
+func (g *RoadGraphProvider) shortestPaths(source int) map[int]RouteCost {
+	costs := map[int]RouteCost{source: {}}
+	visited := make(map[int]bool)
+	queue := &roadQueue{{node: source}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(roadQueueItem)
+		if visited[current.node] {
+			continue
+		}
+		visited[current.node] = true
+		for _, edge := range g.edges[current.node] {
+			next := RouteCost{
+				Distance: current.cost.Distance + edge.cost.Distance,
+				Duration: current.cost.Duration + edge.cost.Duration,
+			}
+			if known, ok := costs[edge.to]; ok && known.Distance <= next.Distance {
+				continue
+			}
+			costs[edge.to] = next
+			heap.Push(queue, roadQueueItem{node: edge.to, cost: next})
+		}
+	}
+	return costs
+}
+
+type roadQueueItem struct {
+	node int
+	cost RouteCost
+}
+
+// roadQueue 按行驶距离排序的最小堆
+type roadQueue []roadQueueItem
+
+func (q roadQueue) Len() int           { return len(q) }
+func (q roadQueue) Less(i, j int) bool { return q[i].cost.Distance < q[j].cost.Distance }
+func (q roadQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *roadQueue) Push(x any)        { *q = append(*q, x.(roadQueueItem)) }
+func (q *roadQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
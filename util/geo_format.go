@@ -0,0 +1,334 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/simplify"
+	"go_logistics/common"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// GeoFormat 线路导入导出支持的文件格式
+type GeoFormat string
+
+const (
+	GeoFormatGPX     GeoFormat = "gpx"
+	GeoFormatKML     GeoFormat = "kml"
+	GeoFormatGeoJSON GeoFormat = "geojson"
+)
+
+// metersPerDegree 赤道处每度对应的米数，用于将简化容差换算为经纬度
+const metersPerDegree = 111320.0
+
+// ParseGeoFormat 解析格式名称，兼容文件扩展名写法
+func ParseGeoFormat(name string) (GeoFormat, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), ".") {
+	case "gpx":
+		return GeoFormatGPX, nil
+	case "kml":
+		return GeoFormatKML, nil
+	case "geojson", "json":
+		return GeoFormatGeoJSON, nil
+	}
+	return "", fmt.Errorf("不支持的文件格式: %s", name)
+}
+
+// ContentType 返回格式对应的 MIME 类型
+func (f GeoFormat) ContentType() string {
+	switch f {
+	case GeoFormatGPX:
+		return "application/gpx+xml"
+	case GeoFormatKML:
+		return "application/vnd.google-earth.kml+xml"
+	default:
+		return "application/geo+json"
+	}
+}
+
+// ParseLineString 按格式解析文件中的线路，多条线段按文件顺序首尾相接
+func ParseLineString(format GeoFormat, data []byte) (orb.LineString, error) {
+	var (
+		line orb.LineString
+		err  error
+	)
+	switch format {
+	case GeoFormatGPX:
+		line, err = parseGPX(data)
+	case GeoFormatKML:
+		line, err = parseKML(data)
+	case GeoFormatGeoJSON:
+		line, err = parseGeoJSON(data)
+	default:
+		return nil, fmt.Errorf("不支持的文件格式: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 {
+		return nil, fmt.Errorf("文件中没有找到有效的线路")
+	}
+	return line, nil
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+type gpxFile struct {
+	XMLName xml.Name `xml:"gpx"`
+	Tracks  []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+// parseGPX 解析 GPX 轨迹（trk），没有轨迹时使用路线（rte）
+func parseGPX(data []byte) (orb.LineString, error) {
+	var file gpxFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("GPX 文件解析失败: %v", err)
+	}
+	var line orb.LineString
+	for _, track := range file.Tracks {
+		for _, segment := range track.Segments {
+			for _, p := range segment.Points {
+				line = append(line, orb.Point{p.Lon, p.Lat})
+			}
+		}
+	}
+	if len(line) == 0 {
+		for _, route := range file.Routes {
+			for _, p := range route.Points {
+				line = append(line, orb.Point{p.Lon, p.Lat})
+			}
+		}
+	}
+	return line, nil
+}
+
+// parseKML 解析 KML 中所有 LineString 的 coordinates
+func parseKML(data []byte) (orb.LineString, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		line         orb.LineString
+		inLineString bool
+		inCoords     bool
+		coords       strings.Builder
+	)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("KML 文件解析失败: %v", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "LineString" {
+				inLineString = true
+			} else if inLineString && t.Name.Local == "coordinates" {
+				inCoords = true
+				coords.Reset()
+			}
+		case xml.CharData:
+			if inCoords {
+				coords.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "LineString" {
+				inLineString = false
+			} else if inCoords && t.Name.Local == "coordinates" {
+				inCoords = false
+				points, err := parseKMLCoordinates(coords.String())
+				if err != nil {
+					return nil, err
+				}
+				line = append(line, points...)
+			}
+		}
+	}
+	return line, nil
+}
+
+// parseKMLCoordinates 解析 "lng,lat[,alt]" 以空白分隔的坐标串
+func parseKMLCoordinates(text string) (orb.LineString, error) {
+	var line orb.LineString
+	for _, tuple := range strings.Fields(text) {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("KML 坐标格式错误: %s", tuple)
+		}
+		lng, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("KML 坐标格式错误: %s", tuple)
+		}
+		lat, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("KML 坐标格式错误: %s", tuple)
+		}
+		line = append(line, orb.Point{lng, lat})
+	}
+	return line, nil
+}
+
+// parseGeoJSON 解析 GeoJSON，支持 FeatureCollection、Feature 与裸几何对象中的 LineString/MultiLineString
+func parseGeoJSON(data []byte) (orb.LineString, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("GeoJSON 文件解析失败: %v", err)
+	}
+	var geometries []orb.Geometry
+	switch header.Type {
+	case "FeatureCollection":
+		fc, err := geojson.UnmarshalFeatureCollection(data)
+		if err != nil {
+			return nil, fmt.Errorf("GeoJSON 文件解析失败: %v", err)
+		}
+		for _, feature := range fc.Features {
+			geometries = append(geometries, feature.Geometry)
+		}
+	case "Feature":
+		feature, err := geojson.UnmarshalFeature(data)
+		if err != nil {
+			return nil, fmt.Errorf("GeoJSON 文件解析失败: %v", err)
+		}
+		geometries = append(geometries, feature.Geometry)
+	default:
+		geometry, err := geojson.UnmarshalGeometry(data)
+		if err != nil {
+			return nil, fmt.Errorf("GeoJSON 文件解析失败: %v", err)
+		}
+		geometries = append(geometries, geometry.Geometry())
+	}
+	var line orb.LineString
+	for _, geometry := range geometries {
+		switch g := geometry.(type) {
+		case orb.LineString:
+			line = append(line, g...)
+		case orb.MultiLineString:
+			for _, ls := range g {
+				line = append(line, ls...)
+			}
+		}
+	}
+	return line, nil
+}
+
+// SimplifyLineString 使用 Douglas-Peucker 算法简化线路。
+// tolerance 为容差（米），maxPoints 大于 0 时会逐步放大容差直到点数不超过上限。
+func SimplifyLineString(line orb.LineString, tolerance float64, maxPoints int) orb.LineString {
+	result := line
+	if tolerance > 0 {
+		result = simplify.DouglasPeucker(tolerance / metersPerDegree).LineString(line.Clone())
+	}
+	if maxPoints < 2 || len(result) <= maxPoints {
+		return result
+	}
+	// 二分查找满足点数上限的最小容差
+	low, high := tolerance/metersPerDegree, 1.0
+	best := simplify.DouglasPeucker(high).LineString(line.Clone())
+	for i := 0; i < 40; i++ {
+		mid := (low + high) / 2
+		candidate := simplify.DouglasPeucker(mid).LineString(line.Clone())
+		if len(candidate) <= maxPoints {
+			best, high = candidate, mid
+		} else {
+			low = mid
+		}
+	}
+	return best
+}
+
+// LineStringToGeoPoints 将 orb 线转换为线路点位
+func LineStringToGeoPoints(line orb.LineString) []common.GeoPoint {
+	points := make([]common.GeoPoint, 0, len(line))
+	for _, p := range line {
+		points = append(points, common.GeoPoint{Type: "Point", Coordinates: []float64{p.Lon(), p.Lat()}})
+	}
+	return points
+}
+
+// GeoPointsToLineString 将线路点位转换为 orb 线
+func GeoPointsToLineString(points []common.GeoPoint) orb.LineString {
+	line := make(orb.LineString, 0, len(points))
+	for _, p := range points {
+		if len(p.Coordinates) < 2 {
+			continue
+		}
+		line = append(line, orb.Point{p.Coordinates[0], p.Coordinates[1]})
+	}
+	return line
+}
+
+// EncodeLineString 将线路按格式编码为文件内容
+func EncodeLineString(format GeoFormat, name string, description string, line orb.LineString) ([]byte, error) {
+	switch format {
+	case GeoFormatGPX:
+		return encodeGPX(name, description, line), nil
+	case GeoFormatKML:
+		return encodeKML(name, description, line), nil
+	case GeoFormatGeoJSON:
+		feature := geojson.NewFeature(line)
+		feature.Properties["name"] = name
+		feature.Properties["description"] = description
+		fc := geojson.NewFeatureCollection()
+		fc.Append(feature)
+		return json.MarshalIndent(fc, "", "  ")
+	}
+	return nil, fmt.Errorf("不支持的文件格式: %s", format)
+}
+
+func encodeGPX(name string, description string, line orb.LineString) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<gpx version="1.1" creator="go_logistics" xmlns="http://www.topografix.com/GPX/1/1">` + "\n")
+	buf.WriteString("  <trk>\n")
+	buf.WriteString("    <name>" + escapeXML(name) + "</name>\n")
+	buf.WriteString("    <desc>" + escapeXML(description) + "</desc>\n")
+	buf.WriteString("    <trkseg>\n")
+	for _, p := range line {
+		buf.WriteString(fmt.Sprintf("      <trkpt lat=\"%s\" lon=\"%s\"></trkpt>\n", formatCoordinate(p.Lat()), formatCoordinate(p.Lon())))
+	}
+	buf.WriteString("    </trkseg>\n  </trk>\n</gpx>\n")
+	return buf.Bytes()
+}
+
+func encodeKML(name string, description string, line orb.LineString) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<kml xmlns="http://www.opengis.net/kml/2.2">` + "\n")
+	buf.WriteString("  <Document>\n    <Placemark>\n")
+	buf.WriteString("      <name>" + escapeXML(name) + "</name>\n")
+	buf.WriteString("      <description>" + escapeXML(description) + "</description>\n")
+	buf.WriteString("      <LineString>\n        <tessellate>1</tessellate>\n        <coordinates>\n")
+	for _, p := range line {
+		buf.WriteString("          " + formatCoordinate(p.Lon()) + "," + formatCoordinate(p.Lat()) + ",0\n")
+	}
+	buf.WriteString("        </coordinates>\n      </LineString>\n    </Placemark>\n  </Document>\n</kml>\n")
+	return buf.Bytes()
+}
+
+func escapeXML(text string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+func formatCoordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
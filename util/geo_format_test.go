@@ -0,0 +1,194 @@
+package util
+
+import (
+	"github.com/paulmach/orb"
+	"strings"
+	"testing"
+)
+
+// routeSample 测试用的线路，北京市内三个点
+var routeSample = orb.LineString{{116.397428, 39.90923}, {116.403963, 39.915119}, {116.410886, 39.881949}}
+
+func TestParseGeoFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    GeoFormat
+		wantErr bool
+	}{
+		{"gpx", GeoFormatGPX, false},
+		{".KML", GeoFormatKML, false},
+		{" GeoJSON ", GeoFormatGeoJSON, false},
+		{"json", GeoFormatGeoJSON, false},
+		{"shp", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGeoFormat(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseGeoFormat(%q) = %q, %v，期望 %q，出错 %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseLineString(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  GeoFormat
+		data    string
+		want    orb.LineString
+		wantErr bool
+	}{
+		{
+			name:   "GPX 多段轨迹首尾相接",
+			format: GeoFormatGPX,
+			data: `<gpx><trk><trkseg><trkpt lat="39.9" lon="116.3"/><trkpt lat="39.91" lon="116.31"/></trkseg>` +
+				`<trkseg><trkpt lat="39.92" lon="116.32"/></trkseg></trk></gpx>`,
+			want: orb.LineString{{116.3, 39.9}, {116.31, 39.91}, {116.32, 39.92}},
+		},
+		{
+			name:   "GPX 没有轨迹时使用路线",
+			format: GeoFormatGPX,
+			data:   `<gpx><rte><rtept lat="39.9" lon="116.3"/><rtept lat="39.91" lon="116.31"/></rte></gpx>`,
+			want:   orb.LineString{{116.3, 39.9}, {116.31, 39.91}},
+		},
+		{
+			name:   "GPX 轨迹优先于路线",
+			format: GeoFormatGPX,
+			data: `<gpx><trk><trkseg><trkpt lat="39.9" lon="116.3"/><trkpt lat="39.91" lon="116.31"/></trkseg></trk>` +
+				`<rte><rtept lat="30" lon="120"/><rtept lat="31" lon="121"/></rte></gpx>`,
+			want: orb.LineString{{116.3, 39.9}, {116.31, 39.91}},
+		},
+		{name: "GPX 格式错误", format: GeoFormatGPX, data: `<gpx><trk>`, wantErr: true},
+		{name: "GPX 只有一个点", format: GeoFormatGPX, data: `<gpx><trk><trkseg><trkpt lat="39.9" lon="116.3"/></trkseg></trk></gpx>`, wantErr: true},
+		{name: "GPX 空文件", format: GeoFormatGPX, data: ``, wantErr: true},
+		{
+			name:   "KML 多条线路并忽略海拔",
+			format: GeoFormatKML,
+			data: `<kml><Placemark><LineString><coordinates>116.3,39.9,0 116.31,39.91,10</coordinates></LineString></Placemark>` +
+				`<Placemark><LineString><coordinates>` + "\n\t116.32,39.92\n" + `</coordinates></LineString></Placemark></kml>`,
+			want: orb.LineString{{116.3, 39.9}, {116.31, 39.91}, {116.32, 39.92}},
+		},
+		{
+			name:   "KML 忽略线路之外的坐标",
+			format: GeoFormatKML,
+			data: `<kml><Placemark><Point><coordinates>120,30</coordinates></Point></Placemark>` +
+				`<Placemark><LineString><coordinates>116.3,39.9 116.31,39.91</coordinates></LineString></Placemark></kml>`,
+			want: orb.LineString{{116.3, 39.9}, {116.31, 39.91}},
+		},
+		{name: "KML 只有点", format: GeoFormatKML, data: `<kml><Point><coordinates>120,30</coordinates></Point></kml>`, wantErr: true},
+		{name: "KML 坐标缺少纬度", format: GeoFormatKML, data: `<kml><LineString><coordinates>116.3 116.31,39.91</coordinates></LineString></kml>`, wantErr: true},
+		{name: "KML 坐标不是数字", format: GeoFormatKML, data: `<kml><LineString><coordinates>116.3,abc 116.31,39.91</coordinates></LineString></kml>`, wantErr: true},
+		{name: "KML 标签未闭合", format: GeoFormatKML, data: `<kml><LineString><coordinates>116.3,39.9</LineString></kml>`, wantErr: true},
+		{
+			name:   "GeoJSON 要素集合中的 LineString 与 MultiLineString",
+			format: GeoFormatGeoJSON,
+			data: `{"type":"FeatureCollection","features":[` +
+				`{"type":"Feature","properties":{},"geometry":{"type":"LineString","coordinates":[[116.3,39.9],[116.31,39.91]]}},` +
+				`{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[120,30]}},` +
+				`{"type":"Feature","properties":{},"geometry":{"type":"MultiLineString","coordinates":[[[116.32,39.92]],[[116.33,39.93]]]}}]}`,
+			want: orb.LineString{{116.3, 39.9}, {116.31, 39.91}, {116.32, 39.92}, {116.33, 39.93}},
+		},
+		{
+			name:   "GeoJSON 单个要素",
+			format: GeoFormatGeoJSON,
+			data:   `{"type":"Feature","properties":{},"geometry":{"type":"LineString","coordinates":[[116.3,39.9],[116.31,39.91]]}}`,
+			want:   orb.LineString{{116.3, 39.9}, {116.31, 39.91}},
+		},
+		{
+			name:   "GeoJSON 裸几何对象",
+			format: GeoFormatGeoJSON,
+			data:   `{"type":"LineString","coordinates":[[116.3,39.9],[116.31,39.91]]}`,
+			want:   orb.LineString{{116.3, 39.9}, {116.31, 39.91}},
+		},
+		{name: "GeoJSON 不是 JSON", format: GeoFormatGeoJSON, data: `{"type":`, wantErr: true},
+		{name: "GeoJSON 只有点", format: GeoFormatGeoJSON, data: `{"type":"Point","coordinates":[116.3,39.9]}`, wantErr: true},
+		{name: "GeoJSON 空要素集合", format: GeoFormatGeoJSON, data: `{"type":"FeatureCollection","features":[]}`, wantErr: true},
+		{name: "GeoJSON 几何类型未知", format: GeoFormatGeoJSON, data: `{"type":"Curve","coordinates":[]}`, wantErr: true},
+		{name: "不支持的格式", format: GeoFormat("shp"), data: `{}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLineString(tt.format, []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLineString() 出错 %v，期望出错 %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseLineString() = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeLineString(t *testing.T) {
+	const name, description = `A&B <干线>`, `"测试" 线路`
+	for _, format := range []GeoFormat{GeoFormatGPX, GeoFormatKML, GeoFormatGeoJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			data, err := EncodeLineString(format, name, description, routeSample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != GeoFormatGeoJSON && !strings.Contains(string(data), "A&amp;B &lt;干线&gt;") {
+				t.Errorf("名称未转义: %s", data)
+			}
+			line, err := ParseLineString(format, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !line.Equal(routeSample) {
+				t.Errorf("编码后再解析为 %v，期望 %v", line, routeSample)
+			}
+		})
+	}
+	if _, err := EncodeLineString(GeoFormat("shp"), name, description, routeSample); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
+
+func TestSimplifyLineString(t *testing.T) {
+	// 第二个点偏离直线约 5 米
+	offset := 5 / metersPerDegree
+	wiggle := orb.LineString{{116.30, 39.9}, {116.32, 39.9 + offset}, {116.34, 39.9}, {116.36, 39.9}}
+	// 锯齿线，每个点偏离直线约 1 公里
+	zigzag := make(orb.LineString, 0, 20)
+	for i := 0; i < 20; i++ {
+		lat := 39.9
+		if i%2 == 1 {
+			lat += 0.01
+		}
+		zigzag = append(zigzag, orb.Point{116.3 + 0.01*float64(i), lat})
+	}
+	tests := []struct {
+		name      string
+		line      orb.LineString
+		tolerance float64
+		maxPoints int
+		wantLen   int // 为 0 时只校验不超过点数上限
+	}{
+		{name: "容差为 0 不简化", line: wiggle, wantLen: 4},
+		{name: "容差大于偏离距离时去掉偏离点", line: wiggle, tolerance: 10, wantLen: 2},
+		{name: "容差小于偏离距离时保留偏离点", line: wiggle, tolerance: 1, wantLen: 4},
+		{name: "容差小于锯齿幅度时全部保留", line: zigzag, tolerance: 100, wantLen: 20},
+		{name: "点数上限逐步放大容差", line: zigzag, tolerance: 100, maxPoints: 5},
+		{name: "点数上限小于 2 时不限制", line: zigzag, tolerance: 100, maxPoints: 1, wantLen: 20},
+		{name: "未超过点数上限时不再简化", line: zigzag, maxPoints: 20, wantLen: 20},
+		{name: "两个点的线路", line: orb.LineString{{116.3, 39.9}, {116.4, 39.9}}, tolerance: 1000, wantLen: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.line.Clone()
+			got := SimplifyLineString(tt.line, tt.tolerance, tt.maxPoints)
+			if tt.wantLen > 0 && len(got) != tt.wantLen {
+				t.Errorf("简化后 %d 个点，期望 %d", len(got), tt.wantLen)
+			}
+			if len(got) < 2 || (tt.maxPoints >= 2 && len(got) > tt.maxPoints) {
+				t.Errorf("简化后 %d 个点，超出点数上限 %d", len(got), tt.maxPoints)
+			}
+			if len(got) >= 2 && (got[0] != tt.line[0] || got[len(got)-1] != tt.line[len(tt.line)-1]) {
+				t.Errorf("简化后首尾点 %v、%v 与原线路不同", got[0], got[len(got)-1])
+			}
+			if !tt.line.Equal(original) {
+				t.Error("简化不应修改入参")
+			}
+		})
+	}
+}
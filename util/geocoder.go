@@ -0,0 +1,204 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_logistics/common"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BaiduGeocodingUrl        = "https://api.map.baidu.com/geocoding/v3/"
+	BaiduReverseGeocodingUrl = "https://api.map.baidu.com/reverse_geocoding/v3/"
+	// geocoderTimeout 调用地图服务的超时时间
+	geocoderTimeout = 5 * time.Second
+)
+
+// ErrAddressNotFound 地址无法解析或坐标附近没有可用地址
+var ErrAddressNotFound = errors.New("地址解析失败")
+
+// GeocodeResult 地址解析与逆地址解析的结果
+type GeocodeResult struct {
+	common.LngLat
+	FormattedAddress string `json:"formattedAddress"`
+	Province         string `json:"province"`
+	City             string `json:"city"`
+	District         string `json:"district"`
+	Confidence       int    `json:"confidence"` // 可信度 0-100
+	Provider         string `json:"provider"`
+	CRS              CRS    `json:"crs"` // 坐标所在的坐标系
+}
+
+// ConvertCRS 将结果坐标转换到目标坐标系
+func (r *GeocodeResult) ConvertCRS(to CRS) {
+	r.LngLat = ConvertLngLat(r.LngLat, r.CRS, to)
+	r.CRS = to
+}
+
+// Geocoder 地址解析服务，city 用于限定解析范围，可为空；
+// 逆地址解析的入参坐标为存储坐标系，结果的坐标系由 GeocodeResult.CRS 标注
+type Geocoder interface {
+	Geocode(ctx context.Context, address string, city string) (*GeocodeResult, error)
+	ReverseGeocode(ctx context.Context, position common.LngLat) (*GeocodeResult, error)
+}
+
+// BaiduGeocoder 百度地图 Web 服务 API 的地址解析，返回 BD-09 坐标
+type BaiduGeocoder struct {
+	ak     string
+	client *http.Client
+}
+
+// NewBaiduGeocoder 使用百度地图服务端 AK 创建地址解析服务
+func NewBaiduGeocoder(ak string) *BaiduGeocoder {
+	return &BaiduGeocoder{
+		ak:     ak,
+		client: &http.Client{Timeout: geocoderTimeout},
+	}
+}
+
+type baiduGeocodingResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Result  struct {
+		Location   common.LngLat `json:"location"`
+		Precise    int           `json:"precise"`
+		Confidence int           `json:"confidence"`
+		Level      string        `json:"level"`
+	} `json:"result"`
+}
+
+type baiduReverseGeocodingResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Result  struct {
+		Location         common.LngLat `json:"location"`
+		FormattedAddress string        `json:"formatted_address"`
+		AddressComponent struct {
+			Province string `json:"province"`
+			City     string `json:"city"`
+			District string `json:"district"`
+		} `json:"addressComponent"`
+	} `json:"result"`
+}
+
+// Geocode 地址解析
+func (g *BaiduGeocoder) Geocode(ctx context.Context, address string, city string) (*GeocodeResult, error) {
+	params := url.Values{}
+	params.Set("address", address)
+	if city != "" {
+		params.Set("city", city)
+	}
+	var response baiduGeocodingResponse
+	if err := g.get(ctx, BaiduGeocodingUrl, params, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != 0 {
+		// 1 服务内部错误以外的状态都表示地址无法解析或请求参数有误
+		if response.Status == 1 {
+			return nil, fmt.Errorf("百度地址解析失败: %s", response.Message)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrAddressNotFound, response.Message)
+	}
+	return &GeocodeResult{
+		LngLat:           response.Result.Location,
+		FormattedAddress: address,
+		City:             city,
+		Confidence:       response.Result.Confidence,
+		Provider:         "baidu",
+		CRS:              CRSBD09,
+	}, nil
+}
+
+// ReverseGeocode 逆地址解析
+func (g *BaiduGeocoder) ReverseGeocode(ctx context.Context, position common.LngLat) (*GeocodeResult, error) {
+	params := url.Values{}
+	params.Set("location", strconv.FormatFloat(position.Lat, 'f', -1, 64)+","+strconv.FormatFloat(position.Lng, 'f', -1, 64))
+	params.Set("coordtype", "wgs84ll")
+	var response baiduReverseGeocodingResponse
+	if err := g.get(ctx, BaiduReverseGeocodingUrl, params, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != 0 {
+		return nil, fmt.Errorf("百度逆地址解析失败: %s", response.Message)
+	}
+	if response.Result.FormattedAddress == "" {
+		return nil, ErrAddressNotFound
+	}
+	component := response.Result.AddressComponent
+	return &GeocodeResult{
+		LngLat:           position,
+		FormattedAddress: response.Result.FormattedAddress,
+		Province:         component.Province,
+		City:             component.City,
+		District:         component.District,
+		Confidence:       100,
+		Provider:         "baidu",
+		CRS:              StorageCRS,
+	}, nil
+}
+
+// get 调用百度地图接口并解析 JSON 响应
+func (g *BaiduGeocoder) get(ctx context.Context, baseUrl string, params url.Values, result any) error {
+	params.Set("output", "json")
+	params.Set("ak", g.ak)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, baseUrl+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	response, err := g.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("请求百度地图服务失败: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求百度地图服务失败: HTTP %d", response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// NormalizeAddress 统一地址写法：去掉空白并将全角数字、字母转为半角，便于比较
+func NormalizeAddress(address string) string {
+	var builder strings.Builder
+	for _, r := range address {
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '　':
+			continue
+		case r >= '０' && r <= '９', r >= 'Ａ' && r <= 'Ｚ', r >= 'ａ' && r <= 'ｚ':
+			builder.WriteRune(r - 0xFEE0)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// LongestCommonSubstring 返回两个字符串最长公共子串的长度（按字符计）
+func LongestCommonSubstring(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	longest := 0
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			if ra[i-1] == rb[j-1] {
+				current[j] = previous[j-1] + 1
+				if current[j] > longest {
+					longest = current[j]
+				}
+			} else {
+				current[j] = 0
+			}
+		}
+		previous, current = current, previous
+	}
+	return longest
+}
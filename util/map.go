@@ -6,7 +6,6 @@ import (
 	"github.com/paulmach/orb/planar"
 	"go_logistics/common"
 	"math"
-	"strconv"
 )
 
 const earthRadiusKm = 6371.0
@@ -16,26 +15,9 @@ func haversin(theta float64) float64 {
 	return math.Pow(math.Sin(theta/2), 2)
 }
 
-// GetDistanceFromString 接收经纬度字符串并计算球面距离（单位：公里）
-func GetDistanceFromString(lat1Str, lng1Str, lat2Str, lng2Str string) (float64, error) {
-	lat1, err := strconv.ParseFloat(lat1Str, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid lat1: %v", err)
-	}
-	lng1, err := strconv.ParseFloat(lng1Str, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid lng1: %v", err)
-	}
-	lat2, err := strconv.ParseFloat(lat2Str, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid lat2: %v", err)
-	}
-	lng2, err := strconv.ParseFloat(lng2Str, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid lng2: %v", err)
-	}
-
-	return GetDistance(lat1, lng1, lat2, lng2), nil
+// GetLngLatDistance 计算两个坐标点之间的球面距离（单位：公里）
+func GetLngLatDistance(a common.LngLat, b common.LngLat) float64 {
+	return GetDistance(a.Lat, a.Lng, b.Lat, b.Lng)
 }
 
 // GetDistance 计算两个经纬度点之间的球面距离（单位：公里）
@@ -56,18 +38,15 @@ func GetDistance(lat1, lng1, lat2, lng2 float64) float64 {
 	return earthRadiusKm * c
 }
 
-// IsPointInGeoPointSlice 查询当前点位是否在范围中
-func IsPointInGeoPointSlice(targetLngStr, targetLatStr string, points []common.GeoPoint) (bool, error) {
-	targetLat, err := strconv.ParseFloat(targetLatStr, 64)
-	if err != nil {
-		return false, fmt.Errorf("invalid lat1: %v", err)
-	}
-	targetLng, err := strconv.ParseFloat(targetLngStr, 64)
-	if err != nil {
-		return false, fmt.Errorf("invalid lng1: %v", err)
-	}
+// IsLngLatInScope 判断坐标点是否在多边形范围内
+func IsLngLatInScope(target common.LngLat, points []common.GeoPoint) bool {
+	return IsPointInScope(target.Lng, target.Lat, points)
+}
+
+// IsPointInScope 判断经纬度点是否在多边形范围内
+func IsPointInScope(targetLng, targetLat float64, points []common.GeoPoint) bool {
 	if len(points) < 3 {
-		return false, nil // 至少需要三个点才能构成面
+		return false // 至少需要三个点才能构成面
 	}
 
 	// 构造 orb.Ring
@@ -80,5 +59,66 @@ func IsPointInGeoPointSlice(targetLngStr, targetLatStr string, points []common.G
 	poly := orb.Polygon{ring}
 	point := orb.Point{targetLng, targetLat}
 
-	return planar.PolygonContains(poly, point), nil
+	return planar.PolygonContains(poly, point)
+}
+
+// ValidateGeoPoints 校验坐标点是否为合法的经纬度（[lng, lat]，且不能为原点）
+func ValidateGeoPoints(points []common.GeoPoint) error {
+	for i, p := range points {
+		if len(p.Coordinates) != 2 {
+			return fmt.Errorf("第%d个点坐标格式错误", i+1)
+		}
+		lng, lat := p.Coordinates[0], p.Coordinates[1]
+		if math.IsNaN(lng) || math.IsNaN(lat) || math.IsInf(lng, 0) || math.IsInf(lat, 0) {
+			return fmt.Errorf("第%d个点坐标不是有效数字", i+1)
+		}
+		if lng < -180 || lng > 180 || lat < -90 || lat > 90 {
+			return fmt.Errorf("第%d个点经纬度超出范围", i+1)
+		}
+		if lng == 0 && lat == 0 {
+			return fmt.Errorf("第%d个点坐标为空", i+1)
+		}
+	}
+	return nil
+}
+
+// GetPolylineDistance 计算折线各段球面距离之和（单位：公里）
+func GetPolylineDistance(points []common.GeoPoint) float64 {
+	var total float64
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1], points[i]
+		total += GetDistance(prev.Coordinates[1], prev.Coordinates[0], cur.Coordinates[1], cur.Coordinates[0])
+	}
+	return total
+}
+
+// NewGeoPoint 将经纬度转换为 GeoJSON 点，坐标不能为空
+func NewGeoPoint(position common.LngLat) (common.GeoPoint, error) {
+	point := position.GeoPoint()
+	if err := ValidateGeoPoints([]common.GeoPoint{point}); err != nil {
+		return common.GeoPoint{}, err
+	}
+	return point, nil
+}
+
+// ScopeToPolygon 将范围点位转换为闭合的 GeoJSON 多边形
+func ScopeToPolygon(points []common.GeoPoint) (*common.GeoPolygon, error) {
+	if len(points) < 3 {
+		return nil, fmt.Errorf("范围至少需要三个点")
+	}
+	if err := ValidateGeoPoints(points); err != nil {
+		return nil, err
+	}
+	ring := make([][]float64, 0, len(points)+1)
+	for _, p := range points {
+		ring = append(ring, []float64{p.Coordinates[0], p.Coordinates[1]})
+	}
+	first, last := ring[0], ring[len(ring)-1]
+	if first[0] != last[0] || first[1] != last[1] {
+		ring = append(ring, []float64{first[0], first[1]})
+	}
+	if len(ring) < 4 {
+		return nil, fmt.Errorf("范围至少需要三个不同的点")
+	}
+	return &common.GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{ring}}, nil
 }
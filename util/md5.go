@@ -4,7 +4,6 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
-	"math/rand"
 	"strings"
 	"time"
 )
@@ -39,35 +38,7 @@ func MD5EncodeUpper(str string) string {
 	return strings.ToUpper(MD5Encode(str))
 }
 
-// MakePassword 对给定的密码和盐值进行散列处理，生成安全的密码字符串。
-// 该函数接受两个参数：password（原始密码字符串）和salt（用于增加密码强度的盐值字符串）。
-// 返回值为经过MD5散列处理后的密码字符串。
-func MakePassword(password, salt string) string {
-	return MD5Encode(password + salt)
-}
-
-// ValidPassword 检查密码是否有效。
-// 该函数通过将密码与盐值拼接后进行MD5加密，并将加密结果与给定的加密密码进行比较来验证密码的有效性。
-// 参数:
-//
-//	password - 用户输入的原始密码。
-//	salt - 在原始密码上添加的盐值，用于增加密码安全性。
-//	encodedPassword - 已加密的密码，用于与计算后的加密密码进行比较。
-//
-// 返回值:
-//
-//	如果计算后的加密密码与给定的加密密码相同，则返回true，表示密码验证成功；否则返回false。
-func ValidPassword(password, salt, encodedPassword string) bool {
-	// 比较给定的加密密码与使用盐值对原始密码进行MD5加密后的结果是否一致
-	return encodedPassword == MD5Encode(password+salt)
-}
-
 // MakeToken 生成一个随机的令牌字符串。
 func MakeToken() string {
 	return MD5Encode(fmt.Sprintf("%d", time.Now().Unix()))
 }
-
-// MakeSalt 生成一个随机的盐值字符串。
-func MakeSalt() string {
-	return fmt.Sprintf("%06d", rand.Int31()%1000000)
-}
@@ -0,0 +1,147 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 当前使用的 argon2id 参数，调整后旧参数的哈希会在下次登录时自动重新计算
+const (
+	argon2Memory  uint32 = 64 * 1024 // 单位 KiB
+	argon2Time    uint32 = 3
+	argon2Threads uint8  = 2
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+)
+
+// argon2Prefix 版本化哈希的前缀，格式为 $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>，
+// 不以该前缀开头的视为旧版 MD5 哈希
+const argon2Prefix = "$argon2id$"
+
+// 密码策略的默认值，可通过环境变量 PASSWORD_MIN_LENGTH、PASSWORD_MIN_CLASSES 调整
+const (
+	defaultPasswordMinLength  = 8
+	defaultPasswordMinClasses = 3
+	passwordMaxLength         = 128
+)
+
+// PasswordPolicy 密码策略：最小长度与至少包含的字符种类数
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int
+}
+
+// passwordPolicy 当前使用的密码策略，启动时由 SetPasswordPolicy 设置
+var passwordPolicy = PasswordPolicy{MinLength: defaultPasswordMinLength, MinClasses: defaultPasswordMinClasses}
+
+// SetPasswordPolicy 按配置设置密码策略，minLength、minClasses 为空或不合法时使用默认值
+func SetPasswordPolicy(minLength string, minClasses string) {
+	passwordPolicy = PasswordPolicy{
+		MinLength:  envInt(minLength, defaultPasswordMinLength),
+		MinClasses: envInt(minClasses, defaultPasswordMinClasses),
+	}
+}
+
+// HashPassword 使用 argon2id 计算密码哈希，盐值随机生成并写入哈希串
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("生成盐值失败: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword 校验密码，legacySalt 仅用于旧版 MD5 哈希。
+// needsRehash 为 true 表示密码正确但哈希为旧版格式或参数已过时，应使用 HashPassword 重新计算
+func VerifyPassword(password string, legacySalt string, encoded string) (ok bool, needsRehash bool) {
+	if encoded == "" {
+		return false, false
+	}
+	if !strings.HasPrefix(encoded, argon2Prefix) {
+		ok = subtle.ConstantTimeCompare([]byte(encoded), []byte(MD5Encode(password+legacySalt))) == 1
+		return ok, ok
+	}
+	// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash> 按 $ 分割后为 6 段
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+	var (
+		version            int
+		memory, iterations uint32
+		threads            uint8
+	)
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, false
+	}
+	salt, saltErr := base64.RawStdEncoding.DecodeString(parts[4])
+	key, keyErr := base64.RawStdEncoding.DecodeString(parts[5])
+	if saltErr != nil || keyErr != nil || len(key) == 0 {
+		return false, false
+	}
+	computed := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, computed) != 1 {
+		return false, false
+	}
+	outdated := memory != argon2Memory || iterations != argon2Time || threads != argon2Threads || uint32(len(key)) != argon2KeyLen
+	return true, outdated
+}
+
+// CheckPasswordPolicy 检查密码是否满足密码策略：长度、字符种类（小写、大写、数字、符号）且不包含用户名
+func CheckPasswordPolicy(password string, name string) error {
+	minLength, minClasses := passwordPolicy.MinLength, passwordPolicy.MinClasses
+	length := len([]rune(password))
+	if length < minLength {
+		return fmt.Errorf("密码长度不能少于 %d 位", minLength)
+	}
+	if length > passwordMaxLength {
+		return fmt.Errorf("密码长度不能超过 %d 位", passwordMaxLength)
+	}
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsSpace(r):
+			return fmt.Errorf("密码不能包含空白字符")
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, symbol} {
+		if has {
+			classes++
+		}
+	}
+	if classes < minClasses {
+		return fmt.Errorf("密码需包含小写字母、大写字母、数字、符号中的至少 %d 种", minClasses)
+	}
+	if name != "" && strings.Contains(strings.ToLower(password), strings.ToLower(name)) {
+		return fmt.Errorf("密码不能包含用户名")
+	}
+	return nil
+}
+
+// envInt 解析整数配置，为空或不合法时使用默认值
+func envInt(value string, defaultValue int) int {
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		return n
+	}
+	return defaultValue
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+	"testing"
+)
+
+// encodeArgon2 按指定参数计算 argon2id 哈希串，用于构造参数过时的哈希
+func encodeArgon2(password string, salt []byte, memory uint32, iterations uint32, threads uint8) string {
+	key := argon2.IDKey([]byte(password), salt, iterations, memory, threads, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, memory, iterations, threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestHashPassword(t *testing.T) {
+	first, err := HashPassword("Logistics#2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := HashPassword("Logistics#2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(first, argon2Prefix) {
+		t.Errorf("哈希 %s 缺少 argon2id 前缀", first)
+	}
+	if first == second {
+		t.Error("相同密码两次哈希的盐值应不同")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	const password = "Logistics#2024"
+	current, err := HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("0123456789abcdef")
+	outdated := encodeArgon2(password, salt, 32*1024, 2, 1)
+	malformedKey := strings.Join(strings.Split(current, "$")[:5], "$") + "$!"
+	tests := []struct {
+		name        string
+		password    string
+		legacySalt  string
+		encoded     string
+		ok          bool
+		needsRehash bool
+	}{
+		{name: "当前参数", password: password, encoded: current, ok: true},
+		{name: "密码错误", password: "logistics#2024", encoded: current},
+		{name: "旧参数需要重新哈希", password: password, encoded: outdated, ok: true, needsRehash: true},
+		{name: "旧参数密码错误", password: "wrong", encoded: outdated},
+		{name: "旧版 MD5 需要重新哈希", password: password, legacySalt: "salt", encoded: MD5Encode(password + "salt"), ok: true, needsRehash: true},
+		{name: "旧版 MD5 盐值不符", password: password, legacySalt: "other", encoded: MD5Encode(password + "salt")},
+		{name: "空哈希", password: "", encoded: ""},
+		{name: "段数不符", password: password, encoded: argon2Prefix + "v=19$m=65536,t=3,p=2$abc"},
+		{name: "版本不符", password: password, encoded: strings.Replace(current, fmt.Sprintf("v=%d", argon2.Version), "v=16", 1)},
+		{name: "哈希无法解码", password: password, encoded: malformedKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash := VerifyPassword(tt.password, tt.legacySalt, tt.encoded)
+			if ok != tt.ok || needsRehash != tt.needsRehash {
+				t.Errorf("VerifyPassword() = %v, %v，期望 %v, %v", ok, needsRehash, tt.ok, tt.needsRehash)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	policy := passwordPolicy
+	t.Cleanup(func() {
+		passwordPolicy = policy
+	})
+	tests := []struct {
+		name       string
+		minLength  string
+		minClasses string
+		password   string
+		user       string
+		wantErr    bool
+	}{
+		{name: "满足默认策略", password: "Logistics#2024", user: "admin"},
+		{name: "长度不足", password: "Ab#1", wantErr: true},
+		{name: "超过最大长度", password: "Aa1#" + strings.Repeat("a", passwordMaxLength), wantErr: true},
+		{name: "字符种类不足", password: "logistics2024", wantErr: true},
+		{name: "包含空白字符", password: "Logistics 2024", wantErr: true},
+		{name: "包含用户名（忽略大小写）", password: "Admin#2024xy", user: "admin", wantErr: true},
+		{name: "长度按字符计算", password: "物流Ab1#物流", user: "admin"},
+		{name: "配置放宽字符种类", minClasses: "1", password: "logistics2024"},
+		{name: "配置提高长度", minLength: "16", password: "Logistics#2024", wantErr: true},
+		{name: "非法配置使用默认值", minLength: "abc", minClasses: "-1", password: "logistics2024", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPasswordPolicy(tt.minLength, tt.minClasses)
+			err := CheckPasswordPolicy(tt.password, tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckPasswordPolicy(%q, %q) = %v，期望出错 %v", tt.password, tt.user, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+const (
+	pdfPageWidth  = 595.0 // A4 宽度，单位 pt
+	pdfPageHeight = 842.0 // A4 高度，单位 pt
+	pdfMargin     = 50.0
+)
+
+// SimplePDF 简单的纯文本 PDF 生成器。
+// 使用 Adobe 预置的 STSong-Light 中文字体（UniGB-UCS2-H 编码），无需嵌入字体文件即可显示中文。
+type SimplePDF struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// NewSimplePDF 创建 PDF 文档并添加第一页
+func NewSimplePDF() *SimplePDF {
+	p := &SimplePDF{}
+	p.AddPage()
+	return p
+}
+
+// AddPage 新增一页
+func (p *SimplePDF) AddPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// AddLine 在当前页追加一行文本，空间不足时自动换页
+func (p *SimplePDF) AddLine(text string, fontSize float64) {
+	lineHeight := fontSize * 1.5
+	if p.y-lineHeight < pdfMargin {
+		p.AddPage()
+	}
+	p.y -= lineHeight
+	page := p.pages[len(p.pages)-1]
+	_, _ = fmt.Fprintf(page, "BT /F1 %.1f Tf %.1f %.1f Td <%s> Tj ET\n", fontSize, pdfMargin, p.y, encodePDFText(text))
+}
+
+// AddSpace 在当前页追加空白
+func (p *SimplePDF) AddSpace(height float64) {
+	p.y -= height
+}
+
+// Bytes 输出完整的 PDF 文件内容
+func (p *SimplePDF) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	writeObject := func(body string) {
+		offsets = append(offsets, buf.Len())
+		_, _ = fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	// 对象编号：1 目录，2 页面树，3 字体，之后每页占用页面与内容两个对象
+	pageCount := len(p.pages)
+	kids := &bytes.Buffer{}
+	for i := 0; i < pageCount; i++ {
+		_, _ = fmt.Fprintf(kids, "%d 0 R ", 4+i*2)
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), pageCount))
+	writeObject("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H " +
+		"/DescendantFonts [<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> " +
+		"/FontDescriptor << /Type /FontDescriptor /FontName /STSong-Light /Flags 6 " +
+		"/FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >> " +
+		"/DW 1000 /W [1 95 500] >>] >>")
+	for i, page := range p.pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 5+i*2))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xrefOffset := buf.Len()
+	_, _ = fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		_, _ = fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	_, _ = fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+	return buf.Bytes()
+}
+
+// encodePDFText 将文本编码为 UCS-2 大端序的十六进制字符串，超出基本平面的字符替换为问号
+func encodePDFText(text string) string {
+	var buf bytes.Buffer
+	for _, r := range text {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		_, _ = fmt.Fprintf(&buf, "%04X", r)
+	}
+	return buf.String()
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Access 角色对接口分组的访问级别
+type Access int
+
+const (
+	AccessNone  Access = 0 // 不可访问
+	AccessRead  Access = 1 // 只读
+	AccessWrite Access = 2 // 读写
+)
+
+func (a Access) String() string {
+	textMap := map[Access]string{
+		AccessNone:  "无权限",
+		AccessRead:  "只读",
+		AccessWrite: "读写",
+	}
+	return textMap[a]
+}
+
+// PermissionMatrix 各接口分组的权限矩阵，分组为 /api/ 之后的路径前缀，按最长前缀匹配；
+// 未列出的角色不可访问该分组
+var PermissionMatrix = map[string]map[Role]Access{
+	"user":             {RoleAdmin: AccessWrite},
+	"user/loginStatus": {RoleAdmin: AccessRead, RoleDispatcher: AccessRead, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+	"user/password":    {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessWrite, RoleAnalyst: AccessWrite},
+	"user/logout":      {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessWrite, RoleAnalyst: AccessWrite},
+	"user/logoutAll":   {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessWrite, RoleAnalyst: AccessWrite},
+	"role":             {RoleAdmin: AccessWrite},
+	"order":            {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessWrite, RoleAnalyst: AccessRead},
+	"outlet":           {RoleAdmin: AccessWrite, RoleDispatcher: AccessRead, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+	"outlet/inventory": {RoleAdmin: AccessWrite, RoleDispatcher: AccessRead, RoleOutletOperator: AccessWrite, RoleAnalyst: AccessRead},
+	"route":            {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+	"vehicle":          {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+	"delivery":         {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessWrite, RoleAnalyst: AccessRead},
+	"trip":             {RoleAdmin: AccessRead, RoleDispatcher: AccessRead, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+	"driver":           {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+	"driverApp":        {RoleDriver: AccessWrite},
+	"geo":              {RoleAdmin: AccessWrite, RoleDispatcher: AccessRead, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+	"home":             {RoleAdmin: AccessRead, RoleDispatcher: AccessRead, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+	"generate":         {RoleAdmin: AccessWrite},
+	"llm":              {RoleAdmin: AccessWrite, RoleDispatcher: AccessWrite, RoleOutletOperator: AccessWrite, RoleAnalyst: AccessWrite},
+	"file":             {RoleAdmin: AccessWrite, RoleDispatcher: AccessRead, RoleOutletOperator: AccessRead, RoleAnalyst: AccessRead},
+}
+
+// queryPostRoutes 以 POST 提交查询条件的只读接口，其余 POST、PUT、DELETE 接口均需读写权限
+var queryPostRoutes = map[string]bool{
+	"/api/user/list":                   true,
+	"/api/user/total":                  true,
+	"/api/user/loginAudit/list":        true,
+	"/api/user/loginAudit/total":       true,
+	"/api/order/list":                  true,
+	"/api/order/total":                 true,
+	"/api/outlet/list":                 true,
+	"/api/outlet/total":                true,
+	"/api/outlet/inventory/alert/list": true,
+	"/api/outlet/holiday/list":         true,
+	"/api/route/list":                  true,
+	"/api/route/total":                 true,
+	"/api/vehicle/list":                true,
+	"/api/vehicle/total":               true,
+	"/api/trip/list":                   true,
+	"/api/trip/total":                  true,
+	"/api/driver/list":                 true,
+	"/api/driver/total":                true,
+	"/api/driver/assignments":          true,
+	"/api/driver/events":               true,
+	"/api/home/fleet":                  true,
+	"/api/file/list":                   true,
+}
+
+// mutatingGetRoutes 使用 GET 但会修改数据的接口
+var mutatingGetRoutes = map[string]bool{
+	"/api/vehicle/complete":  true,
+	"/api/delivery/complete": true,
+	"/api/generate/vehicle":  true,
+}
+
+// RequiredAccess 接口需要的访问级别，path 为注册的路由路径
+func RequiredAccess(method string, path string) Access {
+	if method == http.MethodGet || method == http.MethodHead {
+		if mutatingGetRoutes[path] {
+			return AccessWrite
+		}
+		return AccessRead
+	}
+	if method == http.MethodPost && queryPostRoutes[path] {
+		return AccessRead
+	}
+	return AccessWrite
+}
+
+// RouteGroup 返回路由路径所属的权限分组，没有匹配的分组时返回空字符串
+func RouteGroup(path string) string {
+	path = strings.TrimPrefix(path, "/api/")
+	group := ""
+	for prefix := range PermissionMatrix {
+		if (path == prefix || strings.HasPrefix(path, prefix+"/")) && len(prefix) > len(group) {
+			group = prefix
+		}
+	}
+	return group
+}
+
+// HasPermission 判断角色能否以 method 访问 path，没有所属分组的接口只允许管理员访问
+func HasPermission(role Role, method string, path string) bool {
+	group := RouteGroup(path)
+	if group == "" {
+		return role == RoleAdmin
+	}
+	return PermissionMatrix[group][role] >= RequiredAccess(method, path)
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouteGroup(t *testing.T) {
+	tests := []struct {
+		path  string
+		group string
+	}{
+		{"/api/order/list", "order"},
+		{"/api/outlet/create", "outlet"},
+		{"/api/outlet/inventory/alert/list", "outlet/inventory"},
+		{"/api/user/loginStatus", "user/loginStatus"},
+		{"/api/user/list", "user"},
+		{"/api/user/passwordReset", "user"},
+		{"/api/driverApp/orders", "driverApp"},
+		{"/api/driver/list", "driver"},
+		{"/api/order", "order"},
+		{"/api/orders/list", ""},
+		{"/api/unknown/list", ""},
+	}
+	for _, tt := range tests {
+		if group := RouteGroup(tt.path); group != tt.group {
+			t.Errorf("RouteGroup(%q) = %q，期望 %q", tt.path, group, tt.group)
+		}
+	}
+}
+
+func TestRequiredAccess(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		access Access
+	}{
+		{http.MethodGet, "/api/order/detail", AccessRead},
+		{http.MethodHead, "/api/order/detail", AccessRead},
+		{http.MethodGet, "/api/vehicle/complete", AccessWrite},
+		{http.MethodGet, "/api/delivery/complete", AccessWrite},
+		{http.MethodPost, "/api/order/list", AccessRead},
+		{http.MethodPost, "/api/order/create", AccessWrite},
+		{http.MethodPut, "/api/order/list", AccessWrite},
+		{http.MethodDelete, "/api/order/delete", AccessWrite},
+	}
+	for _, tt := range tests {
+		if access := RequiredAccess(tt.method, tt.path); access != tt.access {
+			t.Errorf("RequiredAccess(%s, %q) = %s，期望 %s", tt.method, tt.path, access, tt.access)
+		}
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   Role
+		method string
+		path   string
+		want   bool
+	}{
+		{"管理员可管理用户", RoleAdmin, http.MethodPost, "/api/user/create", true},
+		{"调度员不能管理用户", RoleDispatcher, http.MethodPost, "/api/user/create", false},
+		{"调度员不能查询用户列表", RoleDispatcher, http.MethodPost, "/api/user/list", false},
+		{"分析员可查询登录状态", RoleAnalyst, http.MethodGet, "/api/user/loginStatus", true},
+		{"分析员可修改自己的密码", RoleAnalyst, http.MethodPost, "/api/user/password", true},
+		{"分析员可查询订单", RoleAnalyst, http.MethodPost, "/api/order/list", true},
+		{"分析员不能创建订单", RoleAnalyst, http.MethodPost, "/api/order/create", false},
+		{"网点操作员可创建订单", RoleOutletOperator, http.MethodPost, "/api/order/create", true},
+		{"网点操作员不能修改网点", RoleOutletOperator, http.MethodPost, "/api/outlet/update", false},
+		{"网点操作员可入库扫描", RoleOutletOperator, http.MethodPost, "/api/outlet/inventory/inbound", true},
+		{"调度员只读库存", RoleDispatcher, http.MethodPost, "/api/outlet/inventory/inbound", false},
+		{"调度员可查询库存预警", RoleDispatcher, http.MethodPost, "/api/outlet/inventory/alert/list", true},
+		{"分析员不能完成运输", RoleAnalyst, http.MethodGet, "/api/vehicle/complete", false},
+		{"调度员可完成运输", RoleDispatcher, http.MethodGet, "/api/vehicle/complete", true},
+		{"管理员只读趟次", RoleAdmin, http.MethodPost, "/api/trip/update", false},
+		{"司机可访问司机端", RoleDriver, http.MethodPost, "/api/driverApp/delivery/confirm", true},
+		{"司机不能访问后台", RoleDriver, http.MethodPost, "/api/order/list", false},
+		{"管理员不能访问司机端", RoleAdmin, http.MethodGet, "/api/driverApp/orders", false},
+		{"未分组接口仅管理员可访问", RoleAdmin, http.MethodGet, "/api/unknown/list", true},
+		{"未分组接口其他角色不可访问", RoleDispatcher, http.MethodGet, "/api/unknown/list", false},
+		{"未知角色不可访问", Role("guest"), http.MethodGet, "/api/order/detail", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasPermission(tt.role, tt.method, tt.path); got != tt.want {
+				t.Errorf("HasPermission(%s, %s, %q) = %v，期望 %v", tt.role, tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
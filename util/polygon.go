@@ -0,0 +1,187 @@
+package util
+
+import (
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/planar"
+	"go_logistics/common"
+	"math"
+)
+
+// MinScopeArea 营业范围的最小面积，单位平方米
+const MinScopeArea = 10000.0
+
+// ScopeToOrbPolygon 将范围点位转换为闭合的 orb 多边形
+func ScopeToOrbPolygon(points []common.GeoPoint) orb.Polygon {
+	ring := make(orb.Ring, 0, len(points)+1)
+	for _, p := range points {
+		if len(p.Coordinates) < 2 {
+			continue
+		}
+		ring = append(ring, orb.Point{p.Coordinates[0], p.Coordinates[1]})
+	}
+	if len(ring) > 0 && !ring.Closed() {
+		ring = append(ring, ring[0])
+	}
+	return orb.Polygon{ring}
+}
+
+// ValidateScopePolygon 校验营业范围多边形：坐标合法、至少三个不同的点、无重复点与自相交，且面积不小于下限。
+// 首尾点不同的范围按自动补上首点闭合后校验。
+func ValidateScopePolygon(points []common.GeoPoint) error {
+	if err := ValidateGeoPoints(points); err != nil {
+		return err
+	}
+	polygon := ScopeToOrbPolygon(points)
+	ring := polygon[0]
+	distinct := make(map[orb.Point]bool)
+	for _, p := range ring {
+		distinct[p] = true
+	}
+	if len(distinct) < 3 {
+		return fmt.Errorf("营业范围至少需要三个不同的点")
+	}
+	for i := 0; i < len(ring)-1; i++ {
+		if ring[i] == ring[i+1] {
+			return fmt.Errorf("营业范围第%d个点与下一个点重复", i+1)
+		}
+	}
+	if i, j, ok := findSelfIntersection(ring); ok {
+		return fmt.Errorf("营业范围第%d条边与第%d条边相交", i+1, j+1)
+	}
+	if area := geo.Area(polygon); area < MinScopeArea {
+		return fmt.Errorf("营业范围面积%.0f平方米，小于%.0f平方米", area, MinScopeArea)
+	}
+	return nil
+}
+
+// findSelfIntersection 查找闭合环中不相邻且相交的两条边
+func findSelfIntersection(ring orb.Ring) (int, int, bool) {
+	edges := len(ring) - 1
+	for i := 0; i < edges; i++ {
+		for j := i + 1; j < edges; j++ {
+			// 相邻边共享端点，不算相交
+			if j == i+1 || (i == 0 && j == edges-1) {
+				continue
+			}
+			if segmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return i, j, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func orientation(a, b, c orb.Point) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+func onSegment(a, b, p orb.Point) bool {
+	return math.Min(a[0], b[0]) <= p[0] && p[0] <= math.Max(a[0], b[0]) &&
+		math.Min(a[1], b[1]) <= p[1] && p[1] <= math.Max(a[1], b[1])
+}
+
+// segmentsIntersect 判断线段 p1p2 与 p3p4 是否相交（含端点接触与共线重叠）
+func segmentsIntersect(p1, p2, p3, p4 orb.Point) bool {
+	d1 := orientation(p3, p4, p1)
+	d2 := orientation(p3, p4, p2)
+	d3 := orientation(p1, p2, p3)
+	d4 := orientation(p1, p2, p4)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(p3, p4, p1)) || (d2 == 0 && onSegment(p3, p4, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, p3)) || (d4 == 0 && onSegment(p1, p2, p4))
+}
+
+// gridCellArea 计算网格单元的球面面积，单位平方米
+func gridCellArea(minLng, minLat, maxLng, maxLat float64) float64 {
+	return geo.Area(orb.Bound{Min: orb.Point{minLng, minLat}, Max: orb.Point{maxLng, maxLat}})
+}
+
+// EstimateOverlapArea 采用网格采样估算两个多边形的重叠面积（平方米），resolution 为每个方向的采样数
+func EstimateOverlapArea(a, b orb.Polygon, resolution int) float64 {
+	boundA, boundB := a.Bound(), b.Bound()
+	if !boundA.Intersects(boundB) {
+		return 0
+	}
+	minLng := math.Max(boundA.Min[0], boundB.Min[0])
+	minLat := math.Max(boundA.Min[1], boundB.Min[1])
+	maxLng := math.Min(boundA.Max[0], boundB.Max[0])
+	maxLat := math.Min(boundA.Max[1], boundB.Max[1])
+	if maxLng <= minLng || maxLat <= minLat {
+		return 0
+	}
+	stepLng := (maxLng - minLng) / float64(resolution)
+	stepLat := (maxLat - minLat) / float64(resolution)
+	var area float64
+	for row := 0; row < resolution; row++ {
+		lat := minLat + stepLat*float64(row)
+		// 同一行的单元面积相同
+		cellArea := gridCellArea(minLng, lat, minLng+stepLng, lat+stepLat)
+		for col := 0; col < resolution; col++ {
+			center := orb.Point{minLng + stepLng*(float64(col)+0.5), lat + stepLat*0.5}
+			if planar.PolygonContains(a, center) && planar.PolygonContains(b, center) {
+				area += cellArea
+			}
+		}
+	}
+	return area
+}
+
+// CoverageGrid 网格覆盖分析的结果
+type CoverageGrid struct {
+	TotalArea   float64          // 分析区域面积，单位平方米
+	CoveredArea float64          // 被覆盖的面积，单位平方米
+	Uncovered   orb.MultiPolygon // 未覆盖区域，由同一行相邻的未覆盖网格合并而成
+}
+
+// AnalyzeCoverage 将区域划分为 resolution×resolution 的网格，以网格中心是否落在任一多边形内判断覆盖情况
+func AnalyzeCoverage(area orb.Bound, polygons []orb.Polygon, resolution int) *CoverageGrid {
+	result := &CoverageGrid{Uncovered: orb.MultiPolygon{}}
+	bounds := make([]orb.Bound, len(polygons))
+	for i, polygon := range polygons {
+		bounds[i] = polygon.Bound()
+	}
+	stepLng := (area.Max[0] - area.Min[0]) / float64(resolution)
+	stepLat := (area.Max[1] - area.Min[1]) / float64(resolution)
+	if stepLng <= 0 || stepLat <= 0 {
+		return result
+	}
+	for row := 0; row < resolution; row++ {
+		lat := area.Min[1] + stepLat*float64(row)
+		cellArea := gridCellArea(area.Min[0], lat, area.Min[0]+stepLng, lat+stepLat)
+		runStart := -1
+		flush := func(end int) {
+			if runStart < 0 {
+				return
+			}
+			bound := orb.Bound{
+				Min: orb.Point{area.Min[0] + stepLng*float64(runStart), lat},
+				Max: orb.Point{area.Min[0] + stepLng*float64(end), lat + stepLat},
+			}
+			result.Uncovered = append(result.Uncovered, bound.ToPolygon())
+			runStart = -1
+		}
+		for col := 0; col < resolution; col++ {
+			center := orb.Point{area.Min[0] + stepLng*(float64(col)+0.5), lat + stepLat*0.5}
+			result.TotalArea += cellArea
+			covered := false
+			for i, polygon := range polygons {
+				if bounds[i].Contains(center) && planar.PolygonContains(polygon, center) {
+					covered = true
+					break
+				}
+			}
+			if covered {
+				result.CoveredArea += cellArea
+				flush(col)
+			} else if runStart < 0 {
+				runStart = col
+			}
+		}
+		flush(resolution)
+	}
+	return result
+}
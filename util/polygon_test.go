@@ -0,0 +1,81 @@
+package util
+
+import (
+	"go_logistics/common"
+	"math"
+	"strings"
+	"testing"
+)
+
+// scopePoints 将经纬度对转换为范围点位，坐标以北京附近为原点偏移，避免出现 (0, 0)
+func scopePoints(coords ...[2]float64) []common.GeoPoint {
+	points := make([]common.GeoPoint, 0, len(coords))
+	for _, c := range coords {
+		points = append(points, common.GeoPoint{Type: "Point", Coordinates: []float64{116.3 + c[0], 39.9 + c[1]}})
+	}
+	return points
+}
+
+func TestScopeToOrbPolygon(t *testing.T) {
+	open := scopePoints([2]float64{0, 0}, [2]float64{0.01, 0}, [2]float64{0.01, 0.01})
+	ring := ScopeToOrbPolygon(open)[0]
+	if len(ring) != 4 || !ring.Closed() {
+		t.Errorf("未闭合的范围应补上首点，实际 %v", ring)
+	}
+	closed := append(open, open[0])
+	if ring = ScopeToOrbPolygon(closed)[0]; len(ring) != 4 {
+		t.Errorf("已闭合的范围不应重复补点，实际 %v", ring)
+	}
+	if ring = ScopeToOrbPolygon(nil)[0]; len(ring) != 0 {
+		t.Errorf("空范围应返回空环，实际 %v", ring)
+	}
+}
+
+func TestValidateScopePolygon(t *testing.T) {
+	square := scopePoints([2]float64{0, 0}, [2]float64{0.01, 0}, [2]float64{0.01, 0.01}, [2]float64{0, 0.01})
+	tests := []struct {
+		name    string
+		points  []common.GeoPoint
+		wantErr string // 为空表示校验通过，否则为错误信息应包含的内容
+	}{
+		{name: "约一平方公里的正方形", points: square},
+		{name: "首尾相同的闭合范围", points: append(append([]common.GeoPoint{}, square...), square[0])},
+		{name: "凹多边形", points: scopePoints([2]float64{0, 0}, [2]float64{0.02, 0}, [2]float64{0.02, 0.02},
+			[2]float64{0.01, 0.005}, [2]float64{0, 0.02})},
+		{name: "没有点", points: nil, wantErr: "至少需要三个不同的点"},
+		{name: "只有两个点", points: square[:2], wantErr: "至少需要三个不同的点"},
+		{name: "三个点中两个相同", points: []common.GeoPoint{square[0], square[1], square[0]}, wantErr: "至少需要三个不同的点"},
+		{name: "相邻点重复", points: []common.GeoPoint{square[0], square[1], square[1], square[2], square[3]}, wantErr: "第2个点与下一个点重复"},
+		{name: "三点共线面积为 0", points: scopePoints([2]float64{0, 0}, [2]float64{0.01, 0}, [2]float64{0.02, 0}), wantErr: "面积"},
+		{name: "8 字形自相交", points: scopePoints([2]float64{0, 0}, [2]float64{0.01, 0.01}, [2]float64{0.01, 0}, [2]float64{0, 0.01}),
+			wantErr: "第1条边与第3条边相交"},
+		{name: "不相邻的边在顶点接触", points: scopePoints([2]float64{0, 0}, [2]float64{0.02, 0}, [2]float64{0.01, 0.01},
+			[2]float64{0.02, 0.02}, [2]float64{0, 0.02}, [2]float64{0.01, 0.01}), wantErr: "相交"},
+		{name: "共线折返", points: scopePoints([2]float64{0, 0}, [2]float64{0.02, 0}, [2]float64{0.01, 0}, [2]float64{0.01, 0.01}),
+			wantErr: "相交"},
+		{name: "面积小于下限", points: scopePoints([2]float64{0, 0}, [2]float64{0.0005, 0}, [2]float64{0.0005, 0.0005}, [2]float64{0, 0.0005}),
+			wantErr: "小于10000平方米"},
+		{name: "纬度超出范围", points: []common.GeoPoint{square[0], {Type: "Point", Coordinates: []float64{116.3, 91}}, square[2]},
+			wantErr: "第2个点经纬度超出范围"},
+		{name: "坐标不是有效数字", points: []common.GeoPoint{square[0], square[1], {Type: "Point", Coordinates: []float64{math.NaN(), 39.9}}},
+			wantErr: "第3个点坐标不是有效数字"},
+		{name: "坐标缺少纬度", points: []common.GeoPoint{{Type: "Point", Coordinates: []float64{116.3}}, square[1], square[2]},
+			wantErr: "第1个点坐标格式错误"},
+		{name: "坐标为空", points: []common.GeoPoint{square[0], square[1], {Type: "Point", Coordinates: []float64{0, 0}}},
+			wantErr: "第3个点坐标为空"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateScopePolygon(tt.points)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateScopePolygon() = %v，期望校验通过", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateScopePolygon() = %v，期望错误包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
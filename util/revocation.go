@@ -0,0 +1,42 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// revokedSessions 已吊销的会话ID及吊销时间。访问 Token 有效期很短，
+// 只需保留最近 AccessTokenTTL 内吊销的会话，校验 Token 时无需查询数据库
+var revokedSessions = struct {
+	sync.RWMutex
+	sessions map[string]time.Time
+}{sessions: make(map[string]time.Time)}
+
+// RevokeSession 在本实例中立即吊销会话
+func RevokeSession(sessionId string, revokeTime time.Time) {
+	revokedSessions.Lock()
+	defer revokedSessions.Unlock()
+	revokedSessions.sessions[sessionId] = revokeTime
+}
+
+// MergeRevokedSessions 合并从数据库同步的吊销记录，并清理已超过访问 Token 有效期的记录
+func MergeRevokedSessions(sessions map[string]time.Time, now time.Time) {
+	revokedSessions.Lock()
+	defer revokedSessions.Unlock()
+	for sessionId, revokeTime := range sessions {
+		revokedSessions.sessions[sessionId] = revokeTime
+	}
+	for sessionId, revokeTime := range revokedSessions.sessions {
+		if now.Sub(revokeTime) > AccessTokenTTL {
+			delete(revokedSessions.sessions, sessionId)
+		}
+	}
+}
+
+// IsSessionRevoked 会话是否已吊销
+func IsSessionRevoked(sessionId string) bool {
+	revokedSessions.RLock()
+	defer revokedSessions.RUnlock()
+	_, ok := revokedSessions.sessions[sessionId]
+	return ok
+}
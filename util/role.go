@@ -0,0 +1,43 @@
+package util
+
+import "fmt"
+
+// Role 用户角色，登录后写入 Token
+type Role string
+
+const (
+	RoleAdmin          Role = "admin"           // 管理员：全部权限，管理用户与角色
+	RoleDispatcher     Role = "dispatcher"      // 调度员：订单、线路、车辆、司机与派送调度
+	RoleOutletOperator Role = "outlet_operator" // 网点操作员：网点库存扫描、派送与收件
+	RoleDriver         Role = DriverRole        // 司机：仅限司机端接口，由司机账号登录获得
+	RoleAnalyst        Role = "analyst"         // 分析员：只读
+)
+
+// Roles 全部角色，按权限从大到小排列
+var Roles = []Role{RoleAdmin, RoleDispatcher, RoleOutletOperator, RoleDriver, RoleAnalyst}
+
+// DefaultUserRole 新建用户未指定角色时使用的角色
+const DefaultUserRole = RoleAnalyst
+
+func (r Role) String() string {
+	textMap := map[Role]string{
+		RoleAdmin:          "管理员",
+		RoleDispatcher:     "调度员",
+		RoleOutletOperator: "网点操作员",
+		RoleDriver:         "司机",
+		RoleAnalyst:        "分析员",
+	}
+	return textMap[r]
+}
+
+// ParseUserRole 解析可分配给后台用户的角色，司机角色只能通过司机账号登录获得
+func ParseUserRole(name string) (Role, error) {
+	role := Role(name)
+	switch role {
+	case RoleAdmin, RoleDispatcher, RoleOutletOperator, RoleAnalyst:
+		return role, nil
+	case RoleDriver:
+		return "", fmt.Errorf("司机角色通过司机账号登录获得，不能分配给后台用户")
+	}
+	return "", fmt.Errorf("不支持的角色: %s", name)
+}
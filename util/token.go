@@ -1,25 +1,57 @@
 package util
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"go_logistics/config"
 	"time"
 )
 
+const (
+	// DriverRole 司机端 Token 的角色，Name 中保存司机ID
+	DriverRole = "driver"
+)
+
+const (
+	// AccessTokenTTL 访问 Token 的有效期，过期后使用刷新 Token 换取新的访问 Token
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL 刷新 Token 的有效期，每次刷新都会轮换为新的刷新 Token
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// tokenSecret 签发与校验 Token 的密钥，启动时由 SetTokenSecret 设置
+var tokenSecret []byte
+
+// errTokenSecretMissing 未设置密钥时拒绝签发与校验 Token
+var errTokenSecretMissing = errors.New("未配置 Token 密钥")
+
+// SetTokenSecret 设置签发与校验 Token 的密钥
+func SetTokenSecret(secret string) {
+	tokenSecret = []byte(secret)
+}
+
 type CustomClaims struct {
-	Name string `json:"name"`
+	Name      string   `json:"name"`
+	Role      string   `json:"role,omitempty"`
+	OutletIDs []string `json:"outletIds,omitempty"` // 后台用户分配的网点
+	SessionID string   `json:"sid"`                 // 登录会话，退出登录时按会话吊销
 	jwt.RegisteredClaims
 }
 
 // 生成 Token
-func createToken(name string, secret []byte) (string, error) {
-	claims := CustomClaims{
-		Name: name,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 过期时间
-			IssuedAt:  jwt.NewNumericDate(time.Now()),                     // 签发时间
-			Issuer:    "logistics",                                        // 签发者
-		},
+func createToken(claims CustomClaims, secret []byte) (string, error) {
+	if len(secret) == 0 {
+		return "", errTokenSecretMissing
+	}
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)), // 过期时间
+		IssuedAt:  jwt.NewNumericDate(now),                     // 签发时间
+		Issuer:    "logistics",                                 // 签发者
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -28,24 +60,53 @@ func createToken(name string, secret []byte) (string, error) {
 
 // 解析 Token
 func parseToken(tokenString string, secret []byte) (*CustomClaims, error) {
+	if len(secret) == 0 {
+		return nil, errTokenSecretMissing
+	}
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return secret, nil
 	})
-
+	if err != nil {
+		return nil, err
+	}
 	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
 		return claims, nil
 	}
-	return nil, err
+	return nil, jwt.ErrTokenInvalidClaims
+}
+
+// GenerateToken 生成后台用户的访问 Token，Token 中携带用户角色、分配的网点与会话ID
+func GenerateToken(name string, role Role, outletIds []string, sessionId string) (token string, err error) {
+	claims := CustomClaims{Name: name, Role: string(role), OutletIDs: outletIds, SessionID: sessionId}
+	token, err = createToken(claims, tokenSecret)
+	return
 }
 
-// GenerateToken 生成 Token
-func GenerateToken(name string) (token string, err error) {
-	token, err = createToken(name, []byte(config.SecretKey))
+// GenerateDriverToken 生成司机端访问 Token
+func GenerateDriverToken(driverId string, sessionId string) (token string, err error) {
+	claims := CustomClaims{Name: driverId, Role: DriverRole, SessionID: sessionId}
+	token, err = createToken(claims, tokenSecret)
 	return
 }
 
 // CheckToken 检查 Token
 func CheckToken(token string) (claims *CustomClaims, err error) {
-	claims, err = parseToken(token, []byte(config.SecretKey))
+	claims, err = parseToken(token, tokenSecret)
 	return
 }
+
+// MakeRefreshToken 生成随机的刷新 Token，数据库中只保存其哈希
+func MakeRefreshToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("生成刷新 Token 失败: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken 计算刷新 Token 的哈希
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
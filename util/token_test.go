@@ -0,0 +1,64 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToken(t *testing.T) {
+	secret := tokenSecret
+	t.Cleanup(func() {
+		tokenSecret = secret
+	})
+
+	SetTokenSecret("")
+	if _, err := GenerateToken("admin", RoleAdmin, nil, "s1"); !errors.Is(err, errTokenSecretMissing) {
+		t.Errorf("未配置密钥时应拒绝签发，实际 %v", err)
+	}
+
+	SetTokenSecret("first-secret")
+	token, err := GenerateToken("operator", RoleOutletOperator, []string{"o1", "o2"}, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := CheckToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Name != "operator" || claims.Role != string(RoleOutletOperator) || len(claims.OutletIDs) != 2 || claims.SessionID != "s1" {
+		t.Errorf("解析出的 Token 内容不符: %+v", claims)
+	}
+
+	driverToken, err := GenerateDriverToken("d1", "s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err = CheckToken(driverToken); err != nil || claims.Role != DriverRole || claims.Name != "d1" {
+		t.Errorf("司机 Token 解析结果 %+v, %v", claims, err)
+	}
+
+	SetTokenSecret("second-secret")
+	if _, err = CheckToken(token); err == nil {
+		t.Error("更换密钥后旧 Token 应校验失败")
+	}
+	if _, err = CheckToken("not-a-token"); err == nil {
+		t.Error("非法 Token 应校验失败")
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	first, hash, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("两次生成的刷新 Token 不应相同")
+	}
+	if HashRefreshToken(first) != hash || hash == first {
+		t.Error("刷新 Token 哈希不一致")
+	}
+}
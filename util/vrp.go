@@ -0,0 +1,240 @@
+package util
+
+import (
+	"sort"
+)
+
+// VRPStop 末端派送的一个停靠点，时间窗为相对出发时刻的小时数，WindowEnd 为 0 表示不限
+type VRPStop struct {
+	Weight      float64
+	WindowStart float64
+	WindowEnd   float64
+}
+
+// VRPProblem 车辆路径问题：所有车辆从同一网点出发并返回。
+// Matrix 为 (len(Stops)+1) 阶行驶距离矩阵，下标 0 为网点，下标 i+1 为 Stops[i]
+type VRPProblem struct {
+	Stops        []VRPStop
+	Capacities   []float64 // 各车辆核定载重
+	Matrix       [][]RouteCost
+	ServiceHours float64 // 每个停靠点的停留时长，单位小时
+}
+
+// VRPRoute 一辆车的派送顺序
+type VRPRoute struct {
+	Vehicle  int       // 车辆在 Capacities 中的下标
+	Stops    []int     // 停靠点在 Stops 中的下标，按派送先后排列
+	Arrivals []float64 // 到达各停靠点的时刻（相对出发时刻的小时数）
+	Load     float64
+	Distance float64 // 含返回网点的总里程，单位公里
+	Duration float64 // 含返回网点的总时长，单位小时
+}
+
+// VRPSolution 求解结果，Unassigned 为载重或时间窗无法满足的停靠点下标
+type VRPSolution struct {
+	Routes     []*VRPRoute
+	Unassigned []int
+}
+
+// vrpSaving 合并两条路径节省的里程：路径 A 以 from 结尾，路径 B 以 to 开头
+type vrpSaving struct {
+	from, to int
+	value    float64
+}
+
+// SolveVRP 使用 Clarke-Wright 节约算法构造路径，按载重最优匹配分配车辆，
+// 未分配的停靠点尝试插入已有路径或启用剩余车辆，最后对每条路径做 2-opt 优化。均只接受满足载重与时间窗的方案
+func SolveVRP(problem *VRPProblem) *VRPSolution {
+	solution := &VRPSolution{}
+	maxCapacity := 0.0
+	for _, capacity := range problem.Capacities {
+		maxCapacity = max(maxCapacity, capacity)
+	}
+
+	// 每个停靠点单独成一条路径
+	routeOf := make(map[int]*VRPRoute, len(problem.Stops))
+	for i, stop := range problem.Stops {
+		route := &VRPRoute{Vehicle: -1, Stops: []int{i}, Load: stop.Weight}
+		if stop.Weight > maxCapacity || !problem.evaluate(route) {
+			solution.Unassigned = append(solution.Unassigned, i)
+			continue
+		}
+		routeOf[i] = route
+	}
+
+	// 按节约值从大到小合并路径
+	var savings []vrpSaving
+	for i := range routeOf {
+		for j := range routeOf {
+			if i == j {
+				continue
+			}
+			value := problem.cost(i+1, 0).Distance + problem.cost(0, j+1).Distance - problem.cost(i+1, j+1).Distance
+			if value > 0 {
+				savings = append(savings, vrpSaving{from: i, to: j, value: value})
+			}
+		}
+	}
+	sort.Slice(savings, func(a, b int) bool {
+		if savings[a].value != savings[b].value {
+			return savings[a].value > savings[b].value
+		}
+		if savings[a].from != savings[b].from {
+			return savings[a].from < savings[b].from
+		}
+		return savings[a].to < savings[b].to
+	})
+	for _, saving := range savings {
+		a, b := routeOf[saving.from], routeOf[saving.to]
+		if a == b || a.Stops[len(a.Stops)-1] != saving.from || b.Stops[0] != saving.to || a.Load+b.Load > maxCapacity {
+			continue
+		}
+		merged := &VRPRoute{Vehicle: -1, Stops: append(append([]int{}, a.Stops...), b.Stops...), Load: a.Load + b.Load}
+		if !problem.evaluate(merged) {
+			continue
+		}
+		for _, stop := range merged.Stops {
+			routeOf[stop] = merged
+		}
+	}
+
+	// 载重大的路径优先分配能装下的最小车辆
+	var routes []*VRPRoute
+	seen := make(map[*VRPRoute]bool)
+	for i := range problem.Stops {
+		if route, ok := routeOf[i]; ok && !seen[route] {
+			seen[route] = true
+			routes = append(routes, route)
+		}
+	}
+	sort.SliceStable(routes, func(a, b int) bool { return routes[a].Load > routes[b].Load })
+	used := make([]bool, len(problem.Capacities))
+	for _, route := range routes {
+		best := -1
+		for v, capacity := range problem.Capacities {
+			if !used[v] && capacity >= route.Load && (best < 0 || capacity < problem.Capacities[best]) {
+				best = v
+			}
+		}
+		if best < 0 {
+			solution.Unassigned = append(solution.Unassigned, route.Stops...)
+			continue
+		}
+		used[best] = true
+		route.Vehicle = best
+		solution.Routes = append(solution.Routes, route)
+	}
+
+	// 未分配的停靠点按最小增加里程插入已有路径，插不进时启用剩余车辆中载重最大的一辆
+	var unassigned []int
+	sort.Ints(solution.Unassigned)
+	for _, stop := range solution.Unassigned {
+		if problem.insert(solution.Routes, stop) {
+			continue
+		}
+		vehicle := -1
+		for v, capacity := range problem.Capacities {
+			if !used[v] && capacity >= problem.Stops[stop].Weight && (vehicle < 0 || capacity > problem.Capacities[vehicle]) {
+				vehicle = v
+			}
+		}
+		route := &VRPRoute{Vehicle: vehicle, Stops: []int{stop}, Load: problem.Stops[stop].Weight}
+		if vehicle < 0 || !problem.evaluate(route) {
+			unassigned = append(unassigned, stop)
+			continue
+		}
+		used[vehicle] = true
+		solution.Routes = append(solution.Routes, route)
+	}
+	solution.Unassigned = unassigned
+
+	for _, route := range solution.Routes {
+		problem.twoOpt(route)
+	}
+	return solution
+}
+
+// cost 矩阵下标之间的行驶距离与时长
+func (p *VRPProblem) cost(from int, to int) RouteCost {
+	return p.Matrix[from][to]
+}
+
+// evaluate 按派送顺序推算到达时刻、里程与时长，早于时间窗开始时原地等待，晚于时间窗结束时返回 false
+func (p *VRPProblem) evaluate(route *VRPRoute) bool {
+	arrivals := make([]float64, len(route.Stops))
+	clock, distance, previous := 0.0, 0.0, 0
+	for i, stop := range route.Stops {
+		leg := p.cost(previous, stop+1)
+		clock += leg.Duration
+		distance += leg.Distance
+		window := p.Stops[stop]
+		if clock < window.WindowStart {
+			clock = window.WindowStart
+		}
+		if window.WindowEnd > 0 && clock > window.WindowEnd {
+			return false
+		}
+		arrivals[i] = clock
+		clock += p.ServiceHours
+		previous = stop + 1
+	}
+	back := p.cost(previous, 0)
+	route.Arrivals = arrivals
+	route.Distance = distance + back.Distance
+	route.Duration = clock + back.Duration
+	return true
+}
+
+// insert 将停靠点插入增加里程最少且满足载重与时间窗的位置
+func (p *VRPProblem) insert(routes []*VRPRoute, stop int) bool {
+	var (
+		best     *VRPRoute
+		bestStop []int
+		bestCost float64
+	)
+	for _, route := range routes {
+		if route.Load+p.Stops[stop].Weight > p.Capacities[route.Vehicle] {
+			continue
+		}
+		for position := 0; position <= len(route.Stops); position++ {
+			stops := make([]int, 0, len(route.Stops)+1)
+			stops = append(append(append(stops, route.Stops[:position]...), stop), route.Stops[position:]...)
+			candidate := &VRPRoute{Stops: stops}
+			if !p.evaluate(candidate) {
+				continue
+			}
+			if increase := candidate.Distance - route.Distance; best == nil || increase < bestCost {
+				best, bestStop, bestCost = route, stops, increase
+			}
+		}
+	}
+	if best == nil {
+		return false
+	}
+	best.Stops = bestStop
+	best.Load += p.Stops[stop].Weight
+	p.evaluate(best)
+	return true
+}
+
+// twoOpt 反转路径中的一段，里程缩短且满足时间窗时接受，直到没有改进
+func (p *VRPProblem) twoOpt(route *VRPRoute) {
+	p.evaluate(route)
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < len(route.Stops)-1; i++ {
+			for k := i + 1; k < len(route.Stops); k++ {
+				stops := append([]int{}, route.Stops...)
+				for a, b := i, k; a < b; a, b = a+1, b-1 {
+					stops[a], stops[b] = stops[b], stops[a]
+				}
+				candidate := &VRPRoute{Stops: stops}
+				if p.evaluate(candidate) && candidate.Distance < route.Distance-1e-9 {
+					route.Stops, route.Arrivals = candidate.Stops, candidate.Arrivals
+					route.Distance, route.Duration = candidate.Distance, candidate.Duration
+					improved = true
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package util
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// vrpSpeed 测试用的行驶速度，单位公里/小时
+const vrpSpeed = 50.0
+
+// newLineProblem 构造网点位于原点、停靠点位于数轴 positions 处的问题，里程为坐标差，时长按 vrpSpeed 计算
+func newLineProblem(positions []float64, weights []float64, capacities []float64) *VRPProblem {
+	nodes := append([]float64{0}, positions...)
+	matrix := NewRouteMatrix(len(nodes), len(nodes))
+	for i := range nodes {
+		for j := range nodes {
+			distance := math.Abs(nodes[i] - nodes[j])
+			matrix[i][j] = RouteCost{Distance: distance, Duration: distance / vrpSpeed}
+		}
+	}
+	stops := make([]VRPStop, len(positions))
+	for i := range stops {
+		stops[i].Weight = weights[i]
+	}
+	return &VRPProblem{Stops: stops, Capacities: capacities, Matrix: matrix}
+}
+
+// checkVRPSolution 校验每个停靠点恰好出现一次，且每条路径满足载重与时间窗
+func checkVRPSolution(t *testing.T, problem *VRPProblem, solution *VRPSolution) {
+	t.Helper()
+	seen := make(map[int]int)
+	vehicles := make(map[int]bool)
+	for _, route := range solution.Routes {
+		if route.Vehicle < 0 || route.Vehicle >= len(problem.Capacities) {
+			t.Fatalf("路径车辆下标越界: %d", route.Vehicle)
+		}
+		if vehicles[route.Vehicle] {
+			t.Errorf("车辆 %d 被分配了多条路径", route.Vehicle)
+		}
+		vehicles[route.Vehicle] = true
+		load := 0.0
+		for i, stop := range route.Stops {
+			seen[stop]++
+			load += problem.Stops[stop].Weight
+			window := problem.Stops[stop]
+			if route.Arrivals[i] < window.WindowStart || (window.WindowEnd > 0 && route.Arrivals[i] > window.WindowEnd) {
+				t.Errorf("停靠点 %d 到达时刻 %.2f 不在时间窗 [%.2f, %.2f] 内", stop, route.Arrivals[i], window.WindowStart, window.WindowEnd)
+			}
+		}
+		if math.Abs(load-route.Load) > 1e-9 {
+			t.Errorf("路径载重 %.2f 与停靠点合计 %.2f 不一致", route.Load, load)
+		}
+		if route.Load > problem.Capacities[route.Vehicle] {
+			t.Errorf("车辆 %d 超载: %.2f > %.2f", route.Vehicle, route.Load, problem.Capacities[route.Vehicle])
+		}
+	}
+	for _, stop := range solution.Unassigned {
+		seen[stop]++
+	}
+	for i := range problem.Stops {
+		if seen[i] != 1 {
+			t.Errorf("停靠点 %d 出现 %d 次", i, seen[i])
+		}
+	}
+}
+
+func TestSolveVRP(t *testing.T) {
+	tests := []struct {
+		name       string
+		problem    *VRPProblem
+		routes     int
+		unassigned []int
+	}{
+		{
+			name:    "同一方向的停靠点合并为一条路径",
+			problem: newLineProblem([]float64{10, 20, 30}, []float64{2, 2, 2}, []float64{10}),
+			routes:  1,
+		},
+		{
+			name:    "超过载重时拆分到多辆车",
+			problem: newLineProblem([]float64{10, 20}, []float64{6, 6}, []float64{10, 10}),
+			routes:  2,
+		},
+		{
+			name:       "车辆不足时剩余停靠点未分配",
+			problem:    newLineProblem([]float64{10, 20}, []float64{6, 6}, []float64{10}),
+			routes:     1,
+			unassigned: []int{1},
+		},
+		{
+			name:       "单个停靠点超过最大载重",
+			problem:    newLineProblem([]float64{10, 20}, []float64{20, 2}, []float64{10, 10}),
+			routes:     1,
+			unassigned: []int{0},
+		},
+		{
+			name: "时间窗内无法到达的停靠点未分配",
+			problem: func() *VRPProblem {
+				problem := newLineProblem([]float64{10, 100}, []float64{1, 1}, []float64{10})
+				problem.Stops[1].WindowEnd = 1
+				return problem
+			}(),
+			routes:     1,
+			unassigned: []int{1},
+		},
+		{
+			name: "停留时长计入时间窗",
+			problem: func() *VRPProblem {
+				problem := newLineProblem([]float64{10, 20}, []float64{1, 1}, []float64{10})
+				problem.Stops[1].WindowEnd = 1
+				problem.ServiceHours = 1
+				return problem
+			}(),
+			routes: 1,
+		},
+		{
+			name:    "没有停靠点",
+			problem: newLineProblem(nil, nil, []float64{10}),
+			routes:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution := SolveVRP(tt.problem)
+			checkVRPSolution(t, tt.problem, solution)
+			if len(solution.Routes) != tt.routes {
+				t.Errorf("路径数 = %d，期望 %d", len(solution.Routes), tt.routes)
+			}
+			unassigned := slices.Clone(solution.Unassigned)
+			slices.Sort(unassigned)
+			if !slices.Equal(unassigned, tt.unassigned) {
+				t.Errorf("未分配停靠点 = %v，期望 %v", unassigned, tt.unassigned)
+			}
+		})
+	}
+}
+
+func TestSolveVRPTimeWindowOrder(t *testing.T) {
+	problem := newLineProblem([]float64{10, 20}, []float64{1, 1}, []float64{10})
+	problem.Stops[0].WindowStart, problem.Stops[0].WindowEnd = 2, 3
+	problem.Stops[1].WindowEnd = 1
+	solution := SolveVRP(problem)
+	if len(solution.Routes) != 1 || !slices.Equal(solution.Routes[0].Stops, []int{1, 0}) {
+		t.Fatalf("派送顺序 = %v，期望先送停靠点 1", solution.Routes)
+	}
+	// 0.4 小时到达停靠点 1，0.6 小时到达停靠点 0 后等待至 2 小时
+	if arrivals := solution.Routes[0].Arrivals; math.Abs(arrivals[0]-0.4) > 1e-9 || arrivals[1] != 2 {
+		t.Errorf("到达时刻 = %v，期望 [0.4 2]", arrivals)
+	}
+}
+
+func TestSolveVRPSmallestVehicle(t *testing.T) {
+	problem := newLineProblem([]float64{10}, []float64{4}, []float64{20, 5, 10})
+	solution := SolveVRP(problem)
+	if len(solution.Routes) != 1 || solution.Routes[0].Vehicle != 1 {
+		t.Fatalf("应分配能装下的最小车辆 1，实际 %v", solution.Routes)
+	}
+}
+
+func TestVRPTwoOpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		stops    []int
+		windows  map[int][2]float64
+		distance float64
+	}{
+		{name: "消除往返绕行", stops: []int{2, 0, 1}, distance: 60},
+		{name: "已是最优时保持不变", stops: []int{0, 1, 2}, distance: 60},
+		{name: "反转后违反时间窗时不接受", stops: []int{2, 0, 1}, distance: 80,
+			windows: map[int][2]float64{0: {0.5, 1.05}, 1: {1.1, 0}, 2: {0, 0.7}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := newLineProblem([]float64{10, 20, 30}, []float64{1, 1, 1}, []float64{10})
+			for stop, window := range tt.windows {
+				problem.Stops[stop].WindowStart, problem.Stops[stop].WindowEnd = window[0], window[1]
+			}
+			route := &VRPRoute{Vehicle: 0, Stops: slices.Clone(tt.stops), Load: 3}
+			problem.twoOpt(route)
+			if math.Abs(route.Distance-tt.distance) > 1e-9 {
+				t.Errorf("2-opt 后里程 = %.2f，期望 %.2f（顺序 %v）", route.Distance, tt.distance, route.Stops)
+			}
+			if len(route.Arrivals) != len(route.Stops) {
+				t.Errorf("到达时刻数量 %d 与停靠点数量 %d 不一致", len(route.Arrivals), len(route.Stops))
+			}
+		})
+	}
+}